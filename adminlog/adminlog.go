@@ -2,20 +2,24 @@ package adminlog
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"sync"
 	"time"
 
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+
 	"github.com/bwmarrin/discordgo"
 )
 
 type Logger struct {
-	s            *discordgo.Session
-	guildID      string
-	logChannelID string
-	mu           sync.RWMutex
-	memberCache  map[string]*memberSnapshot // key: userID
+	s           discord.Session
+	guilds      *guildcfg.Store
+	mu          sync.RWMutex
+	memberCache map[string]map[string]*memberSnapshot // key: guildID -> userID
+	log         *slog.Logger
 }
 
 type memberSnapshot struct {
@@ -29,17 +33,20 @@ type execInfo struct {
 	Reason string
 }
 
-// Init регистрирует хэндлеры и запускает кэш.
-func Init(s *discordgo.Session, guildID, logChannelID string) *Logger {
+// Init регистрирует хэндлеры; кэш участников прогружается для каждого сервера на GuildCreate.
+// Лог-канал берётся из настроек сервера (guildcfg).
+func Init(s *discordgo.Session, guilds *guildcfg.Store, log *slog.Logger) *Logger {
 	l := &Logger{
-		s:            s,
-		guildID:      guildID,
-		logChannelID: logChannelID,
-		memberCache:  make(map[string]*memberSnapshot),
+		s:           s,
+		guilds:      guilds,
+		memberCache: make(map[string]map[string]*memberSnapshot),
+		log:         log,
 	}
 
 	// начальная прогрузка членов (по возможности)
-	go l.primeCache()
+	s.AddHandler(func(_ *discordgo.Session, ev *discordgo.GuildCreate) {
+		go l.primeCache(ev.ID)
+	})
 
 	// события
 	s.AddHandler(l.onGuildMemberUpdate) // никнеймы/роли
@@ -53,70 +60,74 @@ func Init(s *discordgo.Session, guildID, logChannelID string) *Logger {
 // ----------------- Handlers -----------------
 
 func (l *Logger) onGuildMemberUpdate(_ *discordgo.Session, ev *discordgo.GuildMemberUpdate) {
-	if ev.GuildID != l.guildID || ev.Member == nil || ev.User == nil {
+	if l.guilds.Get(ev.GuildID) == nil || ev.Member == nil || ev.User == nil {
 		return
 	}
 
-	before := l.getSnapshot(ev.User.ID)
+	before := l.getSnapshot(ev.GuildID, ev.User.ID)
 	after := snapshotFromMember(ev.Member)
 
 	// ✏️ смена никнейма
 	if before != nil && before.Nick != after.Nick {
 		exec := l.lookupExecutor(ev.GuildID, ev.User.ID, discordgo.AuditLogActionMemberUpdate)
-		l.postNickChange(ev.User, safe(before.Nick), safe(after.Nick), exec)
+		l.postNickChange(ev.GuildID, ev.User, safe(before.Nick), safe(after.Nick), exec)
 	}
 
 	// 🛠 роли
 	added, removed := diffRoles(before, after)
 	if len(added) > 0 || len(removed) > 0 {
 		exec := l.lookupExecutor(ev.GuildID, ev.User.ID, discordgo.AuditLogActionMemberRoleUpdate)
-		l.postRoleUpdate(ev.User, added, removed, exec)
+		l.postRoleUpdate(ev.GuildID, ev.User, added, removed, exec)
 	}
 
 	// обновляем кэш
-	l.setSnapshot(ev.User.ID, after)
+	l.setSnapshot(ev.GuildID, ev.User.ID, after)
 }
 
 func (l *Logger) onGuildMemberRemove(_ *discordgo.Session, ev *discordgo.GuildMemberRemove) {
-	if ev.GuildID != l.guildID || ev.User == nil {
+	if l.guilds.Get(ev.GuildID) == nil || ev.User == nil {
 		return
 	}
 	// 👢 отличаем кик от обычного выхода
 	exec := l.lookupExecutor(ev.GuildID, ev.User.ID, discordgo.AuditLogActionMemberKick)
 	if exec != nil {
-		l.postKick(ev.User, exec)
+		l.postKick(ev.GuildID, ev.User, exec)
 	}
-	l.deleteSnapshot(ev.User.ID)
+	l.deleteSnapshot(ev.GuildID, ev.User.ID)
 }
 
 func (l *Logger) onGuildBanAdd(_ *discordgo.Session, ev *discordgo.GuildBanAdd) {
-	if ev.GuildID != l.guildID || ev.User == nil {
+	if l.guilds.Get(ev.GuildID) == nil || ev.User == nil {
 		return
 	}
 	exec := l.lookupExecutor(ev.GuildID, ev.User.ID, discordgo.AuditLogActionMemberBanAdd)
-	l.postBan(ev.User, exec)
-	l.deleteSnapshot(ev.User.ID)
+	l.postBan(ev.GuildID, ev.User, exec)
+	l.deleteSnapshot(ev.GuildID, ev.User.ID)
 }
 
 func (l *Logger) onGuildBanRemove(_ *discordgo.Session, ev *discordgo.GuildBanRemove) {
-	if ev.GuildID != l.guildID || ev.User == nil {
+	if l.guilds.Get(ev.GuildID) == nil || ev.User == nil {
 		return
 	}
 	exec := l.lookupExecutor(ev.GuildID, ev.User.ID, discordgo.AuditLogActionMemberBanRemove)
-	l.postUnban(ev.User, exec)
+	l.postUnban(ev.GuildID, ev.User, exec)
 }
 
 // ----------------- Helpers (state/cache) -----------------
 
-func (l *Logger) primeCache() {
+func (l *Logger) primeCache(guildID string) {
 	after := ""
 	for {
-		members, err := l.s.GuildMembers(l.guildID, after, 1000)
-		if err != nil || len(members) == 0 {
+		members, err := l.s.GuildMembers(guildID, after, 1000)
+		if err != nil {
+			l.log.Warn("prime member cache", "guild", guildID, "err", err)
+			return
+		}
+		if len(members) == 0 {
 			return
 		}
 		for _, m := range members {
-			l.setSnapshot(m.User.ID, snapshotFromMember(m))
+			l.setSnapshot(guildID, m.User.ID, snapshotFromMember(m))
 			after = m.User.ID
 		}
 		if len(members) < 1000 {
@@ -159,22 +170,27 @@ func diffRoles(before, after *memberSnapshot) (added, removed []string) {
 	return
 }
 
-func (l *Logger) getSnapshot(userID string) *memberSnapshot {
+func (l *Logger) getSnapshot(guildID, userID string) *memberSnapshot {
 	l.mu.RLock()
 	defer l.mu.RUnlock()
-	return l.memberCache[userID]
+	return l.memberCache[guildID][userID]
 }
 
-func (l *Logger) setSnapshot(userID string, snap *memberSnapshot) {
+func (l *Logger) setSnapshot(guildID, userID string, snap *memberSnapshot) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.memberCache[userID] = snap
+	byUser, ok := l.memberCache[guildID]
+	if !ok {
+		byUser = make(map[string]*memberSnapshot)
+		l.memberCache[guildID] = byUser
+	}
+	byUser[userID] = snap
 }
 
-func (l *Logger) deleteSnapshot(userID string) {
+func (l *Logger) deleteSnapshot(guildID, userID string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	delete(l.memberCache, userID)
+	delete(l.memberCache[guildID], userID)
 }
 
 // ----------------- Audit Log lookup -----------------
@@ -185,7 +201,11 @@ func (l *Logger) deleteSnapshot(userID string) {
 // - e.ActionType — *discordgo.AuditLogAction (указатель).
 func (l *Logger) lookupExecutor(guildID, targetUserID string, action discordgo.AuditLogAction) *execInfo {
 	al, err := l.s.GuildAuditLog(guildID, "", "", int(action), 50)
-	if err != nil || al == nil {
+	if err != nil {
+		l.log.Debug("read audit log", "guild", guildID, "action", int(action), "err", err)
+		return nil
+	}
+	if al == nil {
 		return nil
 	}
 	for _, e := range al.AuditLogEntries {
@@ -209,55 +229,58 @@ func (l *Logger) lookupExecutor(guildID, targetUserID string, action discordgo.A
 
 // ----------------- Posting (embeds) -----------------
 
-func (l *Logger) postNickChange(target *discordgo.User, oldNick, newNick string, exec *execInfo) {
+func (l *Logger) postNickChange(guildID string, target *discordgo.User, oldNick, newNick string, exec *execInfo) {
+	lang := i18n.ForGuild(guildID)
 	embed := &discordgo.MessageEmbed{
-		Title:     "✏️ Никнейм обновлён",
+		Title:     i18n.T(lang, "log.nick.title"),
 		Color:     0x3498DB,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields: []*discordgo.MessageEmbedField{
-			{Name: "Пользователь", Value: userTag(target), Inline: true},
-			{Name: "Модератор", Value: formatExec(exec), Inline: true},
-			{Name: "Изменение", Value: fmt.Sprintf("%s → %s", codeOrDash(oldNick), codeOrDash(newNick))},
+			{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+			{Name: i18n.T(lang, "field.moderator"), Value: formatExec(exec), Inline: true},
+			{Name: i18n.T(lang, "log.nick.change"), Value: fmt.Sprintf("%s → %s", codeOrDash(oldNick), codeOrDash(newNick))},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
-func (l *Logger) postRoleUpdate(target *discordgo.User, addedIDs, removedIDs []string, exec *execInfo) {
-	added := ResolveRoleNames(l.s, l.guildID, addedIDs)
-	removed := ResolveRoleNames(l.s, l.guildID, removedIDs)
+func (l *Logger) postRoleUpdate(guildID string, target *discordgo.User, addedIDs, removedIDs []string, exec *execInfo) {
+	lang := i18n.ForGuild(guildID)
+	added := ResolveRoleNames(l.s, guildID, addedIDs)
+	removed := ResolveRoleNames(l.s, guildID, removedIDs)
 
 	embed := &discordgo.MessageEmbed{
-		Title:     "🛠 Роли обновлены",
+		Title:     i18n.T(lang, "log.roles.title"),
 		Color:     0xFFA500,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields: []*discordgo.MessageEmbedField{
-			{Name: "Пользователь", Value: userTag(target), Inline: true},
-			{Name: "Модератор", Value: formatExec(exec), Inline: true},
-			{Name: "Добавлены", Value: bullet(added)},
-			{Name: "Убраны", Value: bullet(removed)},
+			{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+			{Name: i18n.T(lang, "field.moderator"), Value: formatExec(exec), Inline: true},
+			{Name: i18n.T(lang, "log.roles.added"), Value: bullet(added)},
+			{Name: i18n.T(lang, "log.roles.removed"), Value: bullet(removed)},
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
-func (l *Logger) postKick(target *discordgo.User, exec *execInfo) {
+func (l *Logger) postKick(guildID string, target *discordgo.User, exec *execInfo) {
+	lang := i18n.ForGuild(guildID)
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: userTag(target), Inline: true},
-		{Name: "Модератор", Value: formatExec(exec), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(exec), Inline: true},
 	}
 	if exec != nil && exec.Reason != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(exec.Reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(exec.Reason)})
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:     "👢 Кик",
+		Title:     i18n.T(lang, "log.kick.title"),
 		Color:     0xE67E22,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields:    fields,
@@ -265,20 +288,21 @@ func (l *Logger) postKick(target *discordgo.User, exec *execInfo) {
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
-func (l *Logger) postBan(target *discordgo.User, exec *execInfo) {
+func (l *Logger) postBan(guildID string, target *discordgo.User, exec *execInfo) {
+	lang := i18n.ForGuild(guildID)
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: userTag(target), Inline: true},
-		{Name: "Модератор", Value: formatExec(exec), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(exec), Inline: true},
 	}
 	if exec != nil && exec.Reason != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(exec.Reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(exec.Reason)})
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:     "⛔ Бан",
+		Title:     i18n.T(lang, "log.ban.title"),
 		Color:     0xE74C3C,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields:    fields,
@@ -286,20 +310,21 @@ func (l *Logger) postBan(target *discordgo.User, exec *execInfo) {
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
-func (l *Logger) postUnban(target *discordgo.User, exec *execInfo) {
+func (l *Logger) postUnban(guildID string, target *discordgo.User, exec *execInfo) {
+	lang := i18n.ForGuild(guildID)
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: userTag(target), Inline: true},
-		{Name: "Модератор", Value: formatExec(exec), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(exec), Inline: true},
 	}
 	if exec != nil && exec.Reason != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(exec.Reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(exec.Reason)})
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:     "♻️ Разбан",
+		Title:     i18n.T(lang, "log.unban.title"),
 		Color:     0x2ECC71,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields:    fields,
@@ -307,15 +332,21 @@ func (l *Logger) postUnban(target *discordgo.User, exec *execInfo) {
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
-func (l *Logger) sendEmbed(embed *discordgo.MessageEmbed) {
-	if l.logChannelID == "" {
-		log.Println("[adminlog]", embed.Title)
+func (l *Logger) sendEmbed(guildID string, embed *discordgo.MessageEmbed) {
+	logChannelID := ""
+	if cfg := l.guilds.Get(guildID); cfg != nil {
+		logChannelID = cfg.LogChannelID
+	}
+	if logChannelID == "" {
+		l.log.Info(embed.Title, "guild", guildID)
 		return
 	}
-	_, _ = l.s.ChannelMessageSendEmbed(l.logChannelID, embed)
+	if _, err := l.s.ChannelMessageSendEmbed(logChannelID, embed); err != nil {
+		l.log.Warn("send log embed", "guild", guildID, "channel", logChannelID, "err", err)
+	}
 }
 
 // ----------------- Small utils -----------------
@@ -366,16 +397,16 @@ func avatarURL(u *discordgo.User) string {
 }
 
 // Удобный резолвер имён ролей (опционально).
-func ResolveRoleNames(s *discordgo.Session, guildID string, ids []string) []string {
+func ResolveRoleNames(s discord.Session, guildID string, ids []string) []string {
 	if len(ids) == 0 {
 		return []string{"—"}
 	}
-	g, err := s.State.Guild(guildID)
-	if err != nil || g == nil {
+	roles, err := discord.Roles(s, guildID)
+	if err != nil {
 		return ids
 	}
-	nameByID := make(map[string]string, len(g.Roles))
-	for _, r := range g.Roles {
+	nameByID := make(map[string]string, len(roles))
+	for _, r := range roles {
 		nameByID[r.ID] = "@" + r.Name
 	}
 	out := make([]string, 0, len(ids))
@@ -397,21 +428,22 @@ func bullet(items []string) string {
 }
 
 // PostMute — лог о выдаче мута (минуты > 0 — длительность; 0/отрицательное — "не задано").
-func (l *Logger) PostMute(target, moderator *discordgo.User, reason string, minutes int) {
-	extra := "не задано"
+func (l *Logger) PostMute(guildID string, target, moderator *discordgo.User, reason string, minutes int) {
+	lang := i18n.ForGuild(guildID)
+	extra := i18n.T(lang, "common.not_set")
 	if minutes > 0 {
-		extra = fmt.Sprintf("%d мин.", minutes)
+		extra = i18n.T(lang, "common.minutes", minutes)
 	}
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: userTag(target), Inline: true},
-		{Name: "Модератор", Value: formatExec(&execInfo{User: moderator}), Inline: true},
-		{Name: "Длительность", Value: code(extra), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(&execInfo{User: moderator}), Inline: true},
+		{Name: i18n.T(lang, "field.duration"), Value: code(extra), Inline: true},
 	}
 	if strings.TrimSpace(reason) != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
 	}
 	embed := &discordgo.MessageEmbed{
-		Title:     "⛔ Мут",
+		Title:     i18n.T(lang, "log.mute.title"),
 		Color:     0xE74C3C,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields:    fields,
@@ -419,20 +451,21 @@ func (l *Logger) PostMute(target, moderator *discordgo.User, reason string, minu
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
 }
 
 // PostUnmute — лог о снятии мута.
-func (l *Logger) PostUnmute(target, moderator *discordgo.User, reason string) {
+func (l *Logger) PostUnmute(guildID string, target, moderator *discordgo.User, reason string) {
+	lang := i18n.ForGuild(guildID)
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: userTag(target), Inline: true},
-		{Name: "Модератор", Value: formatExec(&execInfo{User: moderator}), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(&execInfo{User: moderator}), Inline: true},
 	}
 	if strings.TrimSpace(reason) != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
 	}
 	embed := &discordgo.MessageEmbed{
-		Title:     "♻️ Размут",
+		Title:     i18n.T(lang, "log.unmute.title"),
 		Color:     0x2ECC71,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
 		Fields:    fields,
@@ -440,6 +473,140 @@ func (l *Logger) PostUnmute(target, moderator *discordgo.User, reason string) {
 			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
 		},
 	}
-	l.sendEmbed(embed)
+	l.sendEmbed(guildID, embed)
+}
+
+// XPChange — ручное изменение XP одного участника (/xp add|remove|set|reset).
+type XPChange struct {
+	Action      string // add | remove | set | reset
+	XPBefore    int64
+	XPAfter     int64
+	LevelBefore int
+	LevelAfter  int
 }
 
+// PostXPChange — лог об изменении XP модератором: было → стало.
+func (l *Logger) PostXPChange(guildID string, target, moderator *discordgo.User, c XPChange, reason string) {
+	lang := i18n.ForGuild(guildID)
+	fields := []*discordgo.MessageEmbedField{
+		{Name: i18n.T(lang, "field.user"), Value: userTag(target), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(&execInfo{User: moderator}), Inline: true},
+		{Name: i18n.T(lang, "field.xp"), Value: code(fmt.Sprintf("%d → %d", c.XPBefore, c.XPAfter)), Inline: true},
+		{Name: i18n.T(lang, "field.level"), Value: code(fmt.Sprintf("%d → %d", c.LevelBefore, c.LevelAfter)), Inline: true},
+	}
+	if strings.TrimSpace(reason) != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:     i18n.T(lang, "log.xp.title", i18n.T(lang, "log.xp."+c.Action)),
+		Color:     0xF1C40F,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{URL: avatarURL(target)},
+		Fields:    fields,
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04")),
+		},
+	}
+	l.sendEmbed(guildID, embed)
+}
+
+// PostXPResetAll — лог об обнулении XP всего сервера: сколько участников и сколько XP было.
+func (l *Logger) PostXPResetAll(guildID string, moderator *discordgo.User, members int, xpBefore int64, reason string) {
+	lang := i18n.ForGuild(guildID)
+	fields := []*discordgo.MessageEmbedField{
+		{Name: i18n.T(lang, "field.moderator"), Value: formatExec(&execInfo{User: moderator}), Inline: true},
+		{Name: i18n.T(lang, "log.xp_reset_all.members"), Value: code(fmt.Sprint(members)), Inline: true},
+		{Name: i18n.T(lang, "field.xp"), Value: code(fmt.Sprintf("%d → 0", xpBefore)), Inline: true},
+	}
+	if strings.TrimSpace(reason) != "" {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:  i18n.T(lang, "log.xp_reset_all.title"),
+		Color:  0xE74C3C,
+		Fields: fields,
+		Footer: &discordgo.MessageEmbedFooter{Text: time.Now().Format("02.01.2006 15:04")},
+	}
+	l.sendEmbed(guildID, embed)
+}
+
+// XPDecay — итог одного прогона затухания XP на сервере.
+type XPDecay struct {
+	Percent    float64
+	AfterDays  int
+	FloorLevel int
+	Members    []XPDecayMember // по убыванию потерянного XP
+}
+
+// XPDecayMember — участник, у которого списан XP за неактивность.
+type XPDecayMember struct {
+	UserID      string
+	XPBefore    int64
+	XPAfter     int64
+	LevelBefore int
+	LevelAfter  int
+}
+
+// сколько участников перечислять в логе затухания поимённо
+const decayListLimit = 15
+
+// PostXPDecay — лог о затухании XP: правило, сколько участников и XP, самые большие потери.
+func (l *Logger) PostXPDecay(guildID string, d XPDecay) {
+	lang := i18n.ForGuild(guildID)
+	var lost int64
+	var b strings.Builder
+	for i, m := range d.Members {
+		lost += m.XPBefore - m.XPAfter
+		if i < decayListLimit {
+			b.WriteString(i18n.T(lang, "log.xp_decay.line", m.UserID, m.XPBefore, m.XPAfter, m.LevelBefore, m.LevelAfter) + "\n")
+		}
+	}
+	if n := len(d.Members) - decayListLimit; n > 0 {
+		b.WriteString(i18n.T(lang, "log.xp_decay.more", n))
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:       i18n.T(lang, "log.xp_decay.title"),
+		Description: b.String(),
+		Color:       0xE67E22,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: i18n.T(lang, "log.xp_decay.policy"), Value: i18n.T(lang, "log.xp_decay.policy_value", d.Percent, d.AfterDays, d.FloorLevel)},
+			{Name: i18n.T(lang, "log.xp_decay.members"), Value: code(fmt.Sprint(len(d.Members))), Inline: true},
+			{Name: i18n.T(lang, "field.xp"), Value: code(fmt.Sprintf("-%d", lost)), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{Text: time.Now().Format("02.01.2006 15:04")},
+	}
+	l.sendEmbed(guildID, embed)
+}
+
+// TierSync — итог /levelroles sync или плановой сверки ролей за уровни.
+type TierSync struct {
+	Checked int // проверено участников
+	Fixed   int // у скольких поправлены роли
+	Added   int
+	Removed int
+	Failed  int // изменений, которые Discord не принял
+}
+
+// PostTierSync — лог о сверке ролей за уровни; moderator == nil — плановая сверка.
+func (l *Logger) PostTierSync(guildID string, moderator *discordgo.User, t TierSync) {
+	lang := i18n.ForGuild(guildID)
+	who := i18n.T(lang, "log.tier_sync.auto")
+	if moderator != nil {
+		who = formatExec(&execInfo{User: moderator})
+	}
+	fields := []*discordgo.MessageEmbedField{
+		{Name: i18n.T(lang, "field.moderator"), Value: who, Inline: true},
+		{Name: i18n.T(lang, "log.tier_sync.checked"), Value: code(fmt.Sprint(t.Checked)), Inline: true},
+		{Name: i18n.T(lang, "log.tier_sync.fixed"), Value: code(fmt.Sprint(t.Fixed)), Inline: true},
+		{Name: i18n.T(lang, "log.tier_sync.roles"), Value: code(fmt.Sprintf("+%d / -%d", t.Added, t.Removed)), Inline: true},
+	}
+	if t.Failed > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "log.tier_sync.failed"), Value: code(fmt.Sprint(t.Failed)), Inline: true})
+	}
+	embed := &discordgo.MessageEmbed{
+		Title:  i18n.T(lang, "log.tier_sync.title"),
+		Color:  0x3498DB,
+		Fields: fields,
+		Footer: &discordgo.MessageEmbedFooter{Text: time.Now().Format("02.01.2006 15:04")},
+	}
+	l.sendEmbed(guildID, embed)
+}
@@ -1,24 +1,36 @@
 package clear
 
 import (
-	"log"
+	"log/slog"
 	"reflect"
-	"strconv"
 	"time"
 
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
 	"github.com/bwmarrin/discordgo"
 )
 
 const CommandName = "clear"
 const maxBulk = 100 // лимит Discord на bulk delete
 
-// Register — регистрирует slash-команду /clear на сервере.
+// пауза между одиночными удалениями (rate limit); в тестах обнуляется
+var deleteDelay = 350 * time.Millisecond
+
+// журнал модуля; задаётся в Register
+var logger = logging.Discard()
+
+// Register — объявляет slash-команду /clear в роутере.
 // Работает с разными версиями discordgo (MinValue/MaxValue: float64 ИЛИ *float64).
-func Register(s *discordgo.Session, guildID string) (*discordgo.ApplicationCommand, error) {
+func Register(router *commands.Router, log *slog.Logger) {
+	logger = log
+
 	var manageMessagesPerm int64 = discordgo.PermissionManageMessages
 	dm := false
 
-    //создаем опцию команды (аргумент /clear)
+	//создаем опцию команды (аргумент /clear)
 	opt := &discordgo.ApplicationCommandOption{
 		Type:        discordgo.ApplicationCommandOptionInteger,
 		Name:        "count",
@@ -27,65 +39,65 @@ func Register(s *discordgo.Session, guildID string) (*discordgo.ApplicationComma
 	}
 	setMinMax(opt, 1, 100)
 
-	cmd := &discordgo.ApplicationCommand{
-		Name:        CommandName,
-		Description: "Удалить последние N сообщений в этом канале",
+	router.Add(&discordgo.ApplicationCommand{
+		Name:                     CommandName,
+		Description:              "Удалить последние N сообщений в этом канале",
 		DefaultMemberPermissions: &manageMessagesPerm,
 		DMPermission:             &dm,
 		Options:                  []*discordgo.ApplicationCommandOption{opt},
-	}
-	return s.ApplicationCommandCreate(s.State.User.ID, guildID, cmd)
+	}, handle)
 }
 
-// AddHandler — обработчик выполнения команды
-func AddHandler(s *discordgo.Session) {
-	s.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
-		if i.Type != discordgo.InteractionApplicationCommand || i.ApplicationCommandData().Name != CommandName {
-			return
-		}
+// handle — обработчик выполнения команды
+func handle(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.Type != discordgo.InteractionApplicationCommand {
+		return
+	}
 
-		channelID := i.ChannelID
-		count := int(i.ApplicationCommandData().Options[0].IntValue())
-		if count < 1 {
-			count = 1
-		}
-		if count > maxBulk {
-			count = maxBulk
-		}
+	lang := i18n.For(i.Interaction)
+	channelID := i.ChannelID
+	count := int(i.ApplicationCommandData().Options[0].IntValue())
+	if count < 1 {
+		count = 1
+	}
+	if count > maxBulk {
+		count = maxBulk
+	}
 
-		deleted, err := deleteLastMessages(s, channelID, count)
-		if err != nil {
-			log.Println("[clear] delete error:", err)
-			_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-				Type: discordgo.InteractionResponseChannelMessageWithSource,
-				Data: &discordgo.InteractionResponseData{
-					Flags:   discordgo.MessageFlagsEphemeral,
-					Content: "Не удалось удалить сообщения: " + err.Error(),
-				},
-			})
-			return
-		}
+	deleted, err := deleteLastMessages(s, channelID, count)
+	if err != nil {
+		logging.Interaction(logger, i).Error("delete messages", "channel", channelID, "deleted", deleted, "err", err)
+		respond(s, i, i18n.T(lang, "clear.failed", err))
+		return
+	}
 
-		msg := "Удалено сообщений: " + strconv.Itoa(deleted)
-		if deleted == 1 {
-			msg = "Удалено 1 сообщение."
-		} else if deleted == 0 {
-			msg = "Ничего не удалено."
-		}
+	msg := i18n.T(lang, "clear.deleted", deleted)
+	if deleted == 1 {
+		msg = i18n.T(lang, "clear.deleted_one")
+	} else if deleted == 0 {
+		msg = i18n.T(lang, "clear.deleted_none")
+	}
 
-		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Flags:   discordgo.MessageFlagsEphemeral,
-				Content: msg,
-			},
-		})
+	logging.Interaction(logger, i).Info("messages deleted", "channel", channelID, "requested", count, "deleted", deleted)
+	respond(s, i, msg)
+}
+
+func respond(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: content,
+		},
 	})
+	if err != nil {
+		logging.Interaction(logger, i).Warn("respond", "err", err)
+	}
 }
 
 // deleteLastMessages — удаляет N последних сообщений.
 // Новые (моложе 14 дней) пробуем удалить пачкой; остальные — по одному.
-func deleteLastMessages(s *discordgo.Session, channelID string, n int) (int, error) {
+func deleteLastMessages(s discord.Session, channelID string, n int) (int, error) {
 	msgs, err := s.ChannelMessages(channelID, n, "", "", "")
 	if err != nil {
 		return 0, err
@@ -111,14 +123,14 @@ func deleteLastMessages(s *discordgo.Session, channelID string, n int) (int, err
 			for _, id := range bulkIDs {
 				if err := s.ChannelMessageDelete(channelID, id); err == nil {
 					deleted++
-					time.Sleep(350 * time.Millisecond)
+					time.Sleep(deleteDelay)
 				}
 			}
 		}
 	} else if len(bulkIDs) == 1 {
 		if err := s.ChannelMessageDelete(channelID, bulkIDs[0]); err == nil {
 			deleted++
-			time.Sleep(350 * time.Millisecond)
+			time.Sleep(deleteDelay)
 		}
 	}
 
@@ -126,7 +138,7 @@ func deleteLastMessages(s *discordgo.Session, channelID string, n int) (int, err
 	for _, id := range oldIDs {
 		if err := s.ChannelMessageDelete(channelID, id); err == nil {
 			deleted++
-			time.Sleep(350 * time.Millisecond)
+			time.Sleep(deleteDelay)
 		}
 	}
 
@@ -161,7 +173,3 @@ func setMinMax(opt *discordgo.ApplicationCommandOption, min, max float64) {
 		}
 	}
 }
-
-func SetMinMaxRange(opt *discordgo.ApplicationCommandOption, min, max float64) {
-	setMinMax(opt, min, max)
-}
@@ -0,0 +1,75 @@
+package clear
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gosha_bot/discord/discordtest"
+)
+
+func init() { deleteDelay = 0 }
+
+func TestDeleteLastMessagesBulkAndOld(t *testing.T) {
+	fake := discordtest.New("bot")
+	keep := fake.AddMessage("c", time.Now().Add(-time.Hour))
+	fake.AddMessage("c", time.Now().Add(-20*24*time.Hour)) // старше 14 дней — только по одному
+	fake.AddMessage("c", time.Now().Add(-time.Minute))
+	fake.AddMessage("c", time.Now())
+
+	n, err := deleteLastMessages(fake, "c", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Fatalf("deleted %d, want 3", n)
+	}
+	left := fake.Messages("c")
+	if len(left) != 1 || left[0].ID != keep.ID {
+		t.Fatalf("left %v, want only %s", left, keep.ID)
+	}
+	if fake.CallCount("ChannelMessagesBulkDelete") != 1 {
+		t.Fatal("recent messages must go through bulk delete")
+	}
+	if fake.CallCount("ChannelMessageDelete") != 1 {
+		t.Fatal("old message must be deleted individually")
+	}
+}
+
+func TestDeleteLastMessagesSingleRecent(t *testing.T) {
+	fake := discordtest.New("bot")
+	fake.AddMessage("c", time.Now())
+
+	n, err := deleteLastMessages(fake, "c", 5)
+	if err != nil || n != 1 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if fake.CallCount("ChannelMessagesBulkDelete") != 0 {
+		t.Fatal("bulk delete is not allowed for a single message")
+	}
+}
+
+func TestDeleteLastMessagesFallsBackWhenBulkFails(t *testing.T) {
+	fake := discordtest.New("bot")
+	for i := 0; i < 4; i++ {
+		fake.AddMessage("c", time.Now())
+	}
+	fake.Fail("ChannelMessagesBulkDelete", "c", errors.New("rate limited"))
+
+	n, err := deleteLastMessages(fake, "c", 4)
+	if err != nil || n != 4 {
+		t.Fatalf("n=%d err=%v", n, err)
+	}
+	if len(fake.Messages("c")) != 0 {
+		t.Fatal("all messages must be deleted one by one")
+	}
+}
+
+func TestDeleteLastMessagesFetchError(t *testing.T) {
+	fake := discordtest.New("bot")
+	fake.Fail("ChannelMessages", "c", errors.New("no access"))
+
+	if _, err := deleteLastMessages(fake, "c", 10); err == nil {
+		t.Fatal("expected error")
+	}
+}
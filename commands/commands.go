@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+	"gosha_bot/metrics"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Handler — обработчик интеракции (slash-команды, подкоманды, кнопки).
+type Handler func(s *discordgo.Session, ic *discordgo.InteractionCreate)
+
+type command struct {
+	def     *discordgo.ApplicationCommand
+	handler Handler
+	subs    map[string]Handler // "sub" или "group/sub"
+}
+
+// Router — единый реестр slash-команд и диспетчер интеракций.
+// Модули объявляют команду + хендлер через Add/AddSub/Component,
+// а main синхронизирует список на каждом сервере через Sync (bulk overwrite).
+type Router struct {
+	mu         sync.RWMutex
+	order      []string
+	cmds       map[string]*command
+	components map[string]Handler // custom_id или префикс custom_id (до ':')
+	log        *slog.Logger
+
+	closed   bool           // после Shutdown новые интеракции не принимаем
+	inflight sync.WaitGroup // хендлеры, которые ещё работают
+}
+
+func New(log *slog.Logger) *Router {
+	return &Router{
+		cmds:       make(map[string]*command),
+		components: make(map[string]Handler),
+		log:        log,
+	}
+}
+
+// Add объявляет команду. h вызывается, если для подкоманды нет отдельного хендлера (может быть nil).
+// Повторная регистрация того же имени — ошибка программиста, паникуем сразу на старте.
+// Переводы имени/описаний подставляются из каталогов i18n (ключи cmd.<имя>.*).
+func (r *Router) Add(def *discordgo.ApplicationCommand, h Handler) {
+	i18n.Localize(def)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.cmds[def.Name]; dup {
+		panic(fmt.Sprintf("commands: /%s registered twice", def.Name))
+	}
+	r.cmds[def.Name] = &command{def: def, handler: h, subs: make(map[string]Handler)}
+	r.order = append(r.order, def.Name)
+}
+
+// AddSub вешает отдельный хендлер на подкоманду уже объявленной команды.
+// sub — "имя" или "группа/имя".
+func (r *Router) AddSub(name, sub string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, ok := r.cmds[name]
+	if !ok {
+		panic(fmt.Sprintf("commands: /%s is not registered (sub %q)", name, sub))
+	}
+	c.subs[sub] = h
+}
+
+// Component вешает хендлер на custom_id кнопки/селекта/модалки.
+// Совпадение точное, либо по префиксу до первого ':' ("xp:page:2" → "xp:page").
+func (r *Router) Component(customID string, h Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.components[customID]; dup {
+		panic(fmt.Sprintf("commands: component %q registered twice", customID))
+	}
+	r.components[customID] = h
+}
+
+// Commands — определения всех команд в порядке регистрации.
+func (r *Router) Commands() []*discordgo.ApplicationCommand {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*discordgo.ApplicationCommand, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.cmds[name].def)
+	}
+	return out
+}
+
+// Attach подключает диспетчер к сессии. Вызывать один раз.
+func (r *Router) Attach(s *discordgo.Session) {
+	s.AddHandler(r.dispatch)
+}
+
+// Sync перезаписывает набор команд сервера целиком (bulk overwrite):
+// команды, которых больше нет в реестре, пропадают из гильдии.
+func (r *Router) Sync(s *discordgo.Session, guildID string) error {
+	cmds := r.Commands()
+	if _, err := s.ApplicationCommandBulkOverwrite(s.State.User.ID, guildID, cmds); err != nil {
+		return fmt.Errorf("bulk overwrite %s: %w", guildID, err)
+	}
+	r.log.Info("commands synced", "guild", guildID, "count", len(cmds))
+	return nil
+}
+
+func (r *Router) dispatch(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	h, label := r.lookup(ic)
+	if h == nil {
+		metrics.ObserveCommand(label, metrics.OutcomeUnknown, 0)
+		logging.Interaction(r.log, ic).Warn("no handler for interaction")
+		return
+	}
+
+	r.mu.RLock()
+	closed := r.closed
+	if !closed {
+		r.inflight.Add(1)
+	}
+	r.mu.RUnlock()
+	if closed {
+		r.rejectShuttingDown(s, ic)
+		return
+	}
+	defer r.inflight.Done()
+
+	start := time.Now()
+	outcome := metrics.OutcomePanic
+	defer func() {
+		d := time.Since(start)
+		metrics.ObserveCommand(label, outcome, d)
+		if p := recover(); p != nil {
+			logging.Interaction(r.log, ic).Error("handler panicked", "panic", fmt.Sprint(p), "duration", d)
+			return
+		}
+		logging.Interaction(r.log, ic).Debug("interaction handled", "duration", d)
+	}()
+	h(s, ic)
+	outcome = metrics.OutcomeOK
+}
+
+// Shutdown перестаёт принимать интеракции и ждёт уже запущенные хендлеры (не дольше ctx).
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("interactions still running: %w", ctx.Err())
+	}
+}
+
+// во время остановки честно отвечаем, а не оставляем "приложение не отвечает"
+func (r *Router) rejectShuttingDown(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if s == nil || ic.Type == discordgo.InteractionApplicationCommandAutocomplete {
+		return
+	}
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: i18n.T(i18n.For(ic.Interaction), "common.shutting_down"),
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("shutdown reply failed", "err", err)
+	}
+}
+
+// lookup — хендлер и метка для метрик: имя команды или зарегистрированный custom_id компонента.
+func (r *Router) lookup(ic *discordgo.InteractionCreate) (Handler, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	switch ic.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		data := ic.ApplicationCommandData()
+		c, ok := r.cmds[data.Name]
+		if !ok {
+			return nil, "unknown"
+		}
+		if sub := SubcommandPath(data.Options); sub != "" {
+			if h, ok := c.subs[sub]; ok {
+				return h, data.Name
+			}
+		}
+		return c.handler, data.Name
+
+	case discordgo.InteractionMessageComponent:
+		return r.component(ic.MessageComponentData().CustomID)
+
+	case discordgo.InteractionModalSubmit:
+		return r.component(ic.ModalSubmitData().CustomID)
+	}
+	return nil, "unknown"
+}
+
+func (r *Router) component(customID string) (Handler, string) {
+	if h, ok := r.components[customID]; ok {
+		return h, customID
+	}
+	// самый длинный зарегистрированный префикс по границе ':'
+	for id := customID; ; {
+		i := strings.LastIndexByte(id, ':')
+		if i <= 0 {
+			return nil, "unknown"
+		}
+		id = id[:i]
+		if h, ok := r.components[id]; ok {
+			return h, id
+		}
+	}
+}
+
+// SubcommandPath — "sub" или "group/sub" для вызванной подкоманды, "" если её нет.
+func SubcommandPath(opts []*discordgo.ApplicationCommandInteractionDataOption) string {
+	if len(opts) == 0 {
+		return ""
+	}
+	o := opts[0]
+	switch o.Type {
+	case discordgo.ApplicationCommandOptionSubCommand:
+		return o.Name
+	case discordgo.ApplicationCommandOptionSubCommandGroup:
+		if len(o.Options) > 0 {
+			return o.Name + "/" + o.Options[0].Name
+		}
+		return o.Name
+	}
+	return ""
+}
+
+// Options — опции вызванной (под)команды по имени, без учёта вложенности.
+func Options(ic *discordgo.InteractionCreate) map[string]*discordgo.ApplicationCommandInteractionDataOption {
+	opts := ic.ApplicationCommandData().Options
+	for len(opts) == 1 && (opts[0].Type == discordgo.ApplicationCommandOptionSubCommand ||
+		opts[0].Type == discordgo.ApplicationCommandOptionSubCommandGroup) {
+		opts = opts[0].Options
+	}
+	out := make(map[string]*discordgo.ApplicationCommandInteractionDataOption, len(opts))
+	for _, o := range opts {
+		out[o.Name] = o
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func slash(name string, opts ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{Name: name, Options: opts},
+	}}
+}
+
+func button(customID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionMessageComponent,
+		Data: discordgo.MessageComponentInteractionData{CustomID: customID},
+	}}
+}
+
+func TestDispatchRoutesSubcommandsAndComponents(t *testing.T) {
+	r := New(logging.Discard())
+	var got string
+	r.Add(&discordgo.ApplicationCommand{Name: "xp"}, func(*discordgo.Session, *discordgo.InteractionCreate) { got = "xp" })
+	r.AddSub("xp", "add", func(*discordgo.Session, *discordgo.InteractionCreate) { got = "xp add" })
+	r.Component("xp:page", func(*discordgo.Session, *discordgo.InteractionCreate) { got = "page" })
+
+	cases := []struct {
+		ic        *discordgo.InteractionCreate
+		want, lbl string
+	}{
+		{slash("xp"), "xp", "xp"},
+		{slash("xp", &discordgo.ApplicationCommandInteractionDataOption{Name: "add", Type: discordgo.ApplicationCommandOptionSubCommand}), "xp add", "xp"},
+		{slash("xp", &discordgo.ApplicationCommandInteractionDataOption{Name: "set", Type: discordgo.ApplicationCommandOptionSubCommand}), "xp", "xp"},
+		{button("xp:page:3:u1"), "page", "xp:page"},
+	}
+	for _, tc := range cases {
+		got = ""
+		h, lbl := r.lookup(tc.ic)
+		if h == nil {
+			t.Fatalf("%s: no handler", tc.want)
+		}
+		h(nil, tc.ic)
+		if got != tc.want || lbl != tc.lbl {
+			t.Errorf("got %q/%q, want %q/%q", got, lbl, tc.want, tc.lbl)
+		}
+	}
+
+	if h, lbl := r.lookup(button("other:1")); h != nil || lbl != "unknown" {
+		t.Errorf("unknown component routed: %q", lbl)
+	}
+	if h, _ := r.lookup(slash("nope")); h != nil {
+		t.Error("unknown command routed")
+	}
+}
+
+func TestDispatchRecoversPanics(t *testing.T) {
+	r := New(logging.Discard())
+	r.Add(&discordgo.ApplicationCommand{Name: "boom"}, func(*discordgo.Session, *discordgo.InteractionCreate) { panic("oops") })
+
+	r.dispatch(nil, slash("boom")) // не должно уронить процесс
+}
+
+func TestShutdownWaitsForRunningHandlers(t *testing.T) {
+	r := New(logging.Discard())
+	started, release := make(chan struct{}), make(chan struct{})
+	calls := 0
+	r.Add(&discordgo.ApplicationCommand{Name: "slow"}, func(*discordgo.Session, *discordgo.InteractionCreate) {
+		calls++
+		close(started)
+		<-release
+	})
+
+	go r.dispatch(nil, slash("slow"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); err == nil {
+		t.Fatal("shutdown must time out while handler is running")
+	}
+
+	close(release)
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r.dispatch(nil, slash("slow")) // после остановки хендлер не вызывается
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
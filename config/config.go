@@ -0,0 +1,293 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config — всё, что раньше было захардкожено в коде или размазано по env:
+// ID ролей и каналов, тарифы XP, включённые модули.
+// Секреты (токен, DSN) можно не класть в файл — их перекрывают переменные окружения.
+type Config struct {
+	Discord  Discord  `yaml:"discord"`
+	Database Database `yaml:"database"`
+
+	AdminRoleIDs     []string `yaml:"admin_role_ids"`
+	ProtectedRoleIDs []string `yaml:"protected_role_ids"`
+
+	Modules Modules `yaml:"modules"`
+	XP      XP      `yaml:"xp"`
+	Levels  Levels  `yaml:"levels"`
+	Mute    Mute    `yaml:"mute"`
+	Give    Give    `yaml:"give"`
+	Metrics Metrics `yaml:"metrics"`
+	Log     Log     `yaml:"log"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // общий дедлайн на остановку по SIGTERM
+
+	Guilds []Guild `yaml:"guilds"`
+}
+
+type Discord struct {
+	Token string `yaml:"token"` // env DISCORD_TOKEN
+}
+
+type Database struct {
+	DSN string `yaml:"dsn"` // env POSTGRES_DSN; пусто — без БД
+}
+
+// Modules — какие модули поднимать. По умолчанию включены все.
+type Modules struct {
+	Level    bool `yaml:"level"`
+	Mute     bool `yaml:"mute"`
+	Give     bool `yaml:"give"`
+	Remove   bool `yaml:"remove"`
+	Top      bool `yaml:"top"`
+	Clear    bool `yaml:"clear"`
+	SelfRole bool `yaml:"selfrole"`
+	AdminLog bool `yaml:"adminlog"`
+}
+
+// XP — тарифы начисления опыта.
+type XP struct {
+	MessageAward    int64         `yaml:"message_award"`     // XP за сообщение
+	MessageAwardMax int64         `yaml:"message_award_max"` // >0 — случайно от message_award до message_award_max
+	MessageCooldown time.Duration `yaml:"message_cooldown"`  // не чаще, чем раз в …
+	VoicePerHour    float64       `yaml:"voice_per_hour"`    // XP за час в войсе (пропорционально)
+
+	// анти-спам в чате: сообщения, не прошедшие правила, XP не дают и кулдаун не трогают
+	MessageMinLength           int     `yaml:"message_min_length"`           // минимум символов текста (без ссылок, эмодзи и пунктуации)
+	MessageMinWords            int     `yaml:"message_min_words"`            // минимум слов
+	MessageIgnoreEmojiLinks    bool    `yaml:"message_ignore_emoji_links"`   // только эмодзи, ссылки и упоминания — без XP
+	MessageDuplicateSimilarity float64 `yaml:"message_duplicate_similarity"` // похожесть на недавние сообщения автора, с которой это повтор (0 — выкл)
+
+	// анти-фарм в войсе: глухие (сами или сервером) XP не получают никогда
+	VoiceMinHumans   int  `yaml:"voice_min_humans"`    // XP капает, только если в канале не меньше N людей (не ботов и не глухих)
+	VoiceNoSelfMuted bool `yaml:"voice_no_self_muted"` // true — с выключенным микрофоном XP тоже не капает
+
+	// сколько времени простоя бота засчитать тем, кто так и сидел в войсе (0 — не засчитывать и не хранить сессии)
+	VoiceDowntimeCredit time.Duration `yaml:"voice_downtime_credit"`
+
+	// как часто сверять роли за уровни всех участников с их уровнями и исправлять расхождения (0 — только /levelroles sync)
+	TierSyncInterval time.Duration `yaml:"tier_sync_interval"`
+}
+
+// Levels — кривая уровней (XP → уровень), общая для всех модулей.
+type Levels struct {
+	Curve  string  `yaml:"curve"`  // quadratic | exponential | mee6 | table
+	Factor float64 `yaml:"factor"` // quadratic: threshold(L) = factor*L²
+	Base   float64 `yaml:"base"`   // exponential: цена перехода 1→2
+	Growth float64 `yaml:"growth"` // exponential: во сколько раз дорожает каждый следующий уровень
+	Table  []int64 `yaml:"table"`  // table: XP для уровней 2, 3, 4, …
+}
+
+// Кривые для levels.curve.
+const (
+	CurveQuadratic   = "quadratic"
+	CurveExponential = "exponential"
+	CurveMEE6        = "mee6"
+	CurveTable       = "table"
+)
+
+type Mute struct {
+	RetentionDays int `yaml:"retention_days"` // сколько дней хранить завершённые муты
+}
+
+// Give — сколько XP снимают роли-наказания (сами роли — в guilds[].penalty_roles).
+type Give struct {
+	Warn1XP int64 `yaml:"warn1_xp"`
+	Warn2XP int64 `yaml:"warn2_xp"`
+}
+
+// Metrics — HTTP-листенер Prometheus (/metrics). Пустой listen — выключено.
+type Metrics struct {
+	Listen string `yaml:"listen"` // например ":9090" или "127.0.0.1:9090"
+}
+
+// Log — журнал бота (log/slog). Уровни: debug, info, warn, error.
+type Log struct {
+	Level   string            `yaml:"level"`   // общий уровень; env LOG_LEVEL
+	Format  string            `yaml:"format"`  // "text" (по умолчанию) | "json"; env LOG_FORMAT
+	Modules map[string]string `yaml:"modules"` // уровень отдельных модулей: {mute: debug, level: warn}
+}
+
+// LogModules — имена модулей для log.modules.
+var LogModules = []string{
+	"main", "commands", "migrate", "metrics", "std",
+	"level", "mute", "give", "remove", "top", "clear", "selfrole", "adminlog",
+}
+
+// Guild — настройки одного сервера. Непустые поля при старте записываются в guild_settings.
+type Guild struct {
+	ID                string       `yaml:"id"`
+	MuteRoleID        string       `yaml:"mute_role_id"`
+	LogChannelID      string       `yaml:"log_channel_id"`
+	KeepCategoryID    string       `yaml:"keep_category_id"`
+	WelcomeChannelID  string       `yaml:"welcome_channel_id"`
+	SelfRoleID        string       `yaml:"self_role_id"`
+	AfkChannelID      string       `yaml:"afk_channel_id"`
+	AnnounceChannelID string       `yaml:"announce_channel_id"` // объявления: XP-бусты
+	Locale            string       `yaml:"locale"`              // "ru" | "en"; пусто — по локали пользователя
+	RoleStacking      string       `yaml:"role_stacking"`       // max | multiply: как складываются множители ролей
+	LevelUp           LevelUp      `yaml:"levelup"`             // объявления о новом уровне
+	XPDecay           XPDecay      `yaml:"xp_decay"`            // затухание XP неактивных участников
+	TierRoles         TierRoles    `yaml:"tier_roles"`
+	TierMode          string       `yaml:"tier_mode"`    // replace | stack | stack_except: копятся ли роли за уровни
+	TierExclude       []string     `yaml:"tier_exclude"` // для stack_except: роли-ступени, которые не копятся
+	PenaltyRoles      PenaltyRoles `yaml:"penalty_roles"`
+}
+
+type TierRoles struct {
+	L1to24   string `yaml:"l1_24"`
+	L25to49  string `yaml:"l25_49"`
+	L50to74  string `yaml:"l50_74"`
+	L75to99  string `yaml:"l75_99"`
+	L100Plus string `yaml:"l100_plus"`
+}
+
+// LevelUp — объявления о новом уровне. Плейсхолдеры шаблонов: {user}, {level}, {role}, {xp}.
+type LevelUp struct {
+	Mode              string `yaml:"mode"`               // off | channel | same | dm
+	ChannelID         string `yaml:"channel_id"`         // для channel; пусто — announce_channel_id
+	Style             string `yaml:"style"`              // text | embed
+	Template          string `yaml:"template"`           // пусто — стандартный текст
+	MilestoneTemplate string `yaml:"milestone_template"` // уровень с новой ролью-ступенью
+}
+
+// XPDecay — раз в неделю снимать percent% XP у тех, кто after_days дней не писал и не сидел в войсе.
+type XPDecay struct {
+	Percent    float64 `yaml:"percent"`     // 0 — выключено
+	AfterDays  int     `yaml:"after_days"`  // сколько дней без активности до первого списания
+	FloorLevel int     `yaml:"floor_level"` // ниже этого уровня не опускать
+}
+
+type PenaltyRoles struct {
+	Warn1 string `yaml:"warn1"` // снять give.warn1_xp
+	Warn2 string `yaml:"warn2"` // снять give.warn2_xp и убрать warn1
+	Kick  string `yaml:"kick"`  // обнулить XP и кикнуть
+}
+
+// Default — значения, с которыми бот работал до появления конфиг-файла.
+func Default() Config {
+	return Config{
+		Modules: Modules{
+			Level: true, Mute: true, Give: true, Remove: true,
+			Top: true, Clear: true, SelfRole: true, AdminLog: true,
+		},
+		XP: XP{
+			MessageAward:    1,
+			MessageCooldown: time.Minute,
+			VoicePerHour:    100,
+			VoiceMinHumans:  2,
+
+			MessageMinLength:           3,
+			MessageMinWords:            1,
+			MessageIgnoreEmojiLinks:    true,
+			MessageDuplicateSimilarity: 0.9,
+		},
+		Levels: Levels{Curve: CurveQuadratic, Factor: 10},
+		Mute:   Mute{RetentionDays: 3},
+		Log:    Log{Level: "info", Format: "text"},
+		Give:   Give{Warn1XP: 1000, Warn2XP: 1500},
+
+		ShutdownTimeout: 15 * time.Second,
+	}
+}
+
+// Load читает YAML-файл поверх Default, применяет переменные окружения и валидирует результат.
+// Если path пустой или файла нет, а required == false, — работаем на дефолтах и env.
+func Load(path string, required bool) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			dec := yaml.NewDecoder(bytes.NewReader(raw))
+			dec.KnownFields(true) // опечатка в ключе — ошибка, а не тихий дефолт
+			if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+				return Config{}, fmt.Errorf("%s: %w", path, err)
+			}
+		case errors.Is(err, fs.ErrNotExist) && !required:
+			// файла нет — ок
+		default:
+			return Config{}, fmt.Errorf("config: %w", err)
+		}
+	}
+
+	cfg.applyEnv()
+
+	if err := cfg.Validate(); err != nil {
+		if path != "" {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// applyEnv — секреты и старые переменные из .env перекрывают файл.
+func (c *Config) applyEnv() {
+	if v := os.Getenv("DISCORD_TOKEN"); v != "" {
+		c.Discord.Token = v
+	}
+	if v := os.Getenv("POSTGRES_DSN"); v != "" {
+		c.Database.DSN = v
+	}
+	envStr(&c.Log.Level, "LOG_LEVEL")
+	envStr(&c.Log.Format, "LOG_FORMAT")
+	if v := splitIDs(os.Getenv("ADMIN_ROLE_IDS")); len(v) > 0 {
+		c.AdminRoleIDs = v
+	}
+	if v := splitIDs(os.Getenv("PROTECTED_ROLE_IDS")); len(v) > 0 {
+		c.ProtectedRoleIDs = v
+	}
+
+	// старый режим с одним GUILD_ID: env дополняет (или создаёт) запись этого сервера
+	guildID := strings.TrimSpace(os.Getenv("GUILD_ID"))
+	if guildID == "" {
+		return
+	}
+	var g *Guild
+	for i := range c.Guilds {
+		if c.Guilds[i].ID == guildID {
+			g = &c.Guilds[i]
+			break
+		}
+	}
+	if g == nil {
+		c.Guilds = append(c.Guilds, Guild{ID: guildID})
+		g = &c.Guilds[len(c.Guilds)-1]
+	}
+	envStr(&g.MuteRoleID, "MUTE_ROLE_ID")
+	envStr(&g.LogChannelID, "ADMIN_LOG_CHANNEL_ID")
+	envStr(&g.KeepCategoryID, "KEEP_CATEGORY_ID")
+	envStr(&g.WelcomeChannelID, "WELCOME_CHANNEL_ID")
+	envStr(&g.SelfRoleID, "SELF_ROLE_ID")
+}
+
+func envStr(dst *string, key string) {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		*dst = v
+	}
+}
+
+// splitIDs — "id1,id2 id3;id4" → []string.
+func splitIDs(v string) []string {
+	parts := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
+	out := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// чистим env, который Load читает, чтобы тесты не зависели от окружения
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, k := range []string{
+		"DISCORD_TOKEN", "POSTGRES_DSN", "ADMIN_ROLE_IDS", "PROTECTED_ROLE_IDS", "GUILD_ID",
+		"MUTE_ROLE_ID", "ADMIN_LOG_CHANNEL_ID", "KEEP_CATEGORY_ID", "WELCOME_CHANNEL_ID", "SELF_ROLE_ID",
+	} {
+		t.Setenv(k, "")
+	}
+}
+
+func writeFile(t *testing.T, body string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestExampleConfigIsValid(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DISCORD_TOKEN", "secret")
+
+	cfg, err := Load("../config.example.yaml", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Guilds) != 1 || cfg.Guilds[0].TierRoles.L25to49 != "1401993388345262133" {
+		t.Fatalf("guilds = %+v", cfg.Guilds)
+	}
+	if cfg.XP.MessageCooldown != time.Minute || cfg.XP.VoicePerHour != 100 {
+		t.Fatalf("xp = %+v", cfg.XP)
+	}
+}
+
+func TestMissingOptionalFileUsesDefaults(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DISCORD_TOKEN", "secret")
+
+	cfg, err := Load(filepath.Join(t.TempDir(), "nope.yaml"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Modules.Level || cfg.Give.Warn1XP != 1000 || cfg.Mute.RetentionDays != 3 {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+}
+
+func TestMissingRequiredFile(t *testing.T) {
+	clearEnv(t)
+	if _, err := Load(filepath.Join(t.TempDir(), "nope.yaml"), true); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestEnvOverridesSecretsAndLegacyGuild(t *testing.T) {
+	clearEnv(t)
+	p := writeFile(t, `
+discord:
+  token: from-file
+guilds:
+  - id: "111111111111111111"
+    mute_role_id: "222222222222222222"
+    afk_channel_id: "333333333333333333"
+`)
+	t.Setenv("DISCORD_TOKEN", "from-env")
+	t.Setenv("ADMIN_ROLE_IDS", "444444444444444444, 555555555555555555")
+	t.Setenv("GUILD_ID", "111111111111111111")
+	t.Setenv("MUTE_ROLE_ID", "666666666666666666")
+
+	cfg, err := Load(p, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Discord.Token != "from-env" {
+		t.Errorf("token = %q", cfg.Discord.Token)
+	}
+	if len(cfg.AdminRoleIDs) != 2 {
+		t.Errorf("admin roles = %v", cfg.AdminRoleIDs)
+	}
+	if len(cfg.Guilds) != 1 {
+		t.Fatalf("legacy guild must merge into existing entry, got %d guilds", len(cfg.Guilds))
+	}
+	g := cfg.Guilds[0]
+	if g.MuteRoleID != "666666666666666666" || g.AfkChannelID != "333333333333333333" {
+		t.Errorf("guild = %+v", g)
+	}
+}
+
+func TestValidationPointsAtKeys(t *testing.T) {
+	clearEnv(t)
+	p := writeFile(t, `
+xp:
+  voice_per_hour: -5
+  voice_min_humans: 0
+  message_award: 15
+  message_award_max: 10
+  message_duplicate_similarity: 1.5
+  tier_sync_interval: 10m
+metrics:
+  listen: "9090"
+log:
+  format: xml
+  modules:
+    mutee: debug
+mute:
+  retention_days: 0
+levels:
+  curve: table
+  table: [100, 300, 300]
+guilds:
+  - id: "111111111111111111"
+    locale: "de"
+    role_stacking: sum
+    levelup:
+      mode: everywhere
+    xp_decay:
+      percent: 5
+    tier_roles:
+      l25_49: "abc"
+    tier_mode: keep
+  - id: "111111111111111111"
+`)
+
+	_, err := Load(p, true)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{
+		"discord.token",
+		"xp.voice_per_hour",
+		"xp.voice_min_humans: must be >= 1",
+		"xp.message_award_max: must be 0 or >= message_award (15), got 10",
+		"xp.message_duplicate_similarity",
+		"xp.tier_sync_interval: must be 0 or >= 1h, got 10m0s",
+		"mute.retention_days",
+		"levels.table[2]: must be greater than the previous threshold (300)",
+		`metrics.listen: "9090"`,
+		`log.format: "xml"`,
+		"log.modules.mutee: unknown module",
+		`guilds[0].tier_roles.l25_49: "abc"`,
+		`guilds[0].locale: "de" is not supported`,
+		`guilds[0].role_stacking: "sum" is not supported`,
+		`guilds[0].levelup.mode: "everywhere" is not supported`,
+		"guilds[0].xp_decay.after_days: must be >= 1 when percent is set",
+		`guilds[0].tier_mode: "keep" is not supported`,
+		"guilds[1].id: duplicate of guilds[0]",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestUnknownKeyIsRejected(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("DISCORD_TOKEN", "secret")
+	p := writeFile(t, `
+xp:
+  voice_per_huor: 50
+`)
+
+	_, err := Load(p, true)
+	if err == nil || !strings.Contains(err.Error(), "voice_per_huor") {
+		t.Fatalf("expected unknown-field error, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Validate проверяет конфиг целиком и возвращает все ошибки сразу,
+// каждая — с путём до ключа ("guilds[0].tier_roles.l25_49: …").
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	id := func(key, v string, required bool) {
+		switch {
+		case v == "" && required:
+			bad(key, "required")
+		case v != "" && !isSnowflake(v):
+			bad(key, "%q is not a Discord ID", v)
+		}
+	}
+
+	if c.Discord.Token == "" {
+		bad("discord.token", "required (or set DISCORD_TOKEN)")
+	}
+	for i, v := range c.AdminRoleIDs {
+		id(fmt.Sprintf("admin_role_ids[%d]", i), v, true)
+	}
+	for i, v := range c.ProtectedRoleIDs {
+		id(fmt.Sprintf("protected_role_ids[%d]", i), v, true)
+	}
+
+	if c.XP.MessageAward < 0 {
+		bad("xp.message_award", "must be >= 0, got %d", c.XP.MessageAward)
+	}
+	if c.XP.MessageAwardMax != 0 && c.XP.MessageAwardMax < c.XP.MessageAward {
+		bad("xp.message_award_max", "must be 0 or >= message_award (%d), got %d", c.XP.MessageAward, c.XP.MessageAwardMax)
+	}
+	if c.XP.MessageMinLength < 0 {
+		bad("xp.message_min_length", "must be >= 0, got %d", c.XP.MessageMinLength)
+	}
+	if c.XP.MessageMinWords < 0 {
+		bad("xp.message_min_words", "must be >= 0, got %d", c.XP.MessageMinWords)
+	}
+	if s := c.XP.MessageDuplicateSimilarity; s < 0 || s > 1 {
+		bad("xp.message_duplicate_similarity", "must be within [0, 1], got %g", s)
+	}
+	if c.XP.MessageCooldown < 0 {
+		bad("xp.message_cooldown", "must be >= 0, got %s", c.XP.MessageCooldown)
+	}
+	if c.XP.VoicePerHour < 0 {
+		bad("xp.voice_per_hour", "must be >= 0, got %g", c.XP.VoicePerHour)
+	}
+	if c.XP.VoiceDowntimeCredit < 0 {
+		bad("xp.voice_downtime_credit", "must be >= 0, got %s", c.XP.VoiceDowntimeCredit)
+	}
+	if c.XP.TierSyncInterval != 0 && c.XP.TierSyncInterval < time.Hour {
+		bad("xp.tier_sync_interval", "must be 0 or >= 1h, got %s", c.XP.TierSyncInterval)
+	}
+	if c.XP.VoiceMinHumans < 1 {
+		bad("xp.voice_min_humans", "must be >= 1, got %d", c.XP.VoiceMinHumans)
+	}
+	if c.Mute.RetentionDays < 1 {
+		bad("mute.retention_days", "must be >= 1, got %d", c.Mute.RetentionDays)
+	}
+	if c.Give.Warn1XP < 0 {
+		bad("give.warn1_xp", "must be >= 0, got %d", c.Give.Warn1XP)
+	}
+	if c.Give.Warn2XP < 0 {
+		bad("give.warn2_xp", "must be >= 0, got %d", c.Give.Warn2XP)
+	}
+
+	if c.Metrics.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Listen); err != nil {
+			bad("metrics.listen", "%q is not host:port", c.Metrics.Listen)
+		}
+	}
+
+	switch c.Levels.Curve {
+	case CurveQuadratic:
+		if c.Levels.Factor <= 0 {
+			bad("levels.factor", "must be > 0, got %g", c.Levels.Factor)
+		}
+	case CurveExponential:
+		if c.Levels.Base <= 0 {
+			bad("levels.base", "must be > 0, got %g", c.Levels.Base)
+		}
+		if c.Levels.Growth < 1 {
+			bad("levels.growth", "must be >= 1, got %g", c.Levels.Growth)
+		}
+	case CurveMEE6:
+	case CurveTable:
+		if len(c.Levels.Table) == 0 {
+			bad("levels.table", "required for curve %q", CurveTable)
+		}
+		prev := int64(0)
+		for i, v := range c.Levels.Table {
+			if v <= prev {
+				bad(fmt.Sprintf("levels.table[%d]", i), "must be greater than the previous threshold (%d), got %d", prev, v)
+			}
+			prev = v
+		}
+	default:
+		bad("levels.curve", "%q is not supported (quadratic, exponential, mee6, table)", c.Levels.Curve)
+	}
+
+	if !isLogLevel(c.Log.Level) {
+		bad("log.level", "%q is not a level (debug, info, warn, error)", c.Log.Level)
+	}
+	if c.Log.Format != "" && c.Log.Format != "text" && c.Log.Format != "json" {
+		bad("log.format", "%q is not supported (text, json)", c.Log.Format)
+	}
+	for name, lv := range c.Log.Modules {
+		if !slices.Contains(LogModules, name) {
+			bad("log.modules."+name, "unknown module (%s)", strings.Join(LogModules, ", "))
+		}
+		if !isLogLevel(lv) {
+			bad("log.modules."+name, "%q is not a level (debug, info, warn, error)", lv)
+		}
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		bad("shutdown_timeout", "must be > 0, got %s", c.ShutdownTimeout)
+	}
+
+	seen := make(map[string]int, len(c.Guilds))
+	for i, g := range c.Guilds {
+		p := fmt.Sprintf("guilds[%d]", i)
+		id(p+".id", g.ID, true)
+		if j, dup := seen[g.ID]; dup && g.ID != "" {
+			bad(p+".id", "duplicate of guilds[%d]", j)
+		}
+		seen[g.ID] = i
+
+		id(p+".mute_role_id", g.MuteRoleID, false)
+		id(p+".log_channel_id", g.LogChannelID, false)
+		id(p+".keep_category_id", g.KeepCategoryID, false)
+		id(p+".welcome_channel_id", g.WelcomeChannelID, false)
+		id(p+".self_role_id", g.SelfRoleID, false)
+		id(p+".afk_channel_id", g.AfkChannelID, false)
+		id(p+".announce_channel_id", g.AnnounceChannelID, false)
+		switch g.RoleStacking {
+		case "", "max", "multiply":
+		default:
+			bad(p+".role_stacking", "%q is not supported (max, multiply)", g.RoleStacking)
+		}
+		switch g.Locale {
+		case "", "ru", "en":
+		default:
+			bad(p+".locale", "%q is not supported (ru, en)", g.Locale)
+		}
+		switch g.LevelUp.Mode {
+		case "", "off", "channel", "same", "dm":
+		default:
+			bad(p+".levelup.mode", "%q is not supported (off, channel, same, dm)", g.LevelUp.Mode)
+		}
+		id(p+".levelup.channel_id", g.LevelUp.ChannelID, false)
+		switch g.LevelUp.Style {
+		case "", "text", "embed":
+		default:
+			bad(p+".levelup.style", "%q is not supported (text, embed)", g.LevelUp.Style)
+		}
+		if d := g.XPDecay; d.Percent < 0 || d.Percent > 100 {
+			bad(p+".xp_decay.percent", "must be between 0 and 100, got %v", d.Percent)
+		} else if d.Percent > 0 && d.AfterDays < 1 {
+			bad(p+".xp_decay.after_days", "must be >= 1 when percent is set, got %d", d.AfterDays)
+		}
+		if g.XPDecay.AfterDays < 0 || g.XPDecay.FloorLevel < 0 {
+			bad(p+".xp_decay", "after_days and floor_level must not be negative")
+		}
+
+		id(p+".tier_roles.l1_24", g.TierRoles.L1to24, false)
+		id(p+".tier_roles.l25_49", g.TierRoles.L25to49, false)
+		id(p+".tier_roles.l50_74", g.TierRoles.L50to74, false)
+		id(p+".tier_roles.l75_99", g.TierRoles.L75to99, false)
+		id(p+".tier_roles.l100_plus", g.TierRoles.L100Plus, false)
+		switch g.TierMode {
+		case "", "replace", "stack", "stack_except":
+		default:
+			bad(p+".tier_mode", "%q is not supported (replace, stack, stack_except)", g.TierMode)
+		}
+		for j, rid := range g.TierExclude {
+			id(fmt.Sprintf("%s.tier_exclude[%d]", p, j), rid, true)
+		}
+
+		id(p+".penalty_roles.warn1", g.PenaltyRoles.Warn1, false)
+		id(p+".penalty_roles.warn2", g.PenaltyRoles.Warn2, false)
+		id(p+".penalty_roles.kick", g.PenaltyRoles.Kick, false)
+	}
+
+	return errors.Join(errs...)
+}
+
+// isSnowflake — ID Discord: только цифры, 17–20 знаков.
+func isSnowflake(v string) bool {
+	if len(v) < 17 || len(v) > 20 {
+		return false
+	}
+	return strings.IndexFunc(v, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+func isLogLevel(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "", "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package discord
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Session — узкий набор REST-вызовов Discord, которыми пользуется бот.
+// *discordgo.Session удовлетворяет ему как есть; в тестах подставляется discordtest.Fake.
+type Session interface {
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	UserChannelPermissions(userID, channelID string, options ...discordgo.RequestOption) (int64, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	GuildMembers(guildID, after string, limit int, options ...discordgo.RequestOption) ([]*discordgo.Member, error)
+	GuildMemberRoleAdd(guildID, userID, roleID string, options ...discordgo.RequestOption) error
+	GuildMemberRoleRemove(guildID, userID, roleID string, options ...discordgo.RequestOption) error
+	GuildMemberDeleteWithReason(guildID, userID, reason string, options ...discordgo.RequestOption) error
+	GuildAuditLog(guildID, userID, beforeID string, actionType, limit int, options ...discordgo.RequestOption) (*discordgo.GuildAuditLog, error)
+
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+	ChannelMessagesBulkDelete(channelID string, messages []string, options ...discordgo.RequestOption) error
+	ThreadStartComplex(channelID string, data *discordgo.ThreadStart, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ThreadMemberAdd(threadID, memberID string, options ...discordgo.RequestOption) error
+
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+var _ Session = (*discordgo.Session)(nil)
+
+// BotID — ID пользователя бота: из State (если это живая сессия), иначе через /users/@me.
+func BotID(s Session) (string, error) {
+	if ds, ok := s.(*discordgo.Session); ok && ds.State != nil && ds.State.User != nil && ds.State.User.ID != "" {
+		return ds.State.User.ID, nil
+	}
+	me, err := s.User("@me")
+	if err != nil {
+		return "", err
+	}
+	return me.ID, nil
+}
+
+// Roles — роли сервера: из State, если он есть, иначе REST.
+func Roles(s Session, guildID string) ([]*discordgo.Role, error) {
+	if ds, ok := s.(*discordgo.Session); ok && ds.State != nil {
+		if g, err := ds.State.Guild(guildID); err == nil && g != nil && len(g.Roles) > 0 {
+			return g.Roles, nil
+		}
+	}
+	return s.GuildRoles(guildID)
+}
+
+// Member — участник сервера: из State, если он есть, иначе REST.
+func Member(s Session, guildID, userID string) (*discordgo.Member, error) {
+	if m := StateMember(s, guildID, userID); m != nil {
+		return m, nil
+	}
+	return s.GuildMember(guildID, userID)
+}
+
+// StateMember — участник сервера из State без похода в REST; nil — в State его нет.
+func StateMember(s Session, guildID, userID string) *discordgo.Member {
+	if ds, ok := s.(*discordgo.Session); ok && ds.State != nil {
+		if m, err := ds.State.Member(guildID, userID); err == nil && m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+// Channel — канал: из State, если он есть, иначе REST.
+func Channel(s Session, channelID string) (*discordgo.Channel, error) {
+	if ds, ok := s.(*discordgo.Session); ok && ds.State != nil {
+		if c, err := ds.State.Channel(channelID); err == nil && c != nil {
+			return c, nil
+		}
+	}
+	return s.Channel(channelID)
+}
+
+// HighestRolePosition — позиция самой высокой из ролей ids (-1, если ни одной нет среди roles).
+func HighestRolePosition(roles []*discordgo.Role, ids []string) int {
+	pos := make(map[string]int, len(roles))
+	for _, r := range roles {
+		pos[r.ID] = r.Position
+	}
+	max := -1
+	for _, id := range ids {
+		if p, ok := pos[id]; ok && p > max {
+			max = p
+		}
+	}
+	return max
+}
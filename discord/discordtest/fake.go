@@ -0,0 +1,551 @@
+// Package discordtest — in-memory Discord для тестов: серверы, роли с позициями,
+// участники, каналы и сообщения. Все вызовы записываются, любой можно заставить упасть.
+package discordtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"gosha_bot/discord"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var _ discord.Session = (*Fake)(nil)
+
+// ErrNotFound возвращается для неизвестных серверов/участников/каналов.
+var ErrNotFound = errors.New("discordtest: not found")
+
+// Call — запись одного REST-вызова.
+type Call struct {
+	Method string
+	Args   []string
+}
+
+type guild struct {
+	roles   map[string]*discordgo.Role
+	members map[string]*discordgo.Member
+}
+
+type Fake struct {
+	mu sync.Mutex
+
+	BotUserID string
+
+	guilds   map[string]*guild
+	channels map[string][]*discordgo.Message // старые → новые
+	chans    map[string]*discordgo.Channel   // каналы и категории (Channel)
+	perms    map[string]int64                // userID → права (во всех каналах одинаково)
+	fails    map[string]error
+	nextID   int
+
+	AuditLog *discordgo.GuildAuditLog
+
+	Calls     []Call
+	Responses []*discordgo.InteractionResponse
+	Edits     []*discordgo.WebhookEdit
+	Followups []*discordgo.WebhookParams
+	Kicked    map[string]string // guildID:userID → reason
+}
+
+func New(botUserID string) *Fake {
+	return &Fake{
+		BotUserID: botUserID,
+		guilds:    make(map[string]*guild),
+		channels:  make(map[string][]*discordgo.Message),
+		chans:     make(map[string]*discordgo.Channel),
+		perms:     make(map[string]int64),
+		fails:     make(map[string]error),
+		Kicked:    make(map[string]string),
+	}
+}
+
+// ---------- настройка ----------
+
+// AddRole добавляет роль на сервер (сервер создаётся при необходимости).
+func (f *Fake) AddRole(guildID, roleID string, position int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.guild(guildID).roles[roleID] = &discordgo.Role{ID: roleID, Name: roleID, Position: position}
+}
+
+// AddMember добавляет участника с ролями.
+func (f *Fake) AddMember(guildID, userID string, roles ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.guild(guildID).members[userID] = &discordgo.Member{
+		GuildID: guildID,
+		User:    &discordgo.User{ID: userID, Username: "user" + userID},
+		Roles:   append([]string(nil), roles...),
+	}
+}
+
+// SetPermissions задаёт права пользователя (UserChannelPermissions).
+func (f *Fake) SetPermissions(userID string, perms int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.perms[userID] = perms
+}
+
+// AddChannel добавляет канал; parentID — категория (или канал для ветки).
+func (f *Fake) AddChannel(guildID, channelID, parentID string, typ discordgo.ChannelType) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chans[channelID] = &discordgo.Channel{ID: channelID, GuildID: guildID, ParentID: parentID, Type: typ}
+}
+
+// AddMessage кладёт сообщение в канал (как самое новое).
+func (f *Fake) AddMessage(channelID string, ts time.Time) *discordgo.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m := &discordgo.Message{ID: f.newID(), ChannelID: channelID, Timestamp: ts}
+	f.channels[channelID] = append(f.channels[channelID], m)
+	return m
+}
+
+// Fail заставляет method падать с err. key — roleID для операций с ролями,
+// channelID для сообщений, userID для участников; "" — любой вызов метода.
+func (f *Fake) Fail(method, key string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fails[method+"|"+key] = err
+}
+
+// ---------- проверки ----------
+
+// MemberRoles — текущие роли участника (отсортированы), nil если его нет.
+func (f *Fake) MemberRoles(guildID, userID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil
+	}
+	m, ok := g.members[userID]
+	if !ok {
+		return nil
+	}
+	out := append([]string(nil), m.Roles...)
+	sort.Strings(out)
+	return out
+}
+
+// Messages — ID сообщений канала (старые → новые).
+func (f *Fake) Messages(channelID string) []*discordgo.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*discordgo.Message(nil), f.channels[channelID]...)
+}
+
+// CallCount — сколько раз вызывался method.
+func (f *Fake) CallCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, c := range f.Calls {
+		if c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// LastEdit — текст последнего InteractionResponseEdit ("" если не было).
+func (f *Fake) LastEdit() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.Edits) == 0 || f.Edits[len(f.Edits)-1].Content == nil {
+		return ""
+	}
+	return *f.Edits[len(f.Edits)-1].Content
+}
+
+// ---------- internals ----------
+
+func (f *Fake) guild(id string) *guild {
+	g, ok := f.guilds[id]
+	if !ok {
+		g = &guild{roles: make(map[string]*discordgo.Role), members: make(map[string]*discordgo.Member)}
+		f.guilds[id] = g
+	}
+	return g
+}
+
+func (f *Fake) newID() string {
+	f.nextID++
+	return strconv.Itoa(1000 + f.nextID)
+}
+
+// record пишет вызов и возвращает ошибку, если она настроена через Fail.
+func (f *Fake) record(method, key string, args ...string) error {
+	f.Calls = append(f.Calls, Call{Method: method, Args: args})
+	if err, ok := f.fails[method+"|"+key]; ok {
+		return err
+	}
+	if err, ok := f.fails[method+"|"]; ok {
+		return err
+	}
+	return nil
+}
+
+func (f *Fake) member(guildID, userID string) (*discordgo.Member, error) {
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil, fmt.Errorf("guild %s: %w", guildID, ErrNotFound)
+	}
+	m, ok := g.members[userID]
+	if !ok {
+		return nil, fmt.Errorf("member %s: %w", userID, ErrNotFound)
+	}
+	return m, nil
+}
+
+func cloneMember(m *discordgo.Member) *discordgo.Member {
+	cp := *m
+	cp.Roles = append([]string(nil), m.Roles...)
+	return &cp
+}
+
+// ---------- discord.Session ----------
+
+func (f *Fake) User(userID string, _ ...discordgo.RequestOption) (*discordgo.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("User", userID, userID); err != nil {
+		return nil, err
+	}
+	if userID == "@me" {
+		return &discordgo.User{ID: f.BotUserID, Bot: true}, nil
+	}
+	return &discordgo.User{ID: userID}, nil
+}
+
+func (f *Fake) UserChannelPermissions(userID, channelID string, _ ...discordgo.RequestOption) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("UserChannelPermissions", userID, userID, channelID); err != nil {
+		return 0, err
+	}
+	return f.perms[userID], nil
+}
+
+// UserChannelCreate — личка с пользователем: канал "dm:<userID>", сообщения в него видны через Messages.
+func (f *Fake) UserChannelCreate(recipientID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("UserChannelCreate", recipientID, recipientID); err != nil {
+		return nil, err
+	}
+	return &discordgo.Channel{ID: "dm:" + recipientID, Type: discordgo.ChannelTypeDM}, nil
+}
+
+func (f *Fake) Guild(guildID string, _ ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Guild", guildID, guildID); err != nil {
+		return nil, err
+	}
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil, fmt.Errorf("guild %s: %w", guildID, ErrNotFound)
+	}
+	out := &discordgo.Guild{ID: guildID}
+	for _, r := range g.roles {
+		cp := *r
+		out.Roles = append(out.Roles, &cp)
+	}
+	return out, nil
+}
+
+func (f *Fake) GuildRoles(guildID string, _ ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildRoles", guildID, guildID); err != nil {
+		return nil, err
+	}
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil, fmt.Errorf("guild %s: %w", guildID, ErrNotFound)
+	}
+	out := make([]*discordgo.Role, 0, len(g.roles))
+	for _, r := range g.roles {
+		cp := *r
+		out = append(out, &cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Position < out[j].Position })
+	return out, nil
+}
+
+func (f *Fake) GuildMember(guildID, userID string, _ ...discordgo.RequestOption) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildMember", userID, guildID, userID); err != nil {
+		return nil, err
+	}
+	m, err := f.member(guildID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return cloneMember(m), nil
+}
+
+func (f *Fake) GuildMembers(guildID, after string, limit int, _ ...discordgo.RequestOption) ([]*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildMembers", guildID, guildID, after); err != nil {
+		return nil, err
+	}
+	g, ok := f.guilds[guildID]
+	if !ok {
+		return nil, fmt.Errorf("guild %s: %w", guildID, ErrNotFound)
+	}
+	ids := make([]string, 0, len(g.members))
+	for id := range g.members {
+		if after == "" || id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	out := make([]*discordgo.Member, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, cloneMember(g.members[id]))
+	}
+	return out, nil
+}
+
+func (f *Fake) GuildMemberRoleAdd(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildMemberRoleAdd", roleID, guildID, userID, roleID); err != nil {
+		return err
+	}
+	m, err := f.member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	if _, ok := f.guilds[guildID].roles[roleID]; !ok {
+		return fmt.Errorf("role %s: %w", roleID, ErrNotFound)
+	}
+	for _, r := range m.Roles {
+		if r == roleID {
+			return nil
+		}
+	}
+	m.Roles = append(m.Roles, roleID)
+	return nil
+}
+
+func (f *Fake) GuildMemberRoleRemove(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildMemberRoleRemove", roleID, guildID, userID, roleID); err != nil {
+		return err
+	}
+	m, err := f.member(guildID, userID)
+	if err != nil {
+		return err
+	}
+	out := m.Roles[:0]
+	for _, r := range m.Roles {
+		if r != roleID {
+			out = append(out, r)
+		}
+	}
+	m.Roles = out
+	return nil
+}
+
+func (f *Fake) GuildMemberDeleteWithReason(guildID, userID, reason string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildMemberDeleteWithReason", userID, guildID, userID, reason); err != nil {
+		return err
+	}
+	if _, err := f.member(guildID, userID); err != nil {
+		return err
+	}
+	delete(f.guilds[guildID].members, userID)
+	f.Kicked[guildID+":"+userID] = reason
+	return nil
+}
+
+func (f *Fake) GuildAuditLog(guildID, userID, beforeID string, actionType, limit int, _ ...discordgo.RequestOption) (*discordgo.GuildAuditLog, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("GuildAuditLog", guildID, guildID); err != nil {
+		return nil, err
+	}
+	if f.AuditLog == nil {
+		return &discordgo.GuildAuditLog{}, nil
+	}
+	return f.AuditLog, nil
+}
+
+func (f *Fake) Channel(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("Channel", channelID, channelID); err != nil {
+		return nil, err
+	}
+	c, ok := f.chans[channelID]
+	if !ok {
+		return nil, fmt.Errorf("channel %s: %w", channelID, ErrNotFound)
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (f *Fake) ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, _ ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessages", channelID, channelID); err != nil {
+		return nil, err
+	}
+	all := f.channels[channelID]
+	out := make([]*discordgo.Message, 0, limit)
+	for i := len(all) - 1; i >= 0 && len(out) < limit; i-- { // новые первыми, как в API
+		cp := *all[i]
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (f *Fake) send(channelID string, m *discordgo.Message) *discordgo.Message {
+	m.ID = f.newID()
+	m.ChannelID = channelID
+	m.Timestamp = time.Now()
+	f.channels[channelID] = append(f.channels[channelID], m)
+	cp := *m
+	return &cp
+}
+
+func (f *Fake) ChannelMessageSend(channelID string, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessageSend", channelID, channelID); err != nil {
+		return nil, err
+	}
+	return f.send(channelID, &discordgo.Message{Content: content}), nil
+}
+
+func (f *Fake) ChannelMessageSendEmbed(channelID string, embed *discordgo.MessageEmbed, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessageSendEmbed", channelID, channelID); err != nil {
+		return nil, err
+	}
+	return f.send(channelID, &discordgo.Message{Embeds: []*discordgo.MessageEmbed{embed}}), nil
+}
+
+func (f *Fake) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessageSendComplex", channelID, channelID); err != nil {
+		return nil, err
+	}
+	return f.send(channelID, &discordgo.Message{Content: data.Content, Embeds: data.Embeds, Components: data.Components}), nil
+}
+
+func (f *Fake) ChannelMessageDelete(channelID, messageID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessageDelete", channelID, channelID, messageID); err != nil {
+		return err
+	}
+	return f.deleteMessages(channelID, messageID)
+}
+
+func (f *Fake) ChannelMessagesBulkDelete(channelID string, messages []string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ChannelMessagesBulkDelete", channelID, append([]string{channelID}, messages...)...); err != nil {
+		return err
+	}
+	if len(messages) < 2 || len(messages) > 100 {
+		return fmt.Errorf("discordtest: bulk delete needs 2..100 ids, got %d", len(messages))
+	}
+	cutoff := time.Now().Add(-14 * 24 * time.Hour)
+	for _, m := range f.channels[channelID] {
+		for _, id := range messages {
+			if m.ID == id && m.Timestamp.Before(cutoff) {
+				return fmt.Errorf("discordtest: message %s is older than 14 days", id)
+			}
+		}
+	}
+	return f.deleteMessages(channelID, messages...)
+}
+
+func (f *Fake) deleteMessages(channelID string, ids ...string) error {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	msgs := f.channels[channelID]
+	out := msgs[:0]
+	for _, m := range msgs {
+		if want[m.ID] {
+			delete(want, m.ID)
+			continue
+		}
+		out = append(out, m)
+	}
+	f.channels[channelID] = out
+	if len(want) > 0 {
+		return fmt.Errorf("message: %w", ErrNotFound)
+	}
+	return nil
+}
+
+func (f *Fake) ThreadStartComplex(channelID string, data *discordgo.ThreadStart, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("ThreadStartComplex", channelID, channelID, data.Name); err != nil {
+		return nil, err
+	}
+	id := f.newID()
+	f.channels[id] = nil
+	return &discordgo.Channel{ID: id, ParentID: channelID, Name: data.Name, Type: data.Type}, nil
+}
+
+func (f *Fake) ThreadMemberAdd(threadID, memberID string, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.record("ThreadMemberAdd", threadID, threadID, memberID)
+}
+
+func (f *Fake) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("InteractionRespond", interaction.ID, interaction.ID); err != nil {
+		return err
+	}
+	f.Responses = append(f.Responses, resp)
+	return nil
+}
+
+func (f *Fake) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("InteractionResponseEdit", interaction.ID, interaction.ID); err != nil {
+		return nil, err
+	}
+	f.Edits = append(f.Edits, newresp)
+	return &discordgo.Message{ID: f.newID()}, nil
+}
+
+func (f *Fake) FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.record("FollowupMessageCreate", interaction.ID, interaction.ID); err != nil {
+		return nil, err
+	}
+	f.Followups = append(f.Followups, data)
+	return &discordgo.Message{ID: f.newID(), Content: data.Content}, nil
+}
@@ -3,106 +3,112 @@ package give
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
-	 "math"
+	"sync"
+	"time"
 
 	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/config"
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
+	"gosha_bot/xpstore"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// --- Константы ролей со спец-логикой (подставь свои ID как в ТЗ) ---
-const (
-	roleMinus1000XP = "1402166453486096435" // выдача роли => снять 1000xp
-	roleMinus1500XP = "1402166685456400445" // выдача роли => снять 1500xp
-	roleThirdWarn   = "1404881178720342067" // выдача роли => кикнуть с сервера (3 предупреждение)
-)
-
 const CommandName = "give"
 
+// сколько ждать БД при изменении XP за штрафную роль
+const xpTimeout = 2 * time.Second
+
 type Registry struct {
-	GuildID           string
-	AdminRoleIDs      map[string]bool
-	ProtectedRoleIDs  map[string]bool
-	s                 *discordgo.Session
-	DB                *pgxpool.Pool
-	AdminLog          *adminlog.Logger
-	botUserID         string
-	botHighestPos     int
-	guildRolesByID    map[string]*discordgo.Role
+	AdminRoleIDs     map[string]bool
+	ProtectedRoleIDs map[string]bool
+	s                discord.Session
+	Guilds           *guildcfg.Store // роли-наказания сервера (penalty_roles)
+	Penalties        config.Give     // сколько XP они снимают
+	Store            xpstore.Store   // nil — БД не настроена; уровень после списания — по кривой стора
+	AdminLog         *adminlog.Logger
+	LevelRoles       func(guildID, userID string, level int) error // nil — модуль level выключен, роли за уровень не трогаем
+	log              *slog.Logger
+
+	muRoles        sync.Mutex
+	botHighestPos  map[string]int                        // guildID -> позиция верхней роли бота
+	guildRolesByID map[string]map[string]*discordgo.Role // guildID -> roleID -> role
 }
 
 // Register — регистрирует команду и хендлер
 func Register(
 	s *discordgo.Session,
-	guildID string,
+	router *commands.Router,
+	guilds *guildcfg.Store,
 	adminRoleIDs []string,
 	protectedRoleIDs []string,
 	db *pgxpool.Pool,
 	al *adminlog.Logger,
+	penalties config.Give,
+	curve levelcurve.Curve,
+	log *slog.Logger,
 ) (*Registry, error) {
 
-	r := &Registry{
-		GuildID:          guildID,
-		AdminRoleIDs:     toSet(adminRoleIDs),
-		ProtectedRoleIDs: toSet(protectedRoleIDs),
-		s:                s,
-		DB:               db,
-		AdminLog:         al,
+	var store xpstore.Store
+	if db != nil {
+		store = xpstore.NewPG(db, curve)
 	}
+	r := newRegistry(s, guilds, store, adminRoleIDs, protectedRoleIDs)
+	r.AdminLog = al
+	r.Penalties = penalties
+	r.log = log
+
+	router.Add(&discordgo.ApplicationCommand{
+		Name:                     CommandName,
+		Description:              "Выдать роль пользователю (админ-команда)",
+		DefaultMemberPermissions: &[]int64{discordgo.PermissionAdministrator}[0],
+		Type:                     discordgo.ChatApplicationCommand,
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Кому выдать", Required: true},
+			{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Какую роль выдать", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина выдачи", Required: true},
+		},
+	}, r.onInteractionCreate)
 
-	// слушатели
-	s.AddHandler(r.onInteractionCreate)
-
-	// Регистрируем команду и считаем позицию бота ТОЛЬКО после Ready
-	var readyOnce bool
-	s.AddHandler(func(s *discordgo.Session, ev *discordgo.Ready) {
-		if readyOnce {
-			return
-		}
-		readyOnce = true
-
-		// bot user id после логина
-		r.botUserID = ev.User.ID
-
-		if err := r.refreshGuildRolesCache(); err != nil {
-			log.Println("[give] refresh roles:", err)
-		}
-
-		cmd := &discordgo.ApplicationCommand{
-			Name:        CommandName,
-			Description: "Выдать роль пользователю (админ-команда)",
-			DefaultMemberPermissions: &[]int64{discordgo.PermissionAdministrator}[0],
-			Type:        discordgo.ChatApplicationCommand,
-			Options: []*discordgo.ApplicationCommandOption{
-				{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Кому выдать", Required: true},
-				{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Какую роль выдать", Required: true},
-				{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина выдачи", Required: true},
-			},
-		}
-		if _, err := s.ApplicationCommandCreate(ev.User.ID, guildID, cmd); err != nil {
-			log.Println("[give] cmd create:", err)
-		} else {
-			log.Println("[give] registered /give")
+	// считаем позицию бота для каждого сервера, когда он становится доступен
+	s.AddHandler(func(s *discordgo.Session, ev *discordgo.GuildCreate) {
+		if err := r.refreshGuildRolesCache(ev.ID); err != nil {
+			r.log.Warn("refresh roles cache", "guild", ev.ID, "err", err)
 		}
 	})
 
 	return r, nil
 }
 
+func newRegistry(api discord.Session, guilds *guildcfg.Store, store xpstore.Store, adminRoleIDs, protectedRoleIDs []string) *Registry {
+	return &Registry{
+		AdminRoleIDs:     toSet(adminRoleIDs),
+		ProtectedRoleIDs: toSet(protectedRoleIDs),
+		s:                api,
+		Guilds:           guilds,
+		Penalties:        config.Default().Give,
+		Store:            store,
+		botHighestPos:    make(map[string]int),
+		guildRolesByID:   make(map[string]map[string]*discordgo.Role),
+		log:              logging.Discard(),
+	}
+}
 
 // onInteractionCreate — обработчик slash-команды
 func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.InteractionCreate) {
 	if ic.Type != discordgo.InteractionApplicationCommand {
 		return
 	}
-	if ic.GuildID != r.GuildID {
-		return
-	}
-	if ic.ApplicationCommandData().Name != CommandName {
+	if ic.GuildID == "" {
 		return
 	}
 
@@ -111,8 +117,9 @@ func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.Inter
 	if member == nil {
 		return
 	}
+	lang := i18n.For(ic.Interaction)
 	if !r.isInvokerAdmin(member.Roles) {
-		_ = respondEphemeral(s, ic, "❌ Недостаточно прав. Команда доступна только администраторам.")
+		r.respondEphemeral(s, ic, i18n.T(lang, "give.no_perms"))
 		return
 	}
 
@@ -136,78 +143,81 @@ func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.Inter
 	}
 
 	if targetUser == nil || roleID == "" || reason == "" {
-		_ = respondEphemeral(s, ic, "❌ Неверные параметры. Нужно указать user, role и reason.")
+		r.respondEphemeral(s, ic, i18n.T(lang, "give.bad_args"))
 		return
 	}
 
-	// запрет на защищённые роли
-	if r.ProtectedRoleIDs[roleID] {
-		_ = respondEphemeral(s, ic, "⛔ Эту роль выдавать нельзя (защищённый список: уровни).")
+	// запрет на защищённые роли и роли за уровни
+	if r.isProtected(ic.GuildID, roleID) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "give.protected"))
 		return
 	}
 
 	// нельзя выдавать роль выше роли бота
-	if !r.isRoleBelowBot(roleID) {
-		_ = respondEphemeral(s, ic, "⛔ Нельзя выдать роль, которая выше роли бота.")
+	if !r.isRoleBelowBot(ic.GuildID, roleID) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "give.above_bot"))
 		return
 	}
 
 	// выдаём роль
-	if err := s.GuildMemberRoleAdd(r.GuildID, targetUser.ID, roleID); err != nil {
-		_ = respondEphemeral(s, ic, fmt.Sprintf("❌ Не удалось выдать роль: %v", err))
+	if err := s.GuildMemberRoleAdd(ic.GuildID, targetUser.ID, roleID); err != nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "give.add_failed", err))
 		return
 	}
 
-	   // спец-логика при выдаче некоторых ролей
-eff, err := r.applySideEffects(targetUser.ID, roleID, reason)
-if err != nil {
-    log.Println("[give] side effects:", err)
-}
+	// спец-логика при выдаче некоторых ролей
+	eff, err := r.applySideEffects(ic.GuildID, targetUser.ID, roleID, member.User.ID, reason)
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("penalty side effects", "target", targetUser.ID, "role", roleID, "err", err)
+	}
 
-// лог
-r.logGive(targetUser, roleID, reason, ic.Member.User)
-
-// --- красивый embed-ответ ---
-embed := &discordgo.MessageEmbed{
-    Title: "Выдача роли",
-    Color: 0x5865F2,
-    Fields: []*discordgo.MessageEmbedField{
-        {Name: "Пользователь", Value: fmt.Sprintf("<@%s>", targetUser.ID), Inline: true},
-        {Name: "Роль", Value: fmt.Sprintf("<@&%s>", roleID), Inline: true},
-        {Name: "Причина", Value: reason, Inline: false},
-    },
-}
+	// лог
+	r.logGive(ic, targetUser.ID, roleID, reason)
+
+	// --- красивый embed-ответ ---
+	embed := &discordgo.MessageEmbed{
+		Title: i18n.T(lang, "give.title"),
+		Color: 0x5865F2,
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: i18n.T(lang, "field.user"), Value: fmt.Sprintf("<@%s>", targetUser.ID), Inline: true},
+			{Name: i18n.T(lang, "field.role"), Value: fmt.Sprintf("<@&%s>", roleID), Inline: true},
+			{Name: i18n.T(lang, "field.reason"), Value: reason, Inline: false},
+		},
+	}
 
-if eff != nil {
-    xpLine := fmt.Sprintf("%d → %d", eff.XPBefore, eff.XPAfter)
-    lvLine := fmt.Sprintf("%d → %d", eff.LevelBefore, eff.LevelAfter)
-    if eff.XPBefore == 0 && eff.LevelBefore == 0 && (eff.XPAfter != 0 || eff.LevelAfter != 0) {
-        xpLine = fmt.Sprintf("%d", eff.XPAfter)
-        lvLine = fmt.Sprintf("%d", eff.LevelAfter)
-    }
-    embed.Fields = append(embed.Fields,
-        &discordgo.MessageEmbedField{Name: "XP", Value: xpLine, Inline: true},
-        &discordgo.MessageEmbedField{Name: "Уровень", Value: lvLine, Inline: true},
-    )
-    if eff.RemovedRoleID != "" {
-        embed.Fields = append(embed.Fields,
-            &discordgo.MessageEmbedField{Name: "Снята роль", Value: fmt.Sprintf("<@&%s>", eff.RemovedRoleID), Inline: true},
-        )
-    }
-    if eff.Kicked {
-        embed.Fields = append(embed.Fields,
-            &discordgo.MessageEmbedField{Name: "Действие", Value: "Пользователь кикнут (3-е предупреждение)", Inline: false},
-        )
-    }
-}
+	if eff != nil {
+		xpLine := fmt.Sprintf("%d → %d", eff.XPBefore, eff.XPAfter)
+		lvLine := fmt.Sprintf("%d → %d", eff.LevelBefore, eff.LevelAfter)
+		if eff.XPBefore == 0 && eff.LevelBefore == 0 && (eff.XPAfter != 0 || eff.LevelAfter != 0) {
+			xpLine = fmt.Sprintf("%d", eff.XPAfter)
+			lvLine = fmt.Sprintf("%d", eff.LevelAfter)
+		}
+		embed.Fields = append(embed.Fields,
+			&discordgo.MessageEmbedField{Name: i18n.T(lang, "field.xp"), Value: xpLine, Inline: true},
+			&discordgo.MessageEmbedField{Name: i18n.T(lang, "field.level"), Value: lvLine, Inline: true},
+		)
+		if eff.RemovedRoleID != "" {
+			embed.Fields = append(embed.Fields,
+				&discordgo.MessageEmbedField{Name: i18n.T(lang, "give.removed_role"), Value: fmt.Sprintf("<@&%s>", eff.RemovedRoleID), Inline: true},
+			)
+		}
+		if eff.Kicked {
+			embed.Fields = append(embed.Fields,
+				&discordgo.MessageEmbedField{Name: i18n.T(lang, "give.action"), Value: i18n.T(lang, "give.kicked"), Inline: false},
+			)
+		}
+	}
 
-_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-    Type: discordgo.InteractionResponseChannelMessageWithSource,
-    Data: &discordgo.InteractionResponseData{
-        Flags:  discordgo.MessageFlagsEphemeral,
-        Embeds: []*discordgo.MessageEmbed{embed},
-    },
-})
+	err = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:  discordgo.MessageFlagsEphemeral,
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
 }
 
 // --- helpers ---
@@ -220,151 +230,157 @@ func (r *Registry) isInvokerAdmin(invokerRoleIDs []string) bool {
 	return false
 }
 
-
-func (r *Registry) refreshGuildRolesCache() error {
-	roles, err := r.s.GuildRoles(r.GuildID)
+func (r *Registry) refreshGuildRolesCache(guildID string) error {
+	roles, err := r.s.GuildRoles(guildID)
 	if err != nil {
 		return err
 	}
-	r.guildRolesByID = make(map[string]*discordgo.Role, len(roles))
+	byID := make(map[string]*discordgo.Role, len(roles))
 
 	// найдём максимальную позицию роли бота
-	r.botHighestPos = -1
+	highest := -1
 
 	// у бота нет member в payload здесь — достанем его member с ролями
-	botMember, err := r.s.GuildMember(r.GuildID, r.botUserID)
+	botID, err := discord.BotID(r.s)
+	if err != nil {
+		return err
+	}
+	botMember, err := r.s.GuildMember(guildID, botID)
 	if err != nil {
 		return err
 	}
 	rolePos := make(map[string]int, len(roles))
 	for _, role := range roles {
-		r.guildRolesByID[role.ID] = role
+		byID[role.ID] = role
 		rolePos[role.ID] = role.Position
 	}
 	for _, rid := range botMember.Roles {
-		if p := rolePos[rid]; p > r.botHighestPos {
-			r.botHighestPos = p
+		if p := rolePos[rid]; p > highest {
+			highest = p
 		}
 	}
+
+	r.muRoles.Lock()
+	r.guildRolesByID[guildID] = byID
+	r.botHighestPos[guildID] = highest
+	r.muRoles.Unlock()
 	return nil
 }
 
-func (r *Registry) isRoleBelowBot(targetRoleID string) bool {
-	role := r.guildRolesByID[targetRoleID]
+func (r *Registry) cachedRole(guildID, roleID string) (*discordgo.Role, int) {
+	r.muRoles.Lock()
+	defer r.muRoles.Unlock()
+	return r.guildRolesByID[guildID][roleID], r.botHighestPos[guildID]
+}
+
+func (r *Registry) isRoleBelowBot(guildID, targetRoleID string) bool {
+	role, botPos := r.cachedRole(guildID, targetRoleID)
 	if role == nil {
 		// на всякий случай обновим кэш и проверим ещё раз
-		if err := r.refreshGuildRolesCache(); err != nil {
-			log.Println("[give] refresh cache failed:", err)
+		if err := r.refreshGuildRolesCache(guildID); err != nil {
+			r.log.Warn("refresh roles cache", "guild", guildID, "err", err)
 			return false
 		}
-		role = r.guildRolesByID[targetRoleID]
+		role, botPos = r.cachedRole(guildID, targetRoleID)
 		if role == nil {
 			return false
 		}
 	}
-	return role.Position < r.botHighestPos
+	return role.Position < botPos
 }
 
 // --- XP/Level helpers ---
 
-func (r *Registry) getUserStats(userID string) (xp int64, level int, err error) {
-	if r.DB == nil {
-		return 0, 1, fmt.Errorf("DB not configured")
+// changeXP — атомарно прибавляет delta (< 0 — списание, не ниже нуля) и возвращает ДО/ПОСЛЕ
+func (r *Registry) changeXP(guildID, userID string, delta int64, m xpstore.Meta) (xpstore.Result, error) {
+	if r.Store == nil {
+		return xpstore.Result{}, fmt.Errorf("DB not configured")
 	}
-	err = r.DB.QueryRow(
-		context.Background(),
-		`SELECT xp, level FROM users_levels WHERE guild_id=$1 AND user_id=$2`,
-		r.GuildID, userID,
-	).Scan(&xp, &level)
-	// если строки нет — считаем начальные значения
-	if err != nil {
-		return 0, 1, nil
-	}
-	return
+	ctx, cancel := context.WithTimeout(context.Background(), xpTimeout)
+	defer cancel()
+	return r.Store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: userID, Delta: delta, Meta: m})
 }
 
-func levelFromXP(xp int64) int {
-	if xp < 0 {
-		xp = 0
-	}
-	// threshold(L)=10*L^2 → L=floor(sqrt(xp/10)), но минимум 1
-	l := int(math.Floor(math.Sqrt(float64(xp) / 10.0)))
-	if l < 1 {
-		l = 1
-	}
-	return l
-}
-
-func (r *Registry) setXPAndRecalc(userID string, newXP int64) (xpAfter int64, levelAfter int, err error) {
-	if r.DB == nil {
-		return 0, 1, fmt.Errorf("DB not configured")
-	}
-	if newXP < 0 {
-		newXP = 0
-	}
-	newLvl := levelFromXP(newXP)
-	_, err = r.DB.Exec(
-		context.Background(),
-		`UPDATE users_levels SET xp=$1, level=$2, updated_at=now()
-         WHERE guild_id=$3 AND user_id=$4`,
-		newXP, newLvl, r.GuildID, userID,
-	)
-	return newXP, newLvl, err
+// снимает amount и возвращает ДО/ПОСЛЕ
+func (r *Registry) deductXP(guildID, userID string, amount int64, m xpstore.Meta) (xpBefore int64, lvlBefore int, xpAfter int64, lvlAfter int, err error) {
+	res, err := r.changeXP(guildID, userID, -amount, m)
+	return res.XPBefore, res.LevelBefore, res.XP, res.Level, err
 }
 
-// снимает amount и возвращает ДО/ПОСЛЕ
-func (r *Registry) deductXP(userID string, amount int) (xpBefore int64, lvlBefore int, xpAfter int64, lvlAfter int, err error) {
-	xpBefore, lvlBefore, err = r.getUserStats(userID)
-	if err != nil {
-		return
+// resetXP обнуляет XP (уровень → 1) и возвращает ДО/ПОСЛЕ
+func (r *Registry) resetXP(guildID, userID string, m xpstore.Meta) (xpstore.Result, error) {
+	if r.Store == nil {
+		return xpstore.Result{}, fmt.Errorf("DB not configured")
 	}
-	newXP := xpBefore - int64(amount)
-	xpAfter, lvlAfter, err = r.setXPAndRecalc(userID, newXP)
-	return
+	ctx, cancel := context.WithTimeout(context.Background(), xpTimeout)
+	defer cancel()
+	return r.Store.Set(ctx, guildID, userID, 0, m)
 }
 
-
 type Effect struct {
-	XPBefore, XPAfter   int64
+	XPBefore, XPAfter       int64
 	LevelBefore, LevelAfter int
-	RemovedRoleID       string
-	Kicked              bool
+	RemovedRoleID           string
+	Kicked                  bool
 }
 
-func (r *Registry) applySideEffects(userID, roleID, reason string) (*Effect, error) {
+// роли-наказания сервера; пустая структура, если сервер не настроен
+func (r *Registry) penaltyRoles(guildID string) guildcfg.PenaltyRoles {
+	if r.Guilds == nil {
+		return guildcfg.PenaltyRoles{}
+	}
+	if cfg := r.Guilds.Get(guildID); cfg != nil {
+		return cfg.Penalties
+	}
+	return guildcfg.PenaltyRoles{}
+}
+
+// applySideEffects — штраф за выдачу роли-наказания; actorID и reason попадают в журнал XP.
+func (r *Registry) applySideEffects(guildID, userID, roleID, actorID, reason string) (*Effect, error) {
 	e := &Effect{}
+	pen := r.penaltyRoles(guildID)
+	meta := xpstore.Meta{Source: xpstore.SourcePenalty, ActorID: actorID, Reason: reason}
+
+	switch {
+	case roleID == "":
+		return nil, nil
 
-	switch roleID {
-	case roleMinus1000XP:
+	case roleID == pen.Warn1:
 		var err error
-		e.XPBefore, e.LevelBefore, e.XPAfter, e.LevelAfter, err = r.deductXP(userID, 1000)
+		e.XPBefore, e.LevelBefore, e.XPAfter, e.LevelAfter, err = r.deductXP(guildID, userID, r.Penalties.Warn1XP, meta)
+		if err == nil {
+			r.syncLevelRoles(guildID, userID, e)
+		}
 		return e, err
 
-	case roleMinus1500XP:
+	case roleID == pen.Warn2:
 		var err error
-		e.XPBefore, e.LevelBefore, e.XPAfter, e.LevelAfter, err = r.deductXP(userID, 1500)
+		e.XPBefore, e.LevelBefore, e.XPAfter, e.LevelAfter, err = r.deductXP(guildID, userID, r.Penalties.Warn2XP, meta)
 		if err != nil {
 			return e, err
 		}
+		r.syncLevelRoles(guildID, userID, e)
 		// снять предыдущую предупреждающую роль, если есть
-		if remErr := r.s.GuildMemberRoleRemove(r.GuildID, userID, roleMinus1000XP); remErr == nil {
-			e.RemovedRoleID = roleMinus1000XP
+		if pen.Warn1 != "" {
+			if remErr := r.s.GuildMemberRoleRemove(guildID, userID, pen.Warn1); remErr == nil {
+				e.RemovedRoleID = pen.Warn1
+			}
 		}
 		return e, nil
 
-	case roleThirdWarn:
+	case roleID == pen.Kick:
 		// обнуляем XP и уровень → 1, потом кикаем
-		if _, _, err := r.setXPAndRecalc(userID, 0); err != nil {
+		res, err := r.resetXP(guildID, userID, meta)
+		if err != nil {
 			return e, err
 		}
-		kickReason := fmt.Sprintf("3-е предупреждение: %s (выдана роль %s)", reason, roleID)
-		if err := r.s.GuildMemberDeleteWithReason(r.GuildID, userID, kickReason); err != nil {
+		e.XPBefore, e.LevelBefore, e.XPAfter, e.LevelAfter = res.XPBefore, res.LevelBefore, res.XP, res.Level
+		// причина кика видна в журнале аудита сервера — на языке сервера
+		kickReason := i18n.T(i18n.ForGuild(guildID), "give.kick_reason", reason, roleID)
+		if err := r.s.GuildMemberDeleteWithReason(guildID, userID, kickReason); err != nil {
 			return e, err
 		}
 		e.Kicked = true
-		// для отчёта:
-		e.XPBefore, e.LevelBefore, _ = r.getUserStats(userID) // мог отсутствовать
-		e.XPAfter, e.LevelAfter = 0, 1
 		return e, nil
 
 	default:
@@ -372,16 +388,33 @@ func (r *Registry) applySideEffects(userID, roleID, reason string) (*Effect, err
 	}
 }
 
-func (r *Registry) logGive(target *discordgo.User, roleID, reason string, exec *discordgo.User) {
-	msg := fmt.Sprintf(
-		"Выдача роли: <@%s> получил(а) <@&%s>\nПричина: %s\nВыдал: <@%s>",
-		target.ID, roleID, reason, exec.ID,
-	)
+// SetLevelRoles подключает пересчёт ролей за уровень (level.Registry.ApplyLevelRoles):
+// после штрафа роли-ступени приводятся к новому уровню по режиму сервера.
+func (r *Registry) SetLevelRoles(fn func(guildID, userID string, level int) error) { r.LevelRoles = fn }
+
+// syncLevelRoles пересчитывает роли за уровень, если штраф понизил уровень.
+func (r *Registry) syncLevelRoles(guildID, userID string, e *Effect) {
+	if r.LevelRoles == nil || e.LevelAfter == e.LevelBefore {
+		return
+	}
+	if err := r.LevelRoles(guildID, userID, e.LevelAfter); err != nil {
+		r.log.Warn("apply level roles", "guild", guildID, "user", userID, "level", e.LevelAfter, "source", "penalty", "err", err)
+	}
+}
 
-	// TODO: если есть подходящий метод в adminlog.Logger — замени println на него
-	log.Println("[give]", msg)
+// logGive — запись о выдаче; кто выдал и где — в полях интеракции (user, guild)
+func (r *Registry) logGive(ic *discordgo.InteractionCreate, targetID, roleID, reason string) {
+	// TODO: если есть подходящий метод в adminlog.Logger — дублировать туда
+	logging.Interaction(r.log, ic).Info("role given", "target", targetID, "role", roleID, "reason", reason)
 }
 
+// isProtected — роль из protected_role_ids или ступень level_tiers сервера
+func (r *Registry) isProtected(guildID, roleID string) bool {
+	if r.ProtectedRoleIDs[roleID] {
+		return true
+	}
+	return r.Guilds != nil && r.Guilds.IsTierRole(guildID, roleID)
+}
 
 func toSet(ids []string) map[string]bool {
 	m := make(map[string]bool, len(ids))
@@ -395,14 +428,17 @@ func toSet(ids []string) map[string]bool {
 }
 
 // Вспомогательный ответ-эпемерал
-func respondEphemeral(s *discordgo.Session, ic *discordgo.InteractionCreate, content string) error {
-	return s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+func (r *Registry) respondEphemeral(s *discordgo.Session, ic *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Flags:   discordgo.MessageFlagsEphemeral,
 			Content: content,
 		},
 	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
 }
 
 // --- Утилиты для чтения ENV (если пригодится где-то ещё) ---
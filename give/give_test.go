@@ -0,0 +1,222 @@
+package give
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"gosha_bot/discord/discordtest"
+	"gosha_bot/guildcfg"
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+)
+
+const (
+	guildID = "g1"
+	userID  = "u1"
+
+	roleMinus1000XP = "warn1"
+	roleMinus1500XP = "warn2"
+	roleThirdWarn   = "kick"
+)
+
+func setup(t *testing.T, xp int64) (*Registry, *discordtest.Fake, *xpstore.Memory) {
+	t.Helper()
+	fake := discordtest.New("bot")
+	for i, id := range []string{roleMinus1000XP, roleMinus1500XP, roleThirdWarn} {
+		fake.AddRole(guildID, id, i+1)
+	}
+	fake.AddMember(guildID, userID, roleMinus1000XP)
+	store := xpstore.NewMemory(levelcurve.Default)
+	if xp >= 0 {
+		_, _ = store.Set(context.Background(), guildID, userID, xp, xpstore.Meta{})
+	}
+	guilds := guildcfg.NewStore(nil)
+	err := guilds.Apply(context.Background(), guildcfg.Guild{
+		GuildID:   guildID,
+		Penalties: guildcfg.PenaltyRoles{Warn1: roleMinus1000XP, Warn2: roleMinus1500XP, Kick: roleThirdWarn},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newRegistry(fake, guilds, store, nil, nil), fake, store
+}
+
+func TestApplySideEffectsMinus1000(t *testing.T) {
+	r, _, store := setup(t, 5000)
+
+	eff, err := r.applySideEffects(guildID, userID, roleMinus1000XP, "admin", "flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eff.XPBefore != 5000 || eff.XPAfter != 4000 {
+		t.Fatalf("xp %d → %d, want 5000 → 4000", eff.XPBefore, eff.XPAfter)
+	}
+	if eff.LevelBefore != 22 || eff.LevelAfter != 20 {
+		t.Fatalf("level %d → %d, want 22 → 20", eff.LevelBefore, eff.LevelAfter)
+	}
+	if xp, _, _ := store.Get(guildID, userID); xp != 4000 {
+		t.Fatalf("stored xp = %d", xp)
+	}
+	entries, _, err := store.History(context.Background(), guildID, userID, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := entries[0]; e.Delta != -1000 || e.Source != xpstore.SourcePenalty || e.ActorID != "admin" || e.Reason != "flood" {
+		t.Fatalf("ledger entry = %+v", e)
+	}
+}
+
+func TestApplySideEffectsClampsAtZero(t *testing.T) {
+	r, _, _ := setup(t, 300)
+
+	eff, err := r.applySideEffects(guildID, userID, roleMinus1000XP, "admin", "flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eff.XPAfter != 0 || eff.LevelAfter != 1 {
+		t.Fatalf("after = %d xp / lvl %d, want 0 / 1", eff.XPAfter, eff.LevelAfter)
+	}
+}
+
+func TestApplySideEffectsMinus1500RemovesPreviousWarning(t *testing.T) {
+	r, fake, _ := setup(t, 2000)
+
+	eff, err := r.applySideEffects(guildID, userID, roleMinus1500XP, "admin", "again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eff.XPAfter != 500 {
+		t.Fatalf("xp after = %d, want 500", eff.XPAfter)
+	}
+	if eff.RemovedRoleID != roleMinus1000XP {
+		t.Fatalf("removed role = %q", eff.RemovedRoleID)
+	}
+	if roles := fake.MemberRoles(guildID, userID); len(roles) != 0 {
+		t.Fatalf("member still has roles %v", roles)
+	}
+}
+
+func TestApplySideEffectsThirdWarningKicks(t *testing.T) {
+	r, fake, store := setup(t, 9000)
+
+	eff, err := r.applySideEffects(guildID, userID, roleThirdWarn, "admin", "третье")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !eff.Kicked {
+		t.Fatal("expected kick")
+	}
+	if _, ok := fake.Kicked[guildID+":"+userID]; !ok {
+		t.Fatal("member was not kicked")
+	}
+	if xp, _, _ := store.Get(guildID, userID); xp != 0 || eff.LevelAfter != 1 {
+		t.Fatalf("xp must be reset, got %d / lvl %d", xp, eff.LevelAfter)
+	}
+	if eff.XPBefore != 9000 {
+		t.Fatalf("xp before = %d, want 9000", eff.XPBefore)
+	}
+}
+
+func TestApplySideEffectsKickFailure(t *testing.T) {
+	r, fake, _ := setup(t, 9000)
+	fake.Fail("GuildMemberDeleteWithReason", userID, errors.New("missing permissions"))
+
+	eff, err := r.applySideEffects(guildID, userID, roleThirdWarn, "admin", "третье")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if eff.Kicked {
+		t.Fatal("must not report kick")
+	}
+}
+
+func TestApplySideEffectsOrdinaryRole(t *testing.T) {
+	r, fake, _ := setup(t, 100)
+
+	eff, err := r.applySideEffects(guildID, userID, "some-role", "admin", "")
+	if err != nil || eff != nil {
+		t.Fatalf("ordinary role: eff=%v err=%v", eff, err)
+	}
+	if n := fake.CallCount("GuildMemberRoleRemove") + fake.CallCount("GuildMemberDeleteWithReason"); n != 0 {
+		t.Fatalf("unexpected REST calls: %d", n)
+	}
+}
+
+func TestApplySideEffectsUsesConfiguredAmounts(t *testing.T) {
+	r, _, _ := setup(t, 5000)
+	r.Penalties.Warn1XP = 250
+
+	eff, err := r.applySideEffects(guildID, userID, roleMinus1000XP, "admin", "flood")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eff.XPAfter != 4750 {
+		t.Fatalf("xp after = %d, want 4750", eff.XPAfter)
+	}
+}
+
+func TestApplySideEffectsOtherGuildHasNoPenalties(t *testing.T) {
+	r, _, _ := setup(t, 5000)
+
+	eff, err := r.applySideEffects("g2", userID, roleMinus1000XP, "admin", "flood")
+	if err != nil || eff != nil {
+		t.Fatalf("unconfigured guild: eff=%v err=%v", eff, err)
+	}
+}
+
+func TestTierRolesAreProtected(t *testing.T) {
+	r, _, _ := setup(t, 0)
+	r.ProtectedRoleIDs = toSet([]string{"static"})
+	if err := r.Guilds.SetTier(context.Background(), guildID, guildcfg.Tier{MinLevel: 10, RoleID: "lvl10"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for role, want := range map[string]bool{"static": true, "lvl10": true, roleMinus1000XP: false} {
+		if got := r.isProtected(guildID, role); got != want {
+			t.Errorf("isProtected(%s) = %v, want %v", role, got, want)
+		}
+	}
+	if r.isProtected("other-guild", "lvl10") {
+		t.Error("tier of another guild must not be protected here")
+	}
+}
+
+func TestConcurrentPenaltiesAreNotLost(t *testing.T) {
+	r, _, store := setup(t, 10000)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, _, _, err := r.deductXP(guildID, userID, 1000, xpstore.Meta{Source: xpstore.SourcePenalty}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if xp, _, _ := store.Get(guildID, userID); xp != 5000 {
+		t.Fatalf("xp = %d, want 5000", xp)
+	}
+}
+
+func TestPenaltyReappliesLevelRoles(t *testing.T) {
+	r, _, _ := setup(t, 5000)
+	var got []int
+	r.SetLevelRoles(func(g, u string, level int) error {
+		if g != guildID || u != userID {
+			t.Errorf("LevelRoles(%s, %s)", g, u)
+		}
+		got = append(got, level)
+		return nil
+	})
+
+	if _, err := r.applySideEffects(guildID, userID, roleMinus1000XP, "admin", "flood"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != 20 {
+		t.Fatalf("level roles recalculated for %v, want [20]", got)
+	}
+}
@@ -0,0 +1,43 @@
+package guildcfg
+
+import "context"
+
+// Decay — затухание XP неактивных участников (колонки decay_* в guild_settings):
+// раз в неделю снимается Percent% XP у тех, кто AfterDays дней не писал и не сидел в войсе,
+// но не ниже уровня FloorLevel. Percent = 0 — выключено.
+type Decay struct {
+	Percent    float64
+	AfterDays  int
+	FloorLevel int
+}
+
+// Enabled — политика задана целиком.
+func (d Decay) Enabled() bool { return d.Percent > 0 && d.AfterDays > 0 }
+
+// SetDecay заменяет политику затухания сервера целиком; Decay{} выключает его.
+func (s *Store) SetDecay(ctx context.Context, guildID string, d Decay) error {
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO guild_settings (guild_id, decay_percent, decay_after_days, decay_floor_level, settings_seeded)
+VALUES ($1, $2, $3, $4, true)
+ON CONFLICT (guild_id) DO UPDATE SET
+    decay_percent     = EXCLUDED.decay_percent,
+    decay_after_days  = EXCLUDED.decay_after_days,
+    decay_floor_level = EXCLUDED.decay_floor_level,
+    settings_seeded   = true,
+    updated_at        = now()`,
+			guildID, d.Percent, d.AfterDays, d.FloorLevel,
+		); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	g.Decay, g.settingsSeeded = d, true
+	return nil
+}
@@ -0,0 +1,320 @@
+package guildcfg
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Настройки одного сервера (строка guild_settings).
+type Guild struct {
+	GuildID           string
+	MuteRoleID        string
+	LogChannelID      string
+	KeepCategoryID    string
+	WelcomeChannelID  string
+	SelfRoleID        string
+	AfkChannelID      string
+	AnnounceChannelID string             // объявления бота: XP-бусты и т.п.
+	Tiers             []Tier             // роли за уровни (level_tiers), по возрастанию MinLevel
+	TierMode          string             // как выдаются ступени: TierReplace ("" — он же), TierStack, TierStackExcept
+	TierExclude       []string           // для TierStackExcept: ступени, которые не копятся
+	Multipliers       map[string]float64 // множители XP (xp_multipliers): канал/категория → множитель
+	RoleMultipliers   map[string]float64 // множители XP за роли (role_multipliers)
+	RoleStacking      string             // как складываются роли: StackMax ("" — он же) или StackMultiply
+	LevelUp           LevelUp            // объявления о новом уровне
+	Decay             Decay              // затухание XP неактивных участников
+	Penalties         PenaltyRoles
+	Locale            string // язык бота на сервере ("ru", "en"); "" — по локали пользователя
+
+	settingsSeeded bool // без БД: настройки слэш-команд уже взяты из конфига или заданы командой
+}
+
+// Роли-наказания для /give: выдача такой роли снимает XP или кикает.
+type PenaltyRoles struct {
+	Warn1 string // 1-е предупреждение: снять XP
+	Warn2 string // 2-е предупреждение: снять больше XP и убрать Warn1
+	Kick  string // 3-е предупреждение: обнулить XP и кикнуть
+}
+
+// Store — кэш настроек серверов поверх таблицы guild_settings.
+// Без БД работает как чисто in-memory хранилище (только то, что положили через Apply).
+type Store struct {
+	DB *pgxpool.Pool
+
+	mu     sync.RWMutex
+	guilds map[string]*Guild
+}
+
+func NewStore(db *pgxpool.Pool) *Store {
+	return &Store{DB: db, guilds: make(map[string]*Guild)}
+}
+
+// Get — настройки из кэша; nil, если сервер ещё не загружен.
+// Возвращается копия вместе со ступенями и множителями, её можно менять.
+func (s *Store) Get(guildID string) *Guild {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		return nil
+	}
+	cp := *g
+	cp.Tiers = slices.Clone(g.Tiers)
+	cp.TierExclude = slices.Clone(g.TierExclude)
+	cp.Multipliers = maps.Clone(g.Multipliers)
+	cp.RoleMultipliers = maps.Clone(g.RoleMultipliers)
+	return &cp
+}
+
+// IDs — все серверы, которые сейчас есть в кэше.
+func (s *Store) IDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]string, 0, len(s.guilds))
+	for id := range s.guilds {
+		out = append(out, id)
+	}
+	return out
+}
+
+const selectCols = `guild_id, mute_role_id, log_channel_id, keep_category_id, welcome_channel_id,
+       self_role_id, afk_channel_id, announce_channel_id, role_stacking, penalty_warn1_role_id, penalty_warn2_role_id, penalty_kick_role_id, locale,
+       levelup_mode, levelup_channel_id, levelup_style, levelup_template, levelup_milestone_template,
+       decay_percent, decay_after_days, decay_floor_level, tier_mode, tier_exclude`
+
+func scanGuild(row pgx.Row) (*Guild, error) {
+	var g Guild
+	err := row.Scan(
+		&g.GuildID, &g.MuteRoleID, &g.LogChannelID, &g.KeepCategoryID, &g.WelcomeChannelID,
+		&g.SelfRoleID, &g.AfkChannelID, &g.AnnounceChannelID, &g.RoleStacking,
+		&g.Penalties.Warn1, &g.Penalties.Warn2, &g.Penalties.Kick, &g.Locale,
+		&g.LevelUp.Mode, &g.LevelUp.ChannelID, &g.LevelUp.Style, &g.LevelUp.Template, &g.LevelUp.MilestoneTemplate,
+		&g.Decay.Percent, &g.Decay.AfterDays, &g.Decay.FloorLevel, &g.TierMode, &g.TierExclude,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+// LoadAll перечитывает все серверы из БД.
+func (s *Store) LoadAll(ctx context.Context) error {
+	if s.DB == nil {
+		return nil
+	}
+	rows, err := s.DB.Query(ctx, `SELECT `+selectCols+` FROM guild_settings`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	loaded := make(map[string]*Guild)
+	for rows.Next() {
+		g, err := scanGuild(rows)
+		if err != nil {
+			return err
+		}
+		loaded[g.GuildID] = g
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	tiers, err := loadTiers(ctx, s.DB, "")
+	if err != nil {
+		return err
+	}
+	for id, t := range tiers {
+		if g, ok := loaded[id]; ok {
+			g.Tiers = t
+		}
+	}
+	mults, err := loadMultipliers(ctx, s.DB, "xp_multipliers", "channel_id", "")
+	if err != nil {
+		return err
+	}
+	roleMults, err := loadMultipliers(ctx, s.DB, "role_multipliers", "role_id", "")
+	if err != nil {
+		return err
+	}
+	for id, g := range loaded {
+		g.Multipliers = mults[id]
+		g.RoleMultipliers = roleMults[id]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, g := range loaded {
+		s.guilds[id] = g
+	}
+	return nil
+}
+
+// Ensure гарантирует, что у сервера есть строка настроек (пустая, если его ещё не настраивали),
+// перечитывает её из БД и кладёт в кэш. Вызывается на GuildCreate.
+func (s *Store) Ensure(ctx context.Context, guildID string) (*Guild, error) {
+	if s.DB == nil {
+		if g := s.Get(guildID); g != nil {
+			return g, nil
+		}
+		s.put(&Guild{GuildID: guildID})
+		return s.Get(guildID), nil
+	}
+
+	if _, err := s.DB.Exec(ctx,
+		`INSERT INTO guild_settings (guild_id) VALUES ($1) ON CONFLICT (guild_id) DO NOTHING`,
+		guildID,
+	); err != nil {
+		return nil, err
+	}
+	g, err := scanGuild(s.DB.QueryRow(ctx, `SELECT `+selectCols+` FROM guild_settings WHERE guild_id=$1`, guildID))
+	if err != nil {
+		return nil, err
+	}
+	tiers, err := loadTiers(ctx, s.DB, guildID)
+	if err != nil {
+		return nil, err
+	}
+	g.Tiers = tiers[guildID]
+	mults, err := loadMultipliers(ctx, s.DB, "xp_multipliers", "channel_id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	roleMults, err := loadMultipliers(ctx, s.DB, "role_multipliers", "role_id", guildID)
+	if err != nil {
+		return nil, err
+	}
+	g.Multipliers = mults[guildID]
+	g.RoleMultipliers = roleMults[guildID]
+	s.put(g)
+	return s.Get(guildID), nil
+}
+
+// Apply записывает настройки сервера из конфиг-файла.
+// Поля, которых нет в слэш-командах (каналы, роль мьюта, роли-наказания, язык), берутся
+// из конфига как есть: пустое значение очищает настройку. Настройки, которыми управляют
+// команды (сложение ролей, объявления о новом уровне, затухание, режим ступеней), переносятся
+// один раз — при первом Apply, где конфиг задаёт хоть одну из них, — и дальше рестарт их не трогает.
+// Ступени g.Tiers тоже переносятся в level_tiers один раз — если у сервера ступеней ещё нет;
+// дальше ими управляет только /levelroles.
+func (s *Store) Apply(ctx context.Context, g Guild) error {
+	if s.DB == nil {
+		if cur := s.Get(g.GuildID); cur != nil {
+			g = merge(*cur, g)
+		} else {
+			g = merge(Guild{GuildID: g.GuildID}, g)
+		}
+		s.put(&g)
+		return nil
+	}
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	_, err = tx.Exec(ctx, `
+INSERT INTO guild_settings (guild_id, mute_role_id, log_channel_id, keep_category_id, welcome_channel_id,
+                            self_role_id, afk_channel_id, announce_channel_id, role_stacking,
+                            penalty_warn1_role_id, penalty_warn2_role_id, penalty_kick_role_id, locale,
+                            levelup_mode, levelup_channel_id, levelup_style, levelup_template, levelup_milestone_template,
+                            decay_percent, decay_after_days, decay_floor_level, tier_mode, tier_exclude, settings_seeded)
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,COALESCE($23::text[], '{}'),$24)
+ON CONFLICT (guild_id) DO UPDATE SET
+    mute_role_id          = EXCLUDED.mute_role_id,
+    log_channel_id        = EXCLUDED.log_channel_id,
+    keep_category_id      = EXCLUDED.keep_category_id,
+    welcome_channel_id    = EXCLUDED.welcome_channel_id,
+    self_role_id          = EXCLUDED.self_role_id,
+    afk_channel_id        = EXCLUDED.afk_channel_id,
+    announce_channel_id   = EXCLUDED.announce_channel_id,
+    penalty_warn1_role_id = EXCLUDED.penalty_warn1_role_id,
+    penalty_warn2_role_id = EXCLUDED.penalty_warn2_role_id,
+    penalty_kick_role_id  = EXCLUDED.penalty_kick_role_id,
+    locale                = EXCLUDED.locale,
+    -- настройки слэш-команд: только если ещё не переносились и конфиг их задаёт
+    role_stacking         = CASE WHEN `+seedIf+` THEN EXCLUDED.role_stacking ELSE guild_settings.role_stacking END,
+    levelup_mode          = CASE WHEN `+seedIf+` THEN EXCLUDED.levelup_mode ELSE guild_settings.levelup_mode END,
+    levelup_channel_id    = CASE WHEN `+seedIf+` THEN EXCLUDED.levelup_channel_id ELSE guild_settings.levelup_channel_id END,
+    levelup_style         = CASE WHEN `+seedIf+` THEN EXCLUDED.levelup_style ELSE guild_settings.levelup_style END,
+    levelup_template      = CASE WHEN `+seedIf+` THEN EXCLUDED.levelup_template ELSE guild_settings.levelup_template END,
+    levelup_milestone_template = CASE WHEN `+seedIf+` THEN EXCLUDED.levelup_milestone_template ELSE guild_settings.levelup_milestone_template END,
+    decay_percent         = CASE WHEN `+seedIf+` THEN EXCLUDED.decay_percent ELSE guild_settings.decay_percent END,
+    decay_after_days      = CASE WHEN `+seedIf+` THEN EXCLUDED.decay_after_days ELSE guild_settings.decay_after_days END,
+    decay_floor_level     = CASE WHEN `+seedIf+` THEN EXCLUDED.decay_floor_level ELSE guild_settings.decay_floor_level END,
+    tier_mode             = CASE WHEN `+seedIf+` THEN EXCLUDED.tier_mode ELSE guild_settings.tier_mode END,
+    tier_exclude          = CASE WHEN `+seedIf+` THEN EXCLUDED.tier_exclude ELSE guild_settings.tier_exclude END,
+    settings_seeded       = guild_settings.settings_seeded OR EXCLUDED.settings_seeded,
+    updated_at            = now()`,
+		g.GuildID, g.MuteRoleID, g.LogChannelID, g.KeepCategoryID, g.WelcomeChannelID,
+		g.SelfRoleID, g.AfkChannelID, g.AnnounceChannelID, g.RoleStacking,
+		g.Penalties.Warn1, g.Penalties.Warn2, g.Penalties.Kick, g.Locale,
+		g.LevelUp.Mode, g.LevelUp.ChannelID, g.LevelUp.Style, g.LevelUp.Template, g.LevelUp.MilestoneTemplate,
+		g.Decay.Percent, g.Decay.AfterDays, g.Decay.FloorLevel, g.TierMode, g.TierExclude, g.hasCommandSettings(),
+	)
+	if err != nil {
+		return err
+	}
+	if len(g.Tiers) > 0 {
+		if err := seedTiers(ctx, tx, g.GuildID, g.Tiers); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// seedIf — условие в ON CONFLICT у Apply: строку ещё не заполняли настройками слэш-команд,
+// а конфиг их задаёт.
+const seedIf = `NOT guild_settings.settings_seeded AND EXCLUDED.settings_seeded`
+
+// hasCommandSettings — задаёт ли конфиг хоть одну настройку, которой управляют слэш-команды.
+func (g Guild) hasCommandSettings() bool {
+	return g.RoleStacking != "" || g.TierMode != "" || len(g.TierExclude) > 0 ||
+		g.LevelUp != (LevelUp{}) || g.Decay != (Decay{})
+}
+
+// merge — то же правило, что и в Apply, для режима без БД.
+func merge(cur, in Guild) Guild {
+	cur.MuteRoleID = in.MuteRoleID
+	cur.LogChannelID = in.LogChannelID
+	cur.KeepCategoryID = in.KeepCategoryID
+	cur.WelcomeChannelID = in.WelcomeChannelID
+	cur.SelfRoleID = in.SelfRoleID
+	cur.AfkChannelID = in.AfkChannelID
+	cur.AnnounceChannelID = in.AnnounceChannelID
+	cur.Penalties = in.Penalties
+	cur.Locale = in.Locale
+	if len(cur.Tiers) == 0 {
+		for _, t := range in.Tiers {
+			cur.Tiers = withTier(cur.Tiers, t)
+		}
+	}
+	if !cur.settingsSeeded && in.hasCommandSettings() {
+		cur.RoleStacking = in.RoleStacking
+		cur.TierMode = in.TierMode
+		cur.TierExclude = slices.Clone(in.TierExclude)
+		cur.LevelUp = in.LevelUp
+		cur.Decay = in.Decay
+		cur.settingsSeeded = true
+	}
+	return cur
+}
+
+// Locale — язык, принудительно заданный серверу ("" — не задан).
+func (s *Store) Locale(guildID string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if g, ok := s.guilds[guildID]; ok {
+		return g.Locale
+	}
+	return ""
+}
+
+func (s *Store) put(g *Guild) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.guilds[g.GuildID] = g
+}
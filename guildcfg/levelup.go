@@ -0,0 +1,57 @@
+package guildcfg
+
+import "context"
+
+// Куда объявлять о новом уровне участника.
+const (
+	LevelUpOff     = "off"     // никуда ("" — он же)
+	LevelUpChannel = "channel" // в LevelUp.ChannelID, а если он не задан — в AnnounceChannelID
+	LevelUpSame    = "same"    // туда, где заработан уровень: канал сообщения или чат войса
+	LevelUpDM      = "dm"      // в личку участнику
+)
+
+// Как выглядит объявление.
+const (
+	LevelUpText  = "text" // обычное сообщение ("" — оно же)
+	LevelUpEmbed = "embed"
+)
+
+// LevelUp — объявления о новом уровне (колонки levelup_* в guild_settings).
+// В шаблонах подставляются {user}, {level}, {role} и {xp}; пустой шаблон — стандартный текст.
+type LevelUp struct {
+	Mode              string
+	ChannelID         string
+	Style             string
+	Template          string // обычный новый уровень
+	MilestoneTemplate string // уровень, за который выдаётся новая роль-ступень
+}
+
+// SetLevelUp заменяет настройки объявлений сервера целиком.
+func (s *Store) SetLevelUp(ctx context.Context, guildID string, lu LevelUp) error {
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO guild_settings (guild_id, levelup_mode, levelup_channel_id, levelup_style, levelup_template, levelup_milestone_template, settings_seeded)
+VALUES ($1, $2, $3, $4, $5, $6, true)
+ON CONFLICT (guild_id) DO UPDATE SET
+    levelup_mode               = EXCLUDED.levelup_mode,
+    levelup_channel_id         = EXCLUDED.levelup_channel_id,
+    levelup_style              = EXCLUDED.levelup_style,
+    levelup_template           = EXCLUDED.levelup_template,
+    levelup_milestone_template = EXCLUDED.levelup_milestone_template,
+    settings_seeded            = true,
+    updated_at                 = now()`,
+			guildID, lu.Mode, lu.ChannelID, lu.Style, lu.Template, lu.MilestoneTemplate,
+		); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	g.LevelUp, g.settingsSeeded = lu, true
+	return nil
+}
@@ -0,0 +1,74 @@
+package guildcfg
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLevelUpSettings(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	// из конфига настройки берутся один раз, следующий Apply их не меняет
+	want := LevelUp{Mode: LevelUpChannel, Template: "{user}: {level}"}
+	if err := s.Apply(ctx, Guild{GuildID: "g", LevelUp: want}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Apply(ctx, Guild{GuildID: "g", LevelUp: LevelUp{Style: LevelUpEmbed}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("g").LevelUp; got != want {
+		t.Fatalf("after Apply: %+v, want %+v", got, want)
+	}
+
+	// из /levelup: настройки заменяются целиком, пустой шаблон — стандартный
+	want = LevelUp{Mode: LevelUpDM}
+	if err := s.SetLevelUp(ctx, "g", want); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("g").LevelUp; got != want {
+		t.Fatalf("after SetLevelUp: %+v, want %+v", got, want)
+	}
+	// рестарт не возвращает значения из конфига
+	if err := s.Apply(ctx, Guild{GuildID: "g", LevelUp: LevelUp{Mode: LevelUpChannel}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("g").LevelUp; got != want {
+		t.Fatalf("after restart: %+v, want %+v", got, want)
+	}
+}
+
+func TestDecaySettings(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.Apply(ctx, Guild{GuildID: "g", Decay: Decay{Percent: 5, AfterDays: 30}}); err != nil {
+		t.Fatal(err)
+	}
+	want := Decay{Percent: 5, AfterDays: 30}
+	if got := s.Get("g").Decay; got != want || !got.Enabled() {
+		t.Fatalf("after Apply: %+v, want %+v", got, want)
+	}
+	if err := s.SetDecay(ctx, "g", Decay{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Apply(ctx, Guild{GuildID: "g", Decay: Decay{Percent: 5, AfterDays: 30}}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Get("g").Decay.Enabled() {
+		t.Fatal("decay turned off by /xpdecay came back after restart")
+	}
+}
+
+func TestApplyConfigOnlyFields(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.Apply(ctx, Guild{GuildID: "g", MuteRoleID: "mute", AnnounceChannelID: "news", Locale: "en"}); err != nil {
+		t.Fatal(err)
+	}
+	// полей без слэш-команд конфиг хозяин: пустое значение очищает настройку
+	if err := s.Apply(ctx, Guild{GuildID: "g", MuteRoleID: "mute2"}); err != nil {
+		t.Fatal(err)
+	}
+	if g := s.Get("g"); g.MuteRoleID != "mute2" || g.AnnounceChannelID != "" || g.Locale != "" {
+		t.Fatalf("after Apply: %+v", g)
+	}
+}
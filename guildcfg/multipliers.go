@@ -0,0 +1,203 @@
+package guildcfg
+
+import (
+	"context"
+	"maps"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SetMultiplier задаёт множитель XP для канала или категории (0 — без XP).
+func (s *Store) SetMultiplier(ctx context.Context, guildID, channelID string, m float64) error {
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO xp_multipliers (guild_id, channel_id, multiplier) VALUES ($1, $2, $3)
+ON CONFLICT (guild_id, channel_id) DO UPDATE SET multiplier = EXCLUDED.multiplier, updated_at = now()`,
+			guildID, channelID, m,
+		); err != nil {
+			return err
+		}
+	}
+	s.updateMultipliers(guildID, func(ms map[string]float64) { ms[channelID] = m })
+	return nil
+}
+
+// ClearMultiplier возвращает каналу множитель по умолчанию; false — он и не был задан.
+func (s *Store) ClearMultiplier(ctx context.Context, guildID, channelID string) (bool, error) {
+	if s.DB != nil {
+		tag, err := s.DB.Exec(ctx, `DELETE FROM xp_multipliers WHERE guild_id=$1 AND channel_id=$2`, guildID, channelID)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	} else if _, ok := s.Multiplier(guildID, channelID); !ok {
+		return false, nil
+	}
+	s.updateMultipliers(guildID, func(ms map[string]float64) { delete(ms, channelID) })
+	return true, nil
+}
+
+// Multiplier — множитель, заданный именно этому каналу/категории.
+func (s *Store) Multiplier(guildID, channelID string) (float64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		return 0, false
+	}
+	m, ok := g.Multipliers[channelID]
+	return m, ok
+}
+
+// HasMultipliers — задан ли на сервере хоть один множитель (иначе каналы можно не разбирать).
+func (s *Store) HasMultipliers(guildID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.guilds[guildID]
+	return ok && len(g.Multipliers) > 0
+}
+
+// updateMultipliers заменяет карту множителей сервера изменённой копией.
+func (s *Store) updateMultipliers(guildID string, fn func(map[string]float64)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	ms := maps.Clone(g.Multipliers)
+	if ms == nil {
+		ms = make(map[string]float64)
+	}
+	fn(ms)
+	g.Multipliers = ms
+}
+
+// loadMultipliers — карты множителей из table (xp_multipliers или role_multipliers):
+// guildID → keyCol → множитель; guildID == "" — по всем серверам.
+func loadMultipliers(ctx context.Context, q interface {
+	Query(context.Context, string, ...any) (pgx.Rows, error)
+}, table, keyCol, guildID string) (map[string]map[string]float64, error) {
+	rows, err := q.Query(ctx, `SELECT guild_id, `+keyCol+`, multiplier FROM `+table+` WHERE $1 = '' OR guild_id = $1`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]map[string]float64)
+	for rows.Next() {
+		var gid, key string
+		var m float64
+		if err := rows.Scan(&gid, &key, &m); err != nil {
+			return nil, err
+		}
+		if out[gid] == nil {
+			out[gid] = make(map[string]float64)
+		}
+		out[gid][key] = m
+	}
+	return out, rows.Err()
+}
+
+// Как складываются множители нескольких ролей участника.
+const (
+	StackMax      = "max"      // действует сильнейшая роль (по умолчанию)
+	StackMultiply = "multiply" // множители перемножаются
+)
+
+// RoleMultiplier — множитель XP участника с ролями roles (1 — ни одна роль не настроена).
+func (g *Guild) RoleMultiplier(roles []string) float64 {
+	m, found := 1.0, false
+	for _, id := range roles {
+		rm, ok := g.RoleMultipliers[id]
+		if !ok {
+			continue
+		}
+		switch {
+		case g.RoleStacking == StackMultiply:
+			m *= rm
+		case !found || rm > m:
+			m = rm
+		}
+		found = true
+	}
+	return m
+}
+
+// SetRoleMultiplier задаёт множитель XP для роли (0 — без XP).
+func (s *Store) SetRoleMultiplier(ctx context.Context, guildID, roleID string, m float64) error {
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO role_multipliers (guild_id, role_id, multiplier) VALUES ($1, $2, $3)
+ON CONFLICT (guild_id, role_id) DO UPDATE SET multiplier = EXCLUDED.multiplier, updated_at = now()`,
+			guildID, roleID, m,
+		); err != nil {
+			return err
+		}
+	}
+	s.updateRoleMultipliers(guildID, func(ms map[string]float64) { ms[roleID] = m })
+	return nil
+}
+
+// ClearRoleMultiplier убирает множитель роли; false — он и не был задан.
+func (s *Store) ClearRoleMultiplier(ctx context.Context, guildID, roleID string) (bool, error) {
+	if s.DB != nil {
+		tag, err := s.DB.Exec(ctx, `DELETE FROM role_multipliers WHERE guild_id=$1 AND role_id=$2`, guildID, roleID)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	} else if g := s.Get(guildID); g == nil || !hasKey(g.RoleMultipliers, roleID) {
+		return false, nil
+	}
+	s.updateRoleMultipliers(guildID, func(ms map[string]float64) { delete(ms, roleID) })
+	return true, nil
+}
+
+// SetRoleStacking задаёт политику сложения ролей: StackMax или StackMultiply.
+func (s *Store) SetRoleStacking(ctx context.Context, guildID, policy string) error {
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO guild_settings (guild_id, role_stacking, settings_seeded) VALUES ($1, $2, true)
+ON CONFLICT (guild_id) DO UPDATE SET role_stacking = EXCLUDED.role_stacking, settings_seeded = true, updated_at = now()`,
+			guildID, policy,
+		); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	g.RoleStacking, g.settingsSeeded = policy, true
+	return nil
+}
+
+func hasKey(m map[string]float64, k string) bool {
+	_, ok := m[k]
+	return ok
+}
+
+func (s *Store) updateRoleMultipliers(guildID string, fn func(map[string]float64)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	ms := maps.Clone(g.RoleMultipliers)
+	if ms == nil {
+		ms = make(map[string]float64)
+	}
+	fn(ms)
+	g.RoleMultipliers = ms
+}
@@ -0,0 +1,63 @@
+package guildcfg
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRoleMultiplierStacking(t *testing.T) {
+	g := &Guild{RoleMultipliers: map[string]float64{"vip": 1.5, "booster": 2, "muted": 0}}
+	cases := []struct {
+		stacking string
+		roles    []string
+		want     float64
+	}{
+		{"", nil, 1},
+		{"", []string{"other"}, 1},
+		{"", []string{"vip"}, 1.5},
+		{StackMax, []string{"vip", "booster", "other"}, 2},
+		{StackMax, []string{"vip", "muted"}, 1.5},
+		{StackMultiply, []string{"vip", "booster"}, 3},
+		{StackMultiply, []string{"vip", "muted"}, 0},
+	}
+	for _, tc := range cases {
+		g.RoleStacking = tc.stacking
+		if got := g.RoleMultiplier(tc.roles); got != tc.want {
+			t.Errorf("%q %v: got %v, want %v", tc.stacking, tc.roles, got, tc.want)
+		}
+	}
+
+	g.RoleMultipliers = map[string]float64{"slow": 0.5}
+	g.RoleStacking = StackMax
+	if got := g.RoleMultiplier([]string{"slow"}); got != 0.5 {
+		t.Fatalf("single role below 1: got %v, want 0.5", got)
+	}
+}
+
+func TestSetClearRoleMultiplier(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.SetRoleMultiplier(ctx, "g", "vip", 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetRoleStacking(ctx, "g", StackMultiply); err != nil {
+		t.Fatal(err)
+	}
+	before := s.Get("g")
+	if err := s.SetRoleMultiplier(ctx, "g", "booster", 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(before.RoleMultipliers) != 1 {
+		t.Fatal("snapshot must not see later changes")
+	}
+	if got := s.Get("g").RoleMultiplier([]string{"vip", "booster"}); got != 6 {
+		t.Fatalf("multiply: got %v, want 6", got)
+	}
+
+	if ok, err := s.ClearRoleMultiplier(ctx, "g", "vip"); err != nil || !ok {
+		t.Fatalf("clear = %v, %v", ok, err)
+	}
+	if ok, _ := s.ClearRoleMultiplier(ctx, "g", "vip"); ok {
+		t.Fatal("second clear must report missing multiplier")
+	}
+}
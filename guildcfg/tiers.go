@@ -0,0 +1,203 @@
+package guildcfg
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Tier — ступень ролей за уровень: с уровня MinLevel участнику положена роль RoleID.
+type Tier struct {
+	MinLevel int
+	RoleID   string
+}
+
+// Как выдаются роли за уровни (tier_mode).
+const (
+	TierReplace     = "replace"      // только старшая заработанная ступень ("" — он же)
+	TierStack       = "stack"        // все заработанные ступени
+	TierStackExcept = "stack_except" // все заработанные, кроме TierExclude: их снимают, когда заработана ступень выше
+)
+
+// TierFor — роль за уровень level: ступень с наибольшим MinLevel <= level ("" — ни одной).
+// tiers отсортированы по MinLevel.
+func TierFor(tiers []Tier, level int) string {
+	want := ""
+	for _, t := range tiers {
+		if t.MinLevel > level {
+			break
+		}
+		want = t.RoleID
+	}
+	return want
+}
+
+// withTier — новый срез со ступенью t: на уровне MinLevel может быть одна роль,
+// и одна роль — только на одной ступени. Исходный срез не меняется.
+func withTier(tiers []Tier, t Tier) []Tier {
+	out := make([]Tier, 0, len(tiers)+1)
+	for _, x := range tiers {
+		if x.MinLevel != t.MinLevel && x.RoleID != t.RoleID {
+			out = append(out, x)
+		}
+	}
+	out = append(out, t)
+	slices.SortFunc(out, func(a, b Tier) int { return a.MinLevel - b.MinLevel })
+	return out
+}
+
+func withoutRole(tiers []Tier, roleID string) []Tier {
+	return slices.DeleteFunc(slices.Clone(tiers), func(t Tier) bool { return t.RoleID == roleID })
+}
+
+// SetTierMode задаёт режим выдачи ролей за уровни и список исключений для TierStackExcept.
+func (s *Store) SetTierMode(ctx context.Context, guildID, mode string, exclude []string) error {
+	exclude = slices.Clone(exclude)
+	if exclude == nil {
+		exclude = []string{}
+	}
+	if s.DB != nil {
+		if _, err := s.DB.Exec(ctx, `
+INSERT INTO guild_settings (guild_id, tier_mode, tier_exclude, settings_seeded) VALUES ($1, $2, $3, true)
+ON CONFLICT (guild_id) DO UPDATE SET
+    tier_mode       = EXCLUDED.tier_mode,
+    tier_exclude    = EXCLUDED.tier_exclude,
+    settings_seeded = true,
+    updated_at      = now()`,
+			guildID, mode, exclude,
+		); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	g.TierMode, g.TierExclude, g.settingsSeeded = mode, exclude, true
+	return nil
+}
+
+// SetTier добавляет ступень или переносит роль на другой уровень.
+func (s *Store) SetTier(ctx context.Context, guildID string, t Tier) error {
+	if s.DB != nil {
+		tx, err := s.DB.Begin(ctx)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback(ctx)
+		if err := upsertTier(ctx, tx, guildID, t); err != nil {
+			return err
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	s.updateTiers(guildID, func(tiers []Tier) []Tier { return withTier(tiers, t) })
+	return nil
+}
+
+// RemoveTier убирает ступень с ролью roleID; false — такой ступени не было.
+func (s *Store) RemoveTier(ctx context.Context, guildID, roleID string) (bool, error) {
+	if s.DB != nil {
+		tag, err := s.DB.Exec(ctx, `DELETE FROM level_tiers WHERE guild_id=$1 AND role_id=$2`, guildID, roleID)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	} else if !s.IsTierRole(guildID, roleID) {
+		return false, nil
+	}
+	s.updateTiers(guildID, func(tiers []Tier) []Tier { return withoutRole(tiers, roleID) })
+	return true, nil
+}
+
+// IsTierRole — выдаётся ли роль за уровень. Такие роли нельзя выдавать и снимать руками.
+func (s *Store) IsTierRole(guildID, roleID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(g.Tiers, func(t Tier) bool { return t.RoleID == roleID })
+}
+
+func (s *Store) updateTiers(guildID string, fn func([]Tier) []Tier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.guilds[guildID]
+	if !ok {
+		g = &Guild{GuildID: guildID}
+		s.guilds[guildID] = g
+	}
+	g.Tiers = fn(g.Tiers)
+}
+
+// seedTiers переносит ступени из конфига в level_tiers, если это ещё не делалось
+// и у сервера нет своих ступеней; в обоих случаях сервер отмечается перенесённым.
+func seedTiers(ctx context.Context, tx pgx.Tx, guildID string, tiers []Tier) error {
+	var has bool
+	err := tx.QueryRow(ctx, `
+UPDATE guild_settings SET tiers_seeded = true WHERE guild_id=$1 AND NOT tiers_seeded
+RETURNING EXISTS (SELECT 1 FROM level_tiers WHERE guild_id=$1)`, guildID).Scan(&has)
+	if errors.Is(err, pgx.ErrNoRows) || has {
+		return nil // уже переносили или ступени настроены через /levelroles
+	}
+	if err != nil {
+		return err
+	}
+	for _, t := range tiers {
+		if err := upsertTier(ctx, tx, guildID, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsertTier(ctx context.Context, tx pgx.Tx, guildID string, t Tier) error {
+	// роль могла стоять на другой ступени — переносим
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM level_tiers WHERE guild_id=$1 AND role_id=$2 AND min_level<>$3`,
+		guildID, t.RoleID, t.MinLevel,
+	); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO level_tiers (guild_id, min_level, role_id) VALUES ($1, $2, $3)
+ON CONFLICT (guild_id, min_level) DO UPDATE SET role_id = EXCLUDED.role_id`,
+		guildID, t.MinLevel, t.RoleID,
+	)
+	return err
+}
+
+// loadTiers — ступени серверов из level_tiers (guildID == "" — всех), по возрастанию уровня.
+func loadTiers(ctx context.Context, q interface {
+	Query(context.Context, string, ...any) (pgx.Rows, error)
+}, guildID string) (map[string][]Tier, error) {
+	rows, err := q.Query(ctx, `
+SELECT guild_id, min_level, role_id FROM level_tiers
+WHERE $1 = '' OR guild_id = $1
+ORDER BY guild_id, min_level`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string][]Tier)
+	for rows.Next() {
+		var gid string
+		var t Tier
+		if err := rows.Scan(&gid, &t.MinLevel, &t.RoleID); err != nil {
+			return nil, err
+		}
+		out[gid] = append(out[gid], t)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,110 @@
+package guildcfg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestTierFor(t *testing.T) {
+	tiers := []Tier{{5, "a"}, {10, "b"}, {30, "c"}}
+	for lvl, want := range map[int]string{1: "", 5: "a", 9: "a", 10: "b", 29: "b", 30: "c", 1000: "c"} {
+		if got := TierFor(tiers, lvl); got != want {
+			t.Errorf("TierFor(%d) = %q, want %q", lvl, got, want)
+		}
+	}
+}
+
+func TestSetTierReplacesLevelAndMovesRole(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	for _, tr := range []Tier{{10, "b"}, {5, "a"}, {10, "b2"}, {20, "a"}} {
+		if err := s.SetTier(ctx, "g", tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []Tier{{10, "b2"}, {20, "a"}}
+	if got := s.Get("g").Tiers; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tiers = %v, want %v", got, want)
+	}
+	if !s.IsTierRole("g", "a") || s.IsTierRole("g", "b") {
+		t.Fatal("IsTierRole out of sync")
+	}
+
+	ok, err := s.RemoveTier(ctx, "g", "a")
+	if err != nil || !ok {
+		t.Fatalf("remove = %v, %v", ok, err)
+	}
+	if ok, _ := s.RemoveTier(ctx, "g", "a"); ok {
+		t.Fatal("second remove must report missing tier")
+	}
+	if got := s.Get("g").Tiers; !reflect.DeepEqual(got, []Tier{{10, "b2"}}) {
+		t.Fatalf("tiers after remove = %v", got)
+	}
+}
+
+func TestApplySeedsTiersOnlyWhenEmpty(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.Apply(ctx, Guild{GuildID: "g", Tiers: []Tier{{25, "cfg25"}, {1, "cfg1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Get("g").Tiers, []Tier{{1, "cfg1"}, {25, "cfg25"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("seeded tiers = %v, want %v", got, want)
+	}
+	// удалённая через /levelroles ступень не возвращается при следующем Apply
+	if _, err := s.RemoveTier(ctx, "g", "cfg25"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Apply(ctx, Guild{GuildID: "g", Tiers: []Tier{{25, "cfg25"}, {1, "cfg1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := s.Get("g").Tiers, []Tier{{1, "cfg1"}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tiers after restart = %v, want %v", got, want)
+	}
+}
+
+func TestTierModeSettings(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.Apply(ctx, Guild{GuildID: "g", TierMode: TierStackExcept, TierExclude: []string{"newbie"}}); err != nil {
+		t.Fatal(err)
+	}
+	// конфиг без этих полей не затирает режим и исключения
+	if err := s.Apply(ctx, Guild{GuildID: "g"}); err != nil {
+		t.Fatal(err)
+	}
+	if g := s.Get("g"); g.TierMode != TierStackExcept || !reflect.DeepEqual(g.TierExclude, []string{"newbie"}) {
+		t.Fatalf("after Apply: %q %v", g.TierMode, g.TierExclude)
+	}
+	if err := s.SetTierMode(ctx, "g", TierStack, nil); err != nil {
+		t.Fatal(err)
+	}
+	if g := s.Get("g"); g.TierMode != TierStack || len(g.TierExclude) != 0 {
+		t.Fatalf("after SetTierMode: %q %v", g.TierMode, g.TierExclude)
+	}
+	if err := s.Apply(ctx, Guild{GuildID: "g", TierMode: TierStackExcept, TierExclude: []string{"newbie"}}); err != nil {
+		t.Fatal(err)
+	}
+	if g := s.Get("g"); g.TierMode != TierStack || len(g.TierExclude) != 0 {
+		t.Fatalf("after restart: %q %v", g.TierMode, g.TierExclude)
+	}
+}
+
+func TestGetReturnsDeepCopy(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(nil)
+	if err := s.Apply(ctx, Guild{GuildID: "g", Tiers: []Tier{{1, "t1"}}, TierMode: TierStackExcept, TierExclude: []string{"t1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetMultiplier(ctx, "g", "chan", 2); err != nil {
+		t.Fatal(err)
+	}
+	g := s.Get("g")
+	g.Tiers[0].RoleID = "changed"
+	g.TierExclude[0] = "changed"
+	g.Multipliers["chan"] = 0
+	if g := s.Get("g"); g.Tiers[0].RoleID != "t1" || g.TierExclude[0] != "t1" || g.Multipliers["chan"] != 2 {
+		t.Fatalf("store changed through Get: %+v", g)
+	}
+}
@@ -0,0 +1,196 @@
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v3"
+)
+
+// Каталоги сообщений: locales/<lang>.yaml, плоский словарь "ключ: текст".
+// Текст — формат для fmt.Sprintf, если в T переданы аргументы.
+//
+//go:embed locales/*.yaml
+var files embed.FS
+
+// Lang — язык каталога.
+type Lang string
+
+const (
+	RU Lang = "ru"
+	EN Lang = "en"
+)
+
+// Default — язык, если ни сервер, ни пользователь не дали подходящей локали.
+const Default = RU
+
+var bundles = mustLoad()
+
+func mustLoad() map[Lang]map[string]string {
+	entries, err := files.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	out := make(map[Lang]map[string]string, len(entries))
+	for _, e := range entries {
+		raw, err := files.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		m := make(map[string]string)
+		if err := yaml.Unmarshal(raw, &m); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		out[Lang(strings.TrimSuffix(e.Name(), ".yaml"))] = m
+	}
+	return out
+}
+
+// T — сообщение key на языке lang. Нет перевода — берём Default, нет и его — сам ключ,
+// чтобы пропуск было видно, а не получить пустую строку.
+func T(lang Lang, key string, args ...any) string {
+	msg, ok := bundles[lang][key]
+	if !ok {
+		if msg, ok = bundles[Default][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Parse — язык по локали Discord ("ru", "en-US", "en-GB") или по значению из настроек ("ru", "en").
+func Parse(locale string) (Lang, bool) {
+	l := strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexByte(l, '-'); i > 0 {
+		l = l[:i]
+	}
+	if _, ok := bundles[Lang(l)]; ok && l != "" {
+		return Lang(l), true
+	}
+	return "", false
+}
+
+// ---- выбор языка ----
+
+var (
+	mu            sync.RWMutex
+	guildOverride func(guildID string) string // язык из настроек сервера (guild_settings.locale)
+	guildLocales  = make(map[string]string)   // guildID → PreferredLocale сервера
+)
+
+// UseGuildOverride подключает источник языка, принудительно заданного серверу.
+func UseGuildOverride(fn func(guildID string) string) {
+	mu.Lock()
+	defer mu.Unlock()
+	guildOverride = fn
+}
+
+// RememberGuild запоминает основной язык сервера (PreferredLocale из GuildCreate) —
+// он используется для сообщений, у которых нет интеракции: логи, приветствия.
+func RememberGuild(guildID string, preferred discordgo.Locale) {
+	mu.Lock()
+	defer mu.Unlock()
+	guildLocales[guildID] = string(preferred)
+}
+
+func override(guildID string) (Lang, bool) {
+	mu.RLock()
+	fn := guildOverride
+	mu.RUnlock()
+	if fn == nil || guildID == "" {
+		return "", false
+	}
+	return Parse(fn(guildID))
+}
+
+// For — язык ответа на интеракцию: настройка сервера → язык пользователя → язык сервера → Default.
+func For(ic *discordgo.Interaction) Lang {
+	if ic == nil {
+		return Default
+	}
+	if l, ok := override(ic.GuildID); ok {
+		return l
+	}
+	if l, ok := Parse(string(ic.Locale)); ok {
+		return l
+	}
+	if ic.GuildLocale != nil {
+		if l, ok := Parse(string(*ic.GuildLocale)); ok {
+			return l
+		}
+	}
+	return Default
+}
+
+// ForGuild — язык сообщений в каналы сервера: настройка сервера → PreferredLocale → Default.
+func ForGuild(guildID string) Lang {
+	if l, ok := override(guildID); ok {
+		return l
+	}
+	mu.RLock()
+	preferred := guildLocales[guildID]
+	mu.RUnlock()
+	if l, ok := Parse(preferred); ok {
+		return l
+	}
+	return Default
+}
+
+// ---- локализация slash-команд ----
+
+// локали Discord, в которые раскладываются каталоги
+var discordLocales = map[Lang][]discordgo.Locale{
+	RU: {discordgo.Russian},
+	EN: {discordgo.EnglishUS, discordgo.EnglishGB},
+}
+
+// Localize заполняет NameLocalizations/DescriptionLocalizations команды и всех её опций
+// из ключей "cmd.<команда>[.<опция>…].name|desc". Нет ключа — поле не трогаем.
+func Localize(def *discordgo.ApplicationCommand) {
+	prefix := "cmd." + def.Name
+	def.NameLocalizations = localizations(prefix+".name", def.NameLocalizations)
+	def.DescriptionLocalizations = localizations(prefix+".desc", def.DescriptionLocalizations)
+	localizeOptions(prefix, def.Options)
+}
+
+func localizeOptions(prefix string, opts []*discordgo.ApplicationCommandOption) {
+	for _, o := range opts {
+		p := prefix + "." + o.Name
+		o.NameLocalizations = *localizations(p+".name", &o.NameLocalizations)
+		o.DescriptionLocalizations = *localizations(p+".desc", &o.DescriptionLocalizations)
+		for _, c := range o.Choices {
+			c.NameLocalizations = *localizations(p+".choice."+fmt.Sprint(c.Value), &c.NameLocalizations)
+		}
+		localizeOptions(p, o.Options)
+	}
+}
+
+func localizations(key string, cur *map[discordgo.Locale]string) *map[discordgo.Locale]string {
+	var m map[discordgo.Locale]string
+	if cur != nil {
+		m = *cur
+	}
+	for lang, locales := range discordLocales {
+		text, ok := bundles[lang][key]
+		if !ok {
+			continue
+		}
+		if m == nil {
+			m = make(map[discordgo.Locale]string)
+		}
+		for _, loc := range locales {
+			m[loc] = text
+		}
+	}
+	if m == nil {
+		return cur
+	}
+	return &m
+}
@@ -0,0 +1,125 @@
+package i18n
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var verbRe = regexp.MustCompile(`%[-+# 0]*\d*(?:\.\d+)?[a-zA-Z%]`)
+
+// каталоги должны совпадать по ключам и по глаголам формата,
+// иначе T на одном из языков выдаст "%!s(MISSING)" или ключ вместо текста
+func TestCatalogsMatch(t *testing.T) {
+	ru, en := bundles[RU], bundles[EN]
+	if len(ru) == 0 || len(en) == 0 {
+		t.Fatal("catalogs not loaded")
+	}
+	for key, text := range ru {
+		// имена команд переводятся только на русский — английские совпадают с Name
+		if strings.HasPrefix(key, "cmd.") && strings.HasSuffix(key, ".name") {
+			continue
+		}
+		other, ok := en[key]
+		if !ok {
+			t.Errorf("en: missing %q", key)
+			continue
+		}
+		if a, b := verbRe.FindAllString(text, -1), verbRe.FindAllString(other, -1); strings.Join(a, " ") != strings.Join(b, " ") {
+			t.Errorf("%q: format verbs differ: ru %v, en %v", key, a, b)
+		}
+	}
+	for key := range en {
+		if _, ok := ru[key]; !ok {
+			t.Errorf("ru: missing %q", key)
+		}
+	}
+}
+
+func TestTFallback(t *testing.T) {
+	if got := T(EN, "common.minutes", 5); got != "5 min" {
+		t.Fatalf("T(en) = %q", got)
+	}
+	if got := T("de", "common.minutes", 5); got != "5 мин." {
+		t.Fatalf("unknown lang must fall back to default, got %q", got)
+	}
+	if got := T(RU, "no.such.key"); got != "no.such.key" {
+		t.Fatalf("missing key = %q", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]Lang{"ru": RU, "en-US": EN, "en-GB": EN, " EN ": EN} {
+		if got, ok := Parse(in); !ok || got != want {
+			t.Errorf("Parse(%q) = %q, %v", in, got, ok)
+		}
+	}
+	for _, in := range []string{"", "de", "-"} {
+		if _, ok := Parse(in); ok {
+			t.Errorf("Parse(%q) must fail", in)
+		}
+	}
+}
+
+func TestForPrecedence(t *testing.T) {
+	t.Cleanup(func() {
+		UseGuildOverride(nil)
+		mu.Lock()
+		guildLocales = make(map[string]string)
+		mu.Unlock()
+	})
+	guildLocale := discordgo.Russian
+	ic := &discordgo.Interaction{GuildID: "g1", Locale: discordgo.EnglishUS, GuildLocale: &guildLocale}
+
+	if got := For(ic); got != EN {
+		t.Fatalf("user locale must win over guild locale, got %q", got)
+	}
+	ic.Locale = "de"
+	if got := For(ic); got != RU {
+		t.Fatalf("unsupported user locale → guild locale, got %q", got)
+	}
+
+	overrides := map[string]string{"g1": "en"}
+	UseGuildOverride(func(id string) string { return overrides[id] })
+	ic.Locale = discordgo.Russian
+	if got := For(ic); got != EN {
+		t.Fatalf("guild setting must win, got %q", got)
+	}
+
+	if got := ForGuild("g2"); got != Default {
+		t.Fatalf("unknown guild = %q", got)
+	}
+	RememberGuild("g2", discordgo.EnglishGB)
+	if got := ForGuild("g2"); got != EN {
+		t.Fatalf("preferred locale ignored, got %q", got)
+	}
+	overrides["g2"] = "ru"
+	if got := ForGuild("g2"); got != RU {
+		t.Fatalf("guild setting must win over preferred locale, got %q", got)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	def := &discordgo.ApplicationCommand{
+		Name: "mute",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Name: "user", Type: discordgo.ApplicationCommandOptionUser},
+		},
+	}
+	Localize(def)
+
+	desc := *def.DescriptionLocalizations
+	for _, loc := range []discordgo.Locale{discordgo.Russian, discordgo.EnglishUS, discordgo.EnglishGB} {
+		if desc[loc] == "" {
+			t.Errorf("no description for %s", loc)
+		}
+	}
+	if desc[discordgo.EnglishUS] != T(EN, "cmd.mute.desc") {
+		t.Errorf("en-US description = %q", desc[discordgo.EnglishUS])
+	}
+	if def.Options[0].DescriptionLocalizations[discordgo.EnglishGB] != T(EN, "cmd.mute.user.desc") {
+		t.Errorf("option not localized: %v", def.Options[0].DescriptionLocalizations)
+	}
+}
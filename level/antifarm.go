@@ -0,0 +1,178 @@
+package level
+
+import (
+	"time"
+
+	"gosha_bot/guildcfg"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voicePresence — кто где сидит в войсе и в каком состоянии. Хранится для всех,
+// а не только для тех, кому капает XP: по ним считается, сколько в канале людей.
+type voicePresence struct {
+	ChannelID string
+	Bot       bool
+	Deaf      bool // сам выключил звук или заглушён сервером
+	SelfMute  bool
+
+	// пребывание в канале ChannelID — то, что пишется в voice_intervals
+	Since time.Time // с какого момента сидит в этом канале
+	XP    int64     // начислено за уже закрытые XP-сессии этого пребывания
+}
+
+func presenceOf(vs *discordgo.VoiceState) voicePresence {
+	p := voicePresence{
+		ChannelID: vs.ChannelID,
+		Deaf:      vs.SelfDeaf || vs.Deaf,
+		SelfMute:  vs.SelfMute,
+	}
+	if vs.Member != nil && vs.Member.User != nil {
+		p.Bot = vs.Member.User.Bot
+	}
+	return p
+}
+
+// listener — участник, который считается «человеком в канале»: не бот и не глухой.
+func (p voicePresence) listener() bool { return !p.Bot && !p.Deaf }
+
+// closedVoice — закрытая при пересчёте XP-сессия (за её хвост надо начислить XP)
+// и/или закончившееся пребывание в канале, которое надо записать в историю.
+type closedVoice struct {
+	key     voiceKey
+	session voiceSession  // ChannelID == "" — XP-сессии не было
+	stay    voicePresence // пребывание, к которому относится сессия
+	left    bool          // пребывание закончилось (ушёл или сменил канал)
+}
+
+// setPresenceLocked запоминает новое состояние участника и пересчитывает, кому капает XP,
+// во всех затронутых каналах (старом и новом): вход, выход, смена канала или mute/deaf
+// одного участника может включить или выключить XP всем остальным.
+// Возвращает закрытые интервалы — XP за них начисляется уже без блокировки. Вызывать под muVoice.
+func (r *Registry) setPresenceLocked(cfg *guildcfg.Guild, key voiceKey, p voicePresence, now time.Time) []closedVoice {
+	prev := r.voiceState[key]
+	left := map[voiceKey]voicePresence{}
+	if st, ok := r.movePresenceLocked(key, p, now); ok {
+		left[key] = st
+	}
+
+	channels := map[string]bool{}
+	for _, id := range []string{prev.ChannelID, p.ChannelID} {
+		if id != "" {
+			channels[id] = true
+		}
+	}
+	keys := []voiceKey{key}
+	for k, other := range r.voiceState {
+		if k != key && k.GuildID == key.GuildID && channels[other.ChannelID] {
+			keys = append(keys, k)
+		}
+	}
+	return r.closeStaysLocked(r.reevaluateLocked(cfg, keys, r.listenersLocked(key.GuildID, channels), now), left)
+}
+
+// movePresenceLocked ставит участнику состояние p (ChannelID == "" — вышел из войса).
+// В том же канале пребывание продолжается; если участник вышел или сменил канал,
+// возвращается закончившееся пребывание. Вызывать под muVoice.
+func (r *Registry) movePresenceLocked(key voiceKey, p voicePresence, now time.Time) (voicePresence, bool) {
+	prev, had := r.voiceState[key]
+	if had && prev.ChannelID == p.ChannelID {
+		p.Since, p.XP = prev.Since, prev.XP
+	} else {
+		p.Since, p.XP = now, 0
+	}
+	if p.ChannelID == "" {
+		delete(r.voiceState, key)
+	} else {
+		r.voiceState[key] = p
+	}
+	return prev, had && prev.ChannelID != p.ChannelID
+}
+
+// closeStaysLocked засчитывает XP закрытых сессий их пребываниям и добавляет к closed
+// закончившиеся пребывания left, в том числе те, где XP не капал. Вызывать под muVoice.
+func (r *Registry) closeStaysLocked(closed []closedVoice, left map[voiceKey]voicePresence) []closedVoice {
+	for i := range closed {
+		c := &closed[i]
+		if st, ok := left[c.key]; ok && st.ChannelID == c.session.ChannelID {
+			delete(left, c.key)
+			st.XP += c.session.XP
+			c.stay, c.left = st, true
+		} else if st, ok := r.voiceState[c.key]; ok && st.ChannelID == c.session.ChannelID {
+			st.XP += c.session.XP
+			r.voiceState[c.key] = st
+			c.stay = st
+		}
+	}
+	for k, st := range left {
+		closed = append(closed, closedVoice{key: k, stay: st, left: true})
+	}
+	return closed
+}
+
+// creditStay засчитывает xp пребыванию участника, если оно ещё то же (since).
+func (r *Registry) creditStay(key voiceKey, since time.Time, xp int64) {
+	r.muVoice.Lock()
+	defer r.muVoice.Unlock()
+	if st, ok := r.voiceState[key]; ok && st.Since.Equal(since) {
+		st.XP += xp
+		r.voiceState[key] = st
+	}
+}
+
+// reevaluateLocked открывает и закрывает сессии keys по текущим voiceState
+// (humans — число людей в каналах). Вызывать под muVoice.
+func (r *Registry) reevaluateLocked(cfg *guildcfg.Guild, keys []voiceKey, humans map[string]int, now time.Time) []closedVoice {
+	var out []closedVoice
+	for _, k := range keys {
+		want := ""
+		if st, ok := r.voiceState[k]; ok && r.earnsVoiceXP(cfg, st, humans[st.ChannelID]) {
+			want = st.ChannelID
+		}
+		cur, tracked := r.voiceJoin[k]
+		if tracked && cur.ChannelID == want {
+			continue // ничего не поменялось — хвост секунд не теряем
+		}
+		if tracked {
+			delete(r.voiceJoin, k)
+			out = append(out, closedVoice{key: k, session: cur})
+		}
+		if want != "" {
+			from := r.sessionStartLocked(k, want, now)
+			r.voiceJoin[k] = voiceSession{From: from, ChannelID: want, Started: from}
+			// засчитанный простой бота — тоже время в канале
+			if st := r.voiceState[k]; from.Before(st.Since) {
+				st.Since = from
+				r.voiceState[k] = st
+			}
+		}
+	}
+	return out
+}
+
+// listenersLocked — сколько людей (не ботов и не глухих) сидит в каждом из каналов (nil — во всех).
+func (r *Registry) listenersLocked(guildID string, channels map[string]bool) map[string]int {
+	n := make(map[string]int, len(channels))
+	for k, p := range r.voiceState {
+		if k.GuildID == guildID && (channels == nil || channels[p.ChannelID]) && p.listener() {
+			n[p.ChannelID]++
+		}
+	}
+	return n
+}
+
+// earnsVoiceXP — капает ли XP участнику в его канале: не AFK, не бот, не глухой,
+// без выключенного микрофона (если xp.voice_no_self_muted) и в канале не меньше xp.voice_min_humans людей.
+func (r *Registry) earnsVoiceXP(cfg *guildcfg.Guild, p voicePresence, humans int) bool {
+	switch {
+	case p.ChannelID == "":
+		return false
+	case cfg.AfkChannelID != "" && p.ChannelID == cfg.AfkChannelID:
+		return false
+	case !p.listener():
+		return false
+	case r.XP.VoiceNoSelfMuted && p.SelfMute:
+		return false
+	}
+	return humans >= max(r.XP.VoiceMinHumans, 1)
+}
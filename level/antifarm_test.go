@@ -0,0 +1,95 @@
+package level
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"gosha_bot/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func setupVoice(t *testing.T) *Registry {
+	t.Helper()
+	r, _ := setup(t)
+	r.XP = config.Default().XP
+	r.voiceJoin = make(map[voiceKey]voiceSession)
+	r.voiceState = make(map[voiceKey]voicePresence)
+	return r
+}
+
+func voiceState(userID, channelID string, edit func(*discordgo.VoiceState)) *discordgo.VoiceStateUpdate {
+	vs := &discordgo.VoiceState{GuildID: guildID, UserID: userID, ChannelID: channelID}
+	if edit != nil {
+		edit(vs)
+	}
+	return &discordgo.VoiceStateUpdate{VoiceState: vs}
+}
+
+// earning — кому сейчас капает XP
+func earning(r *Registry) []string {
+	var out []string
+	for k := range r.voiceJoin {
+		out = append(out, k.UserID)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestVoiceNeedsCompany(t *testing.T) {
+	r := setupVoice(t)
+	bot := func(vs *discordgo.VoiceState) { vs.Member = &discordgo.Member{User: &discordgo.User{Bot: true}} }
+	deaf := func(vs *discordgo.VoiceState) { vs.SelfDeaf = true }
+
+	steps := []struct {
+		ev   *discordgo.VoiceStateUpdate
+		want []string
+	}{
+		{voiceState("a", "vc", nil), nil},                   // один в канале
+		{voiceState("music", "vc", bot), nil},               // бот не в счёт
+		{voiceState("b", "vc", nil), []string{"a", "b"}},    // вдвоём — капает обоим
+		{voiceState("b", "vc", deaf), nil},                  // b оглох: ему нет, и a снова один
+		{voiceState("c", "vc", nil), []string{"a", "c"}},    // c пришёл
+		{voiceState("b", "other", nil), []string{"a", "c"}}, // b ушёл в другой канал, там он один
+		{voiceState("a", "", nil), nil},                     // a вышел — c остался один
+		{voiceState("b", "vc", nil), []string{"b", "c"}},    // b вернулся
+	}
+	for i, st := range steps {
+		r.onVoiceStateUpdate(nil, st.ev)
+		if got := earning(r); !reflect.DeepEqual(got, st.want) {
+			t.Fatalf("step %d (%s → %q): earning %v, want %v", i, st.ev.UserID, st.ev.ChannelID, got, st.want)
+		}
+	}
+}
+
+func TestVoiceSelfMutedOptional(t *testing.T) {
+	muted := func(vs *discordgo.VoiceState) { vs.SelfMute = true }
+	for _, noMuted := range []bool{false, true} {
+		r := setupVoice(t)
+		r.XP.VoiceNoSelfMuted = noMuted
+		r.onVoiceStateUpdate(nil, voiceState("a", "vc", nil))
+		r.onVoiceStateUpdate(nil, voiceState("b", "vc", muted))
+
+		want := []string{"a", "b"}
+		if noMuted {
+			want = []string{"a"} // b без микрофона, но в счёт людей в канале идёт
+		}
+		if got := earning(r); !reflect.DeepEqual(got, want) {
+			t.Errorf("voice_no_self_muted=%v: earning %v, want %v", noMuted, got, want)
+		}
+	}
+}
+
+func TestVoiceUnchangedStateKeepsInterval(t *testing.T) {
+	r := setupVoice(t)
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", nil))
+	r.onVoiceStateUpdate(nil, voiceState("b", "vc", nil))
+	from := r.voiceJoin[voiceKey{guildID, "a"}].From
+
+	// b включил/выключил микрофон — у a интервал не перезапускается
+	r.onVoiceStateUpdate(nil, voiceState("b", "vc", func(vs *discordgo.VoiceState) { vs.SelfMute = true }))
+	if got := r.voiceJoin[voiceKey{guildID, "a"}].From; !got.Equal(from) {
+		t.Fatalf("interval restarted: %v → %v", from, got)
+	}
+}
@@ -0,0 +1,160 @@
+package level
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gosha_bot/config"
+)
+
+// Причины, по которым сообщение не даёт XP (метка reason в gosha_messages_skipped_total).
+const (
+	skipEmojiLinks = "emoji_links"
+	skipTooShort   = "too_short"
+	skipFewWords   = "few_words"
+	skipDuplicate  = "duplicate"
+)
+
+const (
+	recentPerUser   = 3                // с каким числом последних сообщений автора сравниваем
+	recentWindow    = 10 * time.Minute // повтор через 10 минут — уже не спам
+	similarityRunes = 200              // дальше сообщения не сравниваем (Левенштейн — O(n·m))
+)
+
+// ссылки, кастомные эмодзи <:name:id>, упоминания <@id> <@&id> <#id>, таймстемпы <t:…>
+var noiseRe = regexp.MustCompile(`(?i)https?://\S+|<a?:\w+:\d+>|<(?:@[!&]?|#)\d+>|<t:\d+(?::\w)?>`)
+
+// meaningfulText — текст сообщения без ссылок, эмодзи, упоминаний и пунктуации,
+// в нижнем регистре и с одиночными пробелами: по нему считаются длина, слова и повторы.
+func meaningfulText(content string) string {
+	content = noiseRe.ReplaceAllString(content, " ")
+	var b strings.Builder
+	space := true
+	for _, c := range strings.ToLower(content) {
+		if unicode.IsLetter(c) || unicode.IsNumber(c) {
+			b.WriteRune(c)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), " ")
+}
+
+// spamFilter помнит последние сообщения авторов, чтобы ловить повторы.
+type spamFilter struct {
+	mu        sync.Mutex
+	recent    map[voiceKey]*recentText
+	lastPrune time.Time
+}
+
+type recentText struct {
+	texts []string
+	at    time.Time // последнее сообщение
+}
+
+// check — почему сообщение не даёт XP ("" — даёт). Текст запоминается для проверки следующих.
+func (f *spamFilter) check(xp config.XP, key voiceKey, content string, now time.Time) string {
+	text := meaningfulText(content)
+	switch {
+	case xp.MessageIgnoreEmojiLinks && text == "":
+		return skipEmojiLinks
+	case utf8.RuneCountInString(text) < xp.MessageMinLength:
+		return skipTooShort
+	case len(strings.Fields(text)) < xp.MessageMinWords:
+		return skipFewWords
+	}
+	if xp.MessageDuplicateSimilarity <= 0 {
+		return ""
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pruneLocked(now)
+	rt := f.recent[key]
+	if rt == nil || now.Sub(rt.at) > recentWindow {
+		rt = &recentText{}
+		f.recent[key] = rt
+	}
+	dup := false
+	for _, prev := range rt.texts {
+		if similarity(prev, text) >= xp.MessageDuplicateSimilarity {
+			dup = true
+			break
+		}
+	}
+	rt.texts = append(rt.texts, text)
+	if len(rt.texts) > recentPerUser {
+		rt.texts = rt.texts[1:]
+	}
+	rt.at = now
+	if dup {
+		return skipDuplicate
+	}
+	return ""
+}
+
+// pruneLocked раз в recentWindow выкидывает авторов, которые давно молчат.
+func (f *spamFilter) pruneLocked(now time.Time) {
+	if f.recent == nil {
+		f.recent = make(map[voiceKey]*recentText)
+	}
+	if now.Sub(f.lastPrune) < recentWindow {
+		return
+	}
+	f.lastPrune = now
+	for k, rt := range f.recent {
+		if now.Sub(rt.at) > recentWindow {
+			delete(f.recent, k)
+		}
+	}
+}
+
+// similarity — 1 для одинаковых строк, 0 для совсем разных (через расстояние Левенштейна по рунам).
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) > similarityRunes {
+		ra = ra[:similarityRunes]
+	}
+	if len(rb) > similarityRunes {
+		rb = rb[:similarityRunes]
+	}
+	longest := max(len(ra), len(rb))
+	if longest == 0 {
+		return 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// messageAward — XP за сообщение до множителей: xp.message_award или случайно до xp.message_award_max.
+func messageAward(xp config.XP) int64 {
+	if xp.MessageAwardMax <= xp.MessageAward {
+		return xp.MessageAward
+	}
+	return xp.MessageAward + rand.Int64N(xp.MessageAwardMax-xp.MessageAward+1)
+}
@@ -0,0 +1,80 @@
+package level
+
+import (
+	"testing"
+	"time"
+
+	"gosha_bot/config"
+)
+
+func TestMeaningfulText(t *testing.T) {
+	for in, want := range map[string]string{
+		".": "",
+		"😂😂😂 <:pog:123456> <a:dance:987>":       "",
+		"https://example.com/a?b=1 <@123> <#9>": "",
+		"  Привет,   МИР!!! ":                   "привет мир",
+		"look https://x.io it's <@!42> fine":    "look it s fine",
+	} {
+		if got := meaningfulText(in); got != want {
+			t.Errorf("meaningfulText(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSpamFilterRules(t *testing.T) {
+	xp := config.Default().XP
+	xp.MessageMinWords = 2
+	key := voiceKey{GuildID: guildID, UserID: "u1"}
+	now := time.Now()
+
+	var f spamFilter
+	steps := []struct {
+		content string
+		want    string
+	}{
+		{".", skipEmojiLinks},
+		{"https://example.com 🎉", skipEmojiLinks},
+		{"ok", skipTooShort},
+		{"hello", skipFewWords},
+		{"hello there everyone", ""},
+		{"Hello there, everyone!", skipDuplicate},
+		{"hello there everyone!!1", skipDuplicate}, // почти повтор
+		{"what are we playing tonight", ""},
+	}
+	for i, st := range steps {
+		if got := f.check(xp, key, st.content, now.Add(time.Duration(i)*time.Second)); got != st.want {
+			t.Errorf("%q: got %q, want %q", st.content, got, st.want)
+		}
+	}
+
+	// другой автор и старые сообщения повтором не считаются
+	if got := f.check(xp, voiceKey{GuildID: guildID, UserID: "u2"}, "hello there everyone", now); got != "" {
+		t.Errorf("other author: got %q", got)
+	}
+	if got := f.check(xp, key, "hello there everyone", now.Add(recentWindow+time.Minute)); got != "" {
+		t.Errorf("after window: got %q", got)
+	}
+
+	xp.MessageDuplicateSimilarity = 0
+	if got := f.check(xp, key, "hello there everyone", now.Add(recentWindow+2*time.Minute)); got != "" {
+		t.Errorf("duplicates disabled: got %q", got)
+	}
+}
+
+func TestMessageAwardRange(t *testing.T) {
+	xp := config.XP{MessageAward: 15, MessageAwardMax: 25}
+	seen := map[int64]bool{}
+	for range 2000 {
+		a := messageAward(xp)
+		if a < 15 || a > 25 {
+			t.Fatalf("award %d out of [15, 25]", a)
+		}
+		seen[a] = true
+	}
+	if len(seen) != 11 {
+		t.Fatalf("expected all 11 values, got %v", seen)
+	}
+	if a := messageAward(config.XP{MessageAward: 3}); a != 3 {
+		t.Fatalf("fixed award: got %d", a)
+	}
+}
@@ -0,0 +1,264 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosha_bot/i18n"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Boost — временный множитель XP на весь сервер (ChannelID == "") или на канал/категорию.
+type Boost struct {
+	ID         int64
+	GuildID    string
+	ChannelID  string
+	Multiplier float64
+	StartsAt   time.Time
+	EndsAt     time.Time
+	CreatedBy  string
+}
+
+// BoostStore — хранилище бустов (таблица xp_boosts), чтобы они переживали рестарт.
+type BoostStore interface {
+	Insert(ctx context.Context, b *Boost) error // заполняет b.ID
+	Open(ctx context.Context) ([]Boost, error)  // конец ещё не объявлен (в т.ч. истёкшие за простой)
+	End(ctx context.Context, id int64, at time.Time) error
+}
+
+type pgBoostStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPGBoostStore — BoostStore поверх Postgres.
+func NewPGBoostStore(db *pgxpool.Pool) BoostStore { return &pgBoostStore{db: db} }
+
+func (p *pgBoostStore) Insert(ctx context.Context, b *Boost) error {
+	return p.db.QueryRow(ctx, `
+INSERT INTO xp_boosts (guild_id, channel_id, multiplier, starts_at, ends_at, created_by)
+VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		b.GuildID, b.ChannelID, b.Multiplier, b.StartsAt, b.EndsAt, b.CreatedBy,
+	).Scan(&b.ID)
+}
+
+func (p *pgBoostStore) Open(ctx context.Context) ([]Boost, error) {
+	rows, err := p.db.Query(ctx, `
+SELECT id, guild_id, channel_id, multiplier, starts_at, ends_at, created_by
+FROM xp_boosts WHERE NOT ended ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Boost
+	for rows.Next() {
+		var b Boost
+		if err := rows.Scan(&b.ID, &b.GuildID, &b.ChannelID, &b.Multiplier, &b.StartsAt, &b.EndsAt, &b.CreatedBy); err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgBoostStore) End(ctx context.Context, id int64, at time.Time) error {
+	_, err := p.db.Exec(ctx, `UPDATE xp_boosts SET ended = true, ends_at = LEAST(ends_at, $2) WHERE id = $1`, id, at)
+	return err
+}
+
+// ---- активные бусты в памяти ----
+
+type activeBoost struct {
+	Boost
+	timer *time.Timer
+}
+
+// StartBoost сохраняет буст, ставит таймер окончания и объявляет начало.
+func (r *Registry) StartBoost(ctx context.Context, b Boost) (Boost, error) {
+	if r.Boosts != nil {
+		if err := r.Boosts.Insert(ctx, &b); err != nil {
+			return b, err
+		}
+	} else {
+		r.muBoost.Lock()
+		r.lastBoostID++
+		b.ID = r.lastBoostID
+		r.muBoost.Unlock()
+	}
+	r.trackBoost(b)
+	r.log.Info("xp boost started", "guild", b.GuildID, "boost", b.ID, "channel", b.ChannelID,
+		"multiplier", b.Multiplier, "until", b.EndsAt)
+	r.announceBoost(b, "boost.announce_start")
+	return b, nil
+}
+
+// StopBoost завершает буст досрочно; false — такого активного буста на сервере нет.
+func (r *Registry) StopBoost(guildID string, id int64) bool {
+	r.muBoost.Lock()
+	ab, ok := r.boosts[id]
+	if ok && ab.GuildID != guildID {
+		ok = false
+	}
+	r.muBoost.Unlock()
+	if !ok {
+		return false
+	}
+	return r.endBoost(id, time.Now().UTC())
+}
+
+// trackBoost кладёт буст в память и ставит таймер на его конец.
+func (r *Registry) trackBoost(b Boost) {
+	r.muBoost.Lock()
+	defer r.muBoost.Unlock()
+	if r.boosts == nil {
+		r.boosts = make(map[int64]*activeBoost)
+	}
+	ab := &activeBoost{Boost: b}
+	ab.timer = time.AfterFunc(time.Until(b.EndsAt), func() { r.endBoost(b.ID, b.EndsAt) })
+	r.boosts[b.ID] = ab
+}
+
+// endBoost убирает буст, отмечает его в БД и объявляет конец. Вызывается таймером или /xpboost stop.
+func (r *Registry) endBoost(id int64, at time.Time) bool {
+	r.muBoost.Lock()
+	ab, ok := r.boosts[id]
+	if ok {
+		ab.timer.Stop()
+		delete(r.boosts, id)
+	}
+	r.muBoost.Unlock()
+	if !ok {
+		return false
+	}
+	if r.Boosts != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.Boosts.End(ctx, id, at); err != nil {
+			r.log.Error("end xp boost", "guild", ab.GuildID, "boost", id, "err", err)
+		}
+	}
+	r.log.Info("xp boost ended", "guild", ab.GuildID, "boost", id)
+	r.announceBoost(ab.Boost, "boost.announce_end")
+	return true
+}
+
+// restoreBoosts поднимает таймеры бустов из БД. Истёкшие за время простоя
+// завершаются сразу — с объявлением, которого до рестарта не случилось.
+func (r *Registry) restoreBoosts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	open, err := r.Boosts.Open(ctx)
+	if err != nil {
+		r.log.Error("restore xp boosts", "err", err)
+		return
+	}
+	for _, b := range open {
+		r.trackBoost(b) // у истёкших таймер сработает сразу
+	}
+	if len(open) > 0 {
+		r.log.Info("xp boosts restored", "count", len(open))
+	}
+}
+
+// stopBoostTimers — для Shutdown: бусты остаются в БД, таймеры поднимет restoreBoosts.
+func (r *Registry) stopBoostTimers() {
+	r.muBoost.Lock()
+	defer r.muBoost.Unlock()
+	for id, ab := range r.boosts {
+		ab.timer.Stop()
+		delete(r.boosts, id)
+	}
+}
+
+// ActiveBoosts — действующие сейчас бусты сервера, по возрастанию времени окончания.
+func (r *Registry) ActiveBoosts(guildID string, now time.Time) []Boost {
+	r.muBoost.Lock()
+	defer r.muBoost.Unlock()
+	var out []Boost
+	for _, ab := range r.boosts {
+		if ab.GuildID == guildID && !now.Before(ab.StartsAt) && now.Before(ab.EndsAt) {
+			out = append(out, ab.Boost)
+		}
+	}
+	slices.SortFunc(out, func(a, b Boost) int { return a.EndsAt.Compare(b.EndsAt) })
+	return out
+}
+
+// hasScopedBoost — есть ли буст на отдельный канал (тогда нужна цепочка родителей канала).
+func (r *Registry) hasScopedBoost(guildID string) bool {
+	r.muBoost.Lock()
+	defer r.muBoost.Unlock()
+	for _, ab := range r.boosts {
+		if ab.GuildID == guildID && ab.ChannelID != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// boostMultiplier — сильнейший из бустов, действующих в канале (chain — канал и его родители).
+// Бусты не перемножаются: два «x2» подряд — это всё ещё x2.
+func (r *Registry) boostMultiplier(guildID string, chain []string, now time.Time) float64 {
+	m := 1.0
+	for _, b := range r.ActiveBoosts(guildID, now) {
+		if (b.ChannelID == "" || slices.Contains(chain, b.ChannelID)) && b.Multiplier > m {
+			m = b.Multiplier
+		}
+	}
+	return m
+}
+
+func (r *Registry) announceBoost(b Boost, key string) {
+	cfg := r.Guilds.Get(b.GuildID)
+	if cfg == nil || cfg.AnnounceChannelID == "" {
+		return
+	}
+	lang := i18n.ForGuild(b.GuildID)
+	args := []any{formatMultiplier(b.Multiplier), boostScope(lang, b)}
+	if key == "boost.announce_start" {
+		args = append(args, b.EndsAt.Unix())
+	}
+	msg := i18n.T(lang, key, args...)
+	if _, err := r.s.ChannelMessageSend(cfg.AnnounceChannelID, msg); err != nil {
+		r.log.Warn("announce xp boost", "guild", b.GuildID, "boost", b.ID, "err", err)
+	}
+}
+
+func boostScope(lang i18n.Lang, b Boost) string {
+	if b.ChannelID == "" {
+		return i18n.T(lang, "boost.scope_guild")
+	}
+	return i18n.T(lang, "boost.scope_channel", b.ChannelID)
+}
+
+// максимальная длительность буста
+const maxBoostDuration = 30 * 24 * time.Hour
+
+// parseBoostDuration — как time.ParseDuration, но понимает и дни: "48h", "2d", "1d12h", "90m".
+func parseBoostDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	var d time.Duration
+	if i := strings.IndexByte(s, 'd'); i > 0 {
+		days, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		d = time.Duration(days) * 24 * time.Hour
+		s = s[i+1:]
+	}
+	if s != "" {
+		rest, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		d += rest
+	}
+	if d <= 0 || d > maxBoostDuration {
+		return 0, fmt.Errorf("duration must be within (0, %s]", maxBoostDuration)
+	}
+	return d, nil
+}
@@ -0,0 +1,125 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /xpboost start|stop|list — временные множители XP («двойной XP на выходных»)
+func (r *Registry) boostCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minMult, maxMult := 1.0, 10.0
+	minID := float64(1)
+	return &discordgo.ApplicationCommand{
+		Name:                     "xpboost",
+		Description:              "Временные XP-бусты",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "start", Description: "Запустить XP-буст",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionNumber, Name: "multiplier", Description: "Множитель, например 2", Required: true, MinValue: &minMult, MaxValue: maxMult},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "duration", Description: "Длительность: 90m, 48h, 2d", Required: true},
+					{
+						Type: discordgo.ApplicationCommandOptionChannel, Name: "channel", Description: "Только в этом канале или категории (по умолчанию — весь сервер)",
+						ChannelTypes: []discordgo.ChannelType{
+							discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews, discordgo.ChannelTypeGuildForum,
+							discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildStageVoice, discordgo.ChannelTypeGuildCategory,
+						},
+					},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "stop", Description: "Завершить XP-буст досрочно",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "id", Description: "Номер буста из /xpboost list", Required: true, MinValue: &minID},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "Действующие XP-бусты"},
+		},
+	}
+}
+
+func (r *Registry) onBoostStart(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	d, err := parseBoostDuration(opts["duration"].StringValue())
+	if err != nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "boost.bad_duration"))
+		return
+	}
+	now := time.Now().UTC()
+	b := Boost{
+		GuildID:    ic.GuildID,
+		Multiplier: opts["multiplier"].FloatValue(),
+		StartsAt:   now,
+		EndsAt:     now.Add(d),
+		CreatedBy:  ic.Member.User.ID,
+	}
+	if o, ok := opts["channel"]; ok {
+		b.ChannelID = o.ChannelValue(nil).ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b, err = r.StartBoost(ctx, b)
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("start xp boost", "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "boost.failed", err))
+		return
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "boost.started", b.ID, formatMultiplier(b.Multiplier), boostScope(lang, b), b.EndsAt.Unix()))
+}
+
+func (r *Registry) onBoostStop(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	id := commands.Options(ic)["id"].IntValue()
+	if !r.StopBoost(ic.GuildID, id) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "boost.not_found", id))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("xp boost stopped", "boost", id)
+	r.respondEphemeral(s, ic, i18n.T(lang, "boost.stopped", id))
+}
+
+func (r *Registry) onBoostList(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	active := r.ActiveBoosts(ic.GuildID, time.Now())
+	if len(active) == 0 {
+		r.respondEphemeral(s, ic, i18n.T(lang, "boost.none"))
+		return
+	}
+	var b strings.Builder
+	for _, x := range active {
+		fmt.Fprintf(&b, "`#%d` ×%s %s — <t:%d:R>\n", x.ID, formatMultiplier(x.Multiplier), boostScope(lang, x), x.EndsAt.Unix())
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "boost.list", b.String()))
+}
+
+// boostFooter — строка о бустах для футера /level ("" — бустов нет).
+// Футер не рендерит упоминания и <t:…>, поэтому время — текстом в UTC.
+func (r *Registry) boostFooter(lang i18n.Lang, guildID string) string {
+	active := r.ActiveBoosts(guildID, time.Now())
+	if len(active) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(active))
+	for _, b := range active {
+		key := "level.footer_boost"
+		if b.ChannelID != "" {
+			key = "level.footer_boost_channel"
+		}
+		parts = append(parts, i18n.T(lang, key, formatMultiplier(b.Multiplier), b.EndsAt.UTC().Format("02.01 15:04")))
+	}
+	return strings.Join(parts, " • ")
+}
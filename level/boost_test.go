@@ -0,0 +1,179 @@
+package level
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gosha_bot/guildcfg"
+)
+
+type fakeBoosts struct {
+	mu    sync.Mutex
+	rows  map[int64]Boost
+	ended map[int64]time.Time
+	next  int64
+}
+
+func newFakeBoosts(open ...Boost) *fakeBoosts {
+	f := &fakeBoosts{rows: map[int64]Boost{}, ended: map[int64]time.Time{}}
+	for _, b := range open {
+		f.rows[b.ID] = b
+		f.next = max(f.next, b.ID)
+	}
+	return f
+}
+
+func (f *fakeBoosts) Insert(_ context.Context, b *Boost) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next++
+	b.ID = f.next
+	f.rows[b.ID] = *b
+	return nil
+}
+
+func (f *fakeBoosts) Open(context.Context) ([]Boost, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []Boost
+	for id, b := range f.rows {
+		if _, done := f.ended[id]; !done {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBoosts) End(_ context.Context, id int64, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ended[id] = at
+	return nil
+}
+
+func (f *fakeBoosts) isEnded(id int64) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.ended[id]
+	return ok
+}
+
+func setupBoosts(t *testing.T, store *fakeBoosts) (*Registry, func() []string) {
+	t.Helper()
+	r, fake := setup(t)
+	r.Boosts = store
+	if err := r.Guilds.Apply(context.Background(), guildcfg.Guild{GuildID: guildID, AnnounceChannelID: "news"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(r.stopBoostTimers)
+	announced := func() []string {
+		var out []string
+		for _, m := range fake.Messages("news") {
+			out = append(out, m.Content)
+		}
+		return out
+	}
+	return r, announced
+}
+
+func TestBoostMultipliesAndEnds(t *testing.T) {
+	store := newFakeBoosts()
+	r, announced := setupBoosts(t, store)
+	if err := r.Guilds.SetMultiplier(context.Background(), guildID, "muted", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	b, err := r.StartBoost(context.Background(), Boost{GuildID: guildID, Multiplier: 2, StartsAt: now, EndsAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.multiplier(guildID, "general"); got != 2 {
+		t.Errorf("multiplier during boost = %v, want 2", got)
+	}
+	if got := r.multiplier(guildID, "muted"); got != 0 {
+		t.Errorf("no-XP channel during boost = %v, want 0", got)
+	}
+	if got := r.multiplier("other-guild", "general"); got != 1 {
+		t.Errorf("boost leaked into another guild: %v", got)
+	}
+	if msgs := announced(); len(msgs) != 1 || !strings.Contains(msgs[0], "×2") {
+		t.Fatalf("start announcement = %v", msgs)
+	}
+
+	if !r.StopBoost(guildID, b.ID) {
+		t.Fatal("stop failed")
+	}
+	if r.StopBoost(guildID, b.ID) {
+		t.Fatal("second stop must report missing boost")
+	}
+	if !store.isEnded(b.ID) {
+		t.Error("stopped boost not marked ended in store")
+	}
+	if got := r.multiplier(guildID, "general"); got != 1 {
+		t.Errorf("multiplier after boost = %v, want 1", got)
+	}
+	if msgs := announced(); len(msgs) != 2 {
+		t.Fatalf("end not announced: %v", msgs)
+	}
+}
+
+func TestStrongestBoostWins(t *testing.T) {
+	r, _ := setupBoosts(t, newFakeBoosts())
+	now := time.Now().UTC()
+	for _, m := range []float64{1.5, 3, 2} {
+		if _, err := r.StartBoost(context.Background(), Boost{GuildID: guildID, Multiplier: m, StartsAt: now, EndsAt: now.Add(time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := r.multiplier(guildID, "general"); got != 3 {
+		t.Fatalf("multiplier = %v, want 3 (boosts don't stack)", got)
+	}
+}
+
+func TestRestoreBoosts(t *testing.T) {
+	now := time.Now().UTC()
+	store := newFakeBoosts(
+		Boost{ID: 1, GuildID: guildID, Multiplier: 2, StartsAt: now.Add(-3 * time.Hour), EndsAt: now.Add(-time.Hour)},
+		Boost{ID: 2, GuildID: guildID, Multiplier: 3, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)},
+	)
+	r, announced := setupBoosts(t, store)
+
+	r.restoreBoosts()
+
+	// истёкший за простой буст завершается сразу, с объявлением
+	deadline := time.Now().Add(time.Second)
+	for !store.isEnded(1) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !store.isEnded(1) {
+		t.Fatal("expired boost was not ended on restore")
+	}
+	if store.isEnded(2) {
+		t.Fatal("running boost ended on restore")
+	}
+	if got := r.multiplier(guildID, "general"); got != 3 {
+		t.Errorf("restored boost multiplier = %v, want 3", got)
+	}
+	if msgs := announced(); len(msgs) != 1 {
+		t.Errorf("announcements = %v, want only the end of boost 1", msgs)
+	}
+}
+
+func TestParseBoostDuration(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"48h": 48 * time.Hour, "2d": 48 * time.Hour, "1d12h": 36 * time.Hour, "90m": 90 * time.Minute, " 2D ": 48 * time.Hour,
+	} {
+		if got, err := parseBoostDuration(in); err != nil || got != want {
+			t.Errorf("parse(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0h", "-1h", "d", "2x", "31d", "xd"} {
+		if _, err := parseBoostDuration(in); err == nil {
+			t.Errorf("parse(%q) accepted", in)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package level
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosha_bot/i18n"
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v5"
+)
+
+// /level — уровень и XP (свой или другого пользователя)
+func (r *Registry) levelCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "level",
+		Description: "Показать уровень и XP (свой или другого пользователя)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionUser,
+				Name:        "user",
+				Description: "Пользователь (по умолчанию — ты)",
+				Required:    false,
+			},
+		},
+	}
+}
+
+func (r *Registry) onLevelCommand(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Type != discordgo.InteractionApplicationCommand || ic.Member == nil {
+		return
+	}
+	data := ic.ApplicationCommandData()
+	lang := i18n.For(ic.Interaction)
+
+	// чей уровень
+	targetID := ic.Member.User.ID
+	targetTag := ic.Member.User.Username
+	if len(data.Options) > 0 && data.Options[0].Type == discordgo.ApplicationCommandOptionUser {
+		if u := data.Options[0].UserValue(s); u != nil {
+			targetID = u.ID
+			targetTag = u.Username
+		}
+	}
+
+	// из БД
+	var xp int64 = 0
+	if r.DB != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		err := r.DB.QueryRow(ctx,
+			`SELECT xp FROM users_levels WHERE guild_id=$1 AND user_id=$2`,
+			ic.GuildID, targetID,
+		).Scan(&xp)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			logging.Interaction(r.log, ic).Error("load level", "target", targetID, "err", err)
+		}
+	}
+
+	// уровень и пороги — по кривой, а не из колонки level (она могла остаться от старой кривой)
+	lvl, prev, next := levelcurve.Progress(r.Curve, xp)
+
+	need := next - xp
+	prog := 1.0 // последний уровень таблицы — полоса заполнена
+	if next > prev {
+		prog = float64(xp-prev) / float64(next-prev) // 0..1
+	}
+
+	// прогресс-бар на 10 клеток c округлением (а не усечением)
+	const cells = 10
+	filled := int(math.Round(prog * float64(cells)))
+	if filled < 0 {
+		filled = 0
+	}
+	if filled > cells {
+		filled = cells
+	}
+
+	// используем эмодзи одинаковой ширины
+	var bar strings.Builder
+	for i := 0; i < cells; i++ {
+		if i < filled {
+			bar.WriteString("🟩")
+		} else {
+			bar.WriteString("⬜")
+		}
+	}
+	percent := int(math.Round(prog * 100))
+
+	// аватар
+	thumb := ""
+	if u, _ := s.User(targetID); u != nil {
+		thumb = discordgo.EndpointUserAvatar(u.ID, u.Avatar)
+	}
+
+	// тир-роль (из настроек сервера)
+	tier := func(level int) string {
+		if rid := r.TierRoleFor(ic.GuildID, level); rid != "" {
+			return "<@&" + rid + ">"
+		}
+		return i18n.T(lang, "common.dash")
+	}
+
+	toNext := i18n.T(lang, "level.to_next_value", need, lvl+1)
+	if next == prev {
+		toNext = i18n.T(lang, "level.max")
+	}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       i18n.T(lang, "level.title"),
+		Description: fmt.Sprintf("**%s**", targetTag),
+		Color:       0x5865F2,
+		Thumbnail:   &discordgo.MessageEmbedThumbnail{URL: thumb},
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: i18n.T(lang, "field.level"), Value: fmt.Sprintf("%d", lvl), Inline: true},
+			{Name: i18n.T(lang, "field.xp"), Value: fmt.Sprintf("%d", xp), Inline: true},
+			{Name: i18n.T(lang, "level.tier"), Value: tier(lvl), Inline: true},
+			{Name: i18n.T(lang, "level.progress"), Value: fmt.Sprintf("%s  %d%%", bar.String(), percent), Inline: false},
+			{Name: i18n.T(lang, "level.to_next"), Value: toNext, Inline: true},
+			{Name: i18n.T(lang, "level.multiplier"), Value: r.multiplierField(lang, ic.GuildID, targetID), Inline: true},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: i18n.T(lang, "level.footer_voice", strconv.FormatFloat(r.XP.VoicePerHour, 'f', -1, 64)),
+		},
+	}
+	if boost := r.boostFooter(lang, ic.GuildID); boost != "" {
+		embed.Footer.Text += " • " + boost
+	}
+
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
+
+// multiplierField — «×3 (роли ×1.5, буст ×2)»; без ролей и бустов просто «×1».
+func (r *Registry) multiplierField(lang i18n.Lang, guildID, userID string) string {
+	total, roles, boost := r.effectiveMultiplier(guildID, userID)
+	out := "×" + formatMultiplier(total)
+	if roles != 1 || boost != 1 {
+		out += " " + i18n.T(lang, "level.multiplier_parts", formatMultiplier(roles), formatMultiplier(boost))
+	}
+	return out
+}
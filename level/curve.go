@@ -0,0 +1,103 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /levelcurve show|recompute — кривая уровней и пересчёт колонки level после её смены
+func (r *Registry) curveCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	return &discordgo.ApplicationCommand{
+		Name:                     "levelcurve",
+		Description:              "Кривая уровней (XP → уровень)",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "show", Description: "Показать текущую кривую и первые пороги"},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "recompute", Description: "Пересчитать уровни всех участников по текущей кривой"},
+		},
+	}
+}
+
+// сколько порогов показывать в /levelcurve show
+const curvePreview = 10
+
+func (r *Registry) onCurveShow(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+
+	var b strings.Builder
+	for l := 2; l <= curvePreview+1; l++ {
+		if max := r.Curve.MaxLevel(); max > 0 && l > max {
+			break
+		}
+		fmt.Fprintf(&b, "lvl %d — %d XP\n", l, r.Curve.Threshold(l))
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "levelcurve.show", r.Curve.String(), b.String()))
+}
+
+func (r *Registry) onCurveRecompute(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	lg := logging.Interaction(r.log, ic)
+	if r.DB == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "common.no_db"))
+		return
+	}
+
+	// на большом сервере может идти дольше 3 секунд — сначала deferred-ответ
+	if err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	}); err != nil {
+		lg.Warn("ack", "err", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	scanned, changed, err := RecomputeLevels(ctx, r.DB, ic.GuildID, r.xpToLevel)
+	msg := i18n.T(lang, "levelcurve.recomputed", scanned, changed)
+	if err != nil {
+		lg.Error("recompute levels", "err", err)
+		msg = i18n.T(lang, "levelcurve.recompute_failed", err)
+	} else {
+		lg.Info("levels recomputed", "curve", r.Curve.String(), "rows", scanned, "changed", changed)
+		// уровни сменились — роли за уровни приводим к новым уровням так же, как /levelroles sync
+		if g := r.Guilds.Get(ic.GuildID); changed > 0 && r.Store != nil && g != nil && len(g.Tiers) > 0 {
+			if r.startTierSync(ic.GuildID) {
+				r.background.Add(1)
+				go func() {
+					defer r.background.Done()
+					defer r.finishTierSync(ic.GuildID)
+					r.tierSyncReply(s, ic, true, msg)
+				}()
+				return
+			}
+			msg += "\n\n" + i18n.T(lang, "levelroles.sync_busy") + " " + i18n.T(lang, "levelcurve.sync_later")
+		}
+	}
+	if _, err := s.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{Content: &msg}); err != nil {
+		lg.Warn("edit reply", "err", err)
+	}
+}
+
+func (r *Registry) respondEphemeral(s *discordgo.Session, ic *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: content,
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
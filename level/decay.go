@@ -0,0 +1,178 @@
+package level
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Затухание XP: «X% в неделю» — одному участнику не чаще раза в decayPeriod.
+// Серверы проверяются раз в decayCheckEvery, первый раз — через decayFirstRun после старта.
+const (
+	decayPeriod     = 7 * 24 * time.Hour
+	decayCheckEvery = 6 * time.Hour
+	decayFirstRun   = 10 * time.Minute
+)
+
+// decayLoop прогоняет затухание XP по всем серверам, пока не закрыт stopTicker.
+func (r *Registry) decayLoop(first, every time.Duration) {
+	defer close(r.decayDone)
+	t := time.NewTimer(first)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stopTicker:
+			return
+		case now := <-t.C:
+			r.runDecay(now.UTC())
+			t.Reset(every)
+		}
+	}
+}
+
+func (r *Registry) runDecay(now time.Time) {
+	for _, id := range r.Guilds.IDs() {
+		g := r.Guilds.Get(id)
+		if g == nil || !g.Decay.Enabled() {
+			continue
+		}
+		if _, err := r.decayGuild(id, g.Decay, now); err != nil {
+			r.log.Error("xp decay", "guild", id, "err", err)
+		}
+	}
+}
+
+// decayGuild снимает XP у неактивных участников сервера, пересчитывает их роли за уровень
+// и пишет итог в админ-лог.
+func (r *Registry) decayGuild(guildID string, d guildcfg.Decay, now time.Time) ([]xpstore.MemberResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	decayed, err := r.Store.Decay(ctx, guildID, xpstore.Decay{
+		Percent:       d.Percent,
+		InactiveSince: now.Add(-time.Duration(d.AfterDays) * 24 * time.Hour),
+		DecayedBefore: now.Add(-decayPeriod),
+		FloorXP:       r.floorXP(d.FloorLevel),
+	}, xpstore.Meta{Source: xpstore.SourceDecay})
+	if err != nil || len(decayed) == 0 {
+		return nil, err
+	}
+
+	failed := 0
+	for _, m := range decayed {
+		if !m.LevelChanged() {
+			continue
+		}
+		// ушедшие с сервера участники ролей не имеют — XP им всё равно снят
+		if err := r.applyLevelRoles(guildID, m.UserID, m.Level); err != nil {
+			failed++
+			r.log.Debug("apply level roles", "guild", guildID, "user", m.UserID, "level", m.Level, "source", "decay", "err", err)
+		}
+	}
+	r.log.Info("xp decayed", "guild", guildID, "members", len(decayed), "roles_failed", failed)
+
+	if r.AdminLog != nil {
+		members := make([]adminlog.XPDecayMember, 0, len(decayed))
+		for _, m := range decayed {
+			members = append(members, adminlog.XPDecayMember{
+				UserID: m.UserID, XPBefore: m.XPBefore, XPAfter: m.XP, LevelBefore: m.LevelBefore, LevelAfter: m.Level,
+			})
+		}
+		slices.SortStableFunc(members, func(a, b adminlog.XPDecayMember) int {
+			return cmp.Compare(b.XPBefore-b.XPAfter, a.XPBefore-a.XPAfter)
+		})
+		r.AdminLog.PostXPDecay(guildID, adminlog.XPDecay{
+			Percent: d.Percent, AfterDays: d.AfterDays, FloorLevel: d.FloorLevel, Members: members,
+		})
+	}
+	return decayed, nil
+}
+
+// floorXP — сколько XP нужно для уровня level (выше потолка кривой — потолок).
+func (r *Registry) floorXP(level int) int64 {
+	if m := r.Curve.MaxLevel(); m > 0 && level > m {
+		level = m
+	}
+	return r.Curve.Threshold(max(level, 1))
+}
+
+// ====== /xpdecay ======
+
+func (r *Registry) xpDecayCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minPercent, minDays, minLevel := 0.1, float64(1), float64(1)
+	return &discordgo.ApplicationCommand{
+		Name:                     "xpdecay",
+		Description:              "Затухание XP неактивных участников",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "set", Description: "Включить или изменить затухание",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionNumber, Name: "percent", Description: "Сколько процентов XP снимать в неделю", Required: true, MinValue: &minPercent, MaxValue: 100},
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "after_days", Description: "Через сколько дней без сообщений и войса", Required: true, MinValue: &minDays, MaxValue: 3650},
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "floor_level", Description: "Ниже этого уровня не опускать (по умолчанию — 1)", MinValue: &minLevel},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "off", Description: "Выключить затухание"},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "show", Description: "Показать правило затухания"},
+		},
+	}
+}
+
+func (r *Registry) onDecaySet(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	opts := commands.Options(ic)
+	d := guildcfg.Decay{
+		Percent:   opts["percent"].FloatValue(),
+		AfterDays: int(opts["after_days"].IntValue()),
+	}
+	if o, ok := opts["floor_level"]; ok {
+		d.FloorLevel = int(o.IntValue())
+	}
+	r.saveDecay(s, ic, d)
+}
+
+func (r *Registry) onDecayOff(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.saveDecay(s, ic, guildcfg.Decay{})
+}
+
+func (r *Registry) onDecayShow(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	var d guildcfg.Decay
+	if g := r.Guilds.Get(ic.GuildID); g != nil {
+		d = g.Decay
+	}
+	r.respondEphemeral(s, ic, decayText(i18n.For(ic.Interaction), d, r.Curve))
+}
+
+func (r *Registry) saveDecay(s *discordgo.Session, ic *discordgo.InteractionCreate, d guildcfg.Decay) {
+	lang := i18n.For(ic.Interaction)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetDecay(ctx, ic.GuildID, d); err != nil {
+		logging.Interaction(r.log, ic).Error("set xp decay", "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpdecay.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("xp decay set", "percent", d.Percent, "after_days", d.AfterDays, "floor_level", d.FloorLevel)
+	r.respondEphemeral(s, ic, decayText(lang, d, r.Curve))
+}
+
+func decayText(lang i18n.Lang, d guildcfg.Decay, curve levelcurve.Curve) string {
+	if !d.Enabled() {
+		return i18n.T(lang, "xpdecay.off")
+	}
+	floor := max(d.FloorLevel, 1)
+	return i18n.T(lang, "xpdecay.on", d.Percent, d.AfterDays, floor, curve.Threshold(floor))
+}
@@ -0,0 +1,47 @@
+package level
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"gosha_bot/guildcfg"
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+)
+
+func TestDecayGuildRespectsFloorAndReappliesRoles(t *testing.T) {
+	r, fake := setup(t, "t50", "other")
+	store := xpstore.NewMemory(levelcurve.Default)
+	r.Store, r.Curve = store, levelcurve.Default
+	now := time.Now()
+	ctx := context.Background()
+	if _, err := store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: "u1", Delta: levelcurve.Default.Threshold(50), MessageAt: now.Add(-40 * 24 * time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: "active", Delta: 5000, MessageAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	d := guildcfg.Decay{Percent: 90, AfterDays: 30, FloorLevel: 30}
+	decayed, err := r.decayGuild(guildID, d, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decayed) != 1 || decayed[0].UserID != "u1" {
+		t.Fatalf("decayed = %+v", decayed)
+	}
+	if decayed[0].Level != 30 || decayed[0].XP != levelcurve.Default.Threshold(30) {
+		t.Fatalf("floor not respected: %+v", decayed[0])
+	}
+	if got := fake.MemberRoles(guildID, "u1"); !slices.Equal(got, []string{"other", "t25"}) {
+		t.Fatalf("roles after decay = %v", got)
+	}
+
+	// через день — ещё рано, даже без порога: не чаще раза в неделю
+	d.FloorLevel = 0
+	if again, err := r.decayGuild(guildID, d, now.Add(24*time.Hour)); err != nil || len(again) != 0 {
+		t.Fatalf("second run: %+v, %v", again, err)
+	}
+}
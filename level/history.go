@@ -0,0 +1,160 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// записей журнала на странице /xp history
+const historyPageSize = 10
+
+// кнопки листания: custom_id "xp:history:<userID>:<page>"
+const historyButton = "xp:history"
+
+// /xp — XP участников (только администраторы)
+func (r *Registry) xpCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	return &discordgo.ApplicationCommand{
+		Name:                     "xp",
+		Description:              "XP участников",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: append([]*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "history", Description: "Откуда у участника XP: журнал изменений",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Пользователь (по умолчанию — ты)"},
+				},
+			},
+		}, xpAdminOptions()...),
+	}
+}
+
+func (r *Registry) onXPHistory(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Member == nil {
+		return
+	}
+	if !r.canManageXP(ic.Member) {
+		r.respondEphemeral(s, ic, i18n.T(i18n.For(ic.Interaction), "xp.history_no_perms"))
+		return
+	}
+	targetID := ic.Member.User.ID
+	if o, ok := commands.Options(ic)["user"]; ok {
+		if u := o.UserValue(nil); u != nil {
+			targetID = u.ID
+		}
+	}
+	r.respondHistory(s, ic, targetID, 0, discordgo.InteractionResponseChannelMessageWithSource)
+}
+
+// onXPHistoryPage — кнопка ◀/▶: та же страница журнала, только другая.
+func (r *Registry) onXPHistoryPage(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	parts := strings.Split(ic.MessageComponentData().CustomID, ":")
+	if len(parts) != 4 {
+		return
+	}
+	page, err := strconv.Atoi(parts[3])
+	if err != nil || page < 0 {
+		return
+	}
+	// кнопки остаются у сообщения: права могли отобрать, пока журнал был открыт
+	if !r.canManageXP(ic.Member) {
+		r.respondEphemeral(s, ic, i18n.T(i18n.For(ic.Interaction), "xp.history_no_perms"))
+		return
+	}
+	r.respondHistory(s, ic, parts[2], page, discordgo.InteractionResponseUpdateMessage)
+}
+
+func (r *Registry) respondHistory(s *discordgo.Session, ic *discordgo.InteractionCreate, userID string, page int, typ discordgo.InteractionResponseType) {
+	lang := i18n.For(ic.Interaction)
+	if r.Store == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.no_db"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	entries, total, err := r.Store.History(ctx, ic.GuildID, userID, page*historyPageSize, historyPageSize)
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("load xp history", "target", userID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.history_failed"))
+		return
+	}
+	pages := max(1, (total+historyPageSize-1)/historyPageSize)
+	err = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: typ,
+		Data: &discordgo.InteractionResponseData{
+			Flags:           discordgo.MessageFlagsEphemeral,
+			Embeds:          []*discordgo.MessageEmbed{historyEmbed(lang, userID, entries, page, pages, total)},
+			Components:      historyButtons(userID, page, pages),
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
+
+func historyEmbed(lang i18n.Lang, userID string, entries []xpstore.Entry, page, pages, total int) *discordgo.MessageEmbed {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<@%s>\n\n", userID)
+	if len(entries) == 0 {
+		b.WriteString(i18n.T(lang, "xp.history_empty"))
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "<t:%d:f> **%+d** → %d · %s", e.At.Unix(), e.Delta, e.XP, sourceName(lang, e.Source))
+		if e.ActorID != "" {
+			fmt.Fprintf(&b, " · <@%s>", e.ActorID)
+		}
+		if e.Reason != "" {
+			fmt.Fprintf(&b, " — %s", truncate(e.Reason, 100))
+		}
+		b.WriteByte('\n')
+	}
+	return &discordgo.MessageEmbed{
+		Title:       i18n.T(lang, "xp.history_title"),
+		Description: b.String(),
+		Color:       0x5865F2,
+		Footer:      &discordgo.MessageEmbedFooter{Text: i18n.T(lang, "xp.history_footer", page+1, pages, total)},
+	}
+}
+
+func historyButtons(userID string, page, pages int) []discordgo.MessageComponent {
+	if pages <= 1 {
+		return nil
+	}
+	id := func(p int) string { return fmt.Sprintf("%s:%s:%d", historyButton, userID, p) }
+	return []discordgo.MessageComponent{
+		discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+			discordgo.Button{Label: "◀", Style: discordgo.SecondaryButton, CustomID: id(page - 1), Disabled: page == 0},
+			discordgo.Button{Label: "▶", Style: discordgo.SecondaryButton, CustomID: id(page + 1), Disabled: page >= pages-1},
+		}},
+	}
+}
+
+// sourceName — источник изменения словами; неизвестный показывается как есть.
+func sourceName(lang i18n.Lang, source string) string {
+	key := "xp.source_" + source
+	if t := i18n.T(lang, key); t != key {
+		return t
+	}
+	return source
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,52 @@
+package level
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gosha_bot/i18n"
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHistoryEmbedAndPaging(t *testing.T) {
+	store := xpstore.NewMemory(levelcurve.Default)
+	ctx := context.Background()
+	for range 12 {
+		if _, err := store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: "u1", Delta: 5, Meta: xpstore.Meta{Source: xpstore.SourceMessage}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: "u1", Delta: -10,
+		Meta: xpstore.Meta{Source: xpstore.SourcePenalty, ActorID: "mod", Reason: "flood"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, total, err := store.History(ctx, guildID, "u1", 0, historyPageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := (total + historyPageSize - 1) / historyPageSize
+	embed := historyEmbed(i18n.EN, "u1", entries, 0, pages, total)
+	first := strings.SplitN(embed.Description, "\n", 4)[2] // после упоминания и пустой строки — новейшая запись
+	for _, want := range []string{"**-10** → 50", "penalty", "<@mod>", "flood"} {
+		if !strings.Contains(first, want) {
+			t.Errorf("newest entry %q does not mention %q", first, want)
+		}
+	}
+	if embed.Footer.Text != "Page 1 of 2 · 13 entries" {
+		t.Errorf("footer = %q", embed.Footer.Text)
+	}
+
+	row := historyButtons("u1", 0, pages)[0].(discordgo.ActionsRow)
+	prev, next := row.Components[0].(discordgo.Button), row.Components[1].(discordgo.Button)
+	if !prev.Disabled || next.Disabled || next.CustomID != "xp:history:u1:1" {
+		t.Errorf("first page buttons: %+v / %+v", prev, next)
+	}
+	if historyButtons("u1", 0, 1) != nil {
+		t.Error("a single page needs no buttons")
+	}
+}
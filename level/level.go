@@ -2,269 +2,464 @@ package level
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"math"
 	"sync"
 	"time"
 
+	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/config"
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/levelcurve"
+	"gosha_bot/metrics"
+	"gosha_bot/xpstore"
+
 	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Registry struct {
-	s            *discordgo.Session
-	DB           *pgxpool.Pool
-	GuildID      string
-	AfkChannelID string
-
-	// role tiers
-	roleL1to24   string
-	roleL25to49  string
-	roleL50to74  string
-	roleL75to99  string
-	roleL100Plus string
-
-	muVoice   sync.Mutex
-	voiceJoin map[string]time.Time // userID -> join time (если не AFK)
+	s      discord.Session
+	DB     *pgxpool.Pool
+	Guilds *guildcfg.Store
+	XP     config.XP        // тарифы начисления
+	Curve  levelcurve.Curve // XP → уровень
+	Store  xpstore.Store    // nil — БД не настроена, XP не начисляется
+	Boosts BoostStore       // nil — бусты живут только в памяти
+	log    *slog.Logger
+
+	Sessions VoiceSessionStore // nil — открытые войс-сессии рестарт не переживают
+	History  VoiceHistory      // nil — закрытые войс-сессии нигде не хранятся
+	OptOuts  OptOutStore       // nil — отказы от объявлений об уровнях живут только в памяти
+	AdminLog *adminlog.Logger  // nil — ручные изменения XP только в журнале и логах
+
+	adminRoles map[string]bool // admin_role_ids: могут менять XP без прав администратора
+
+	muVoice    sync.Mutex
+	voiceJoin  map[voiceKey]voiceSession  // (guild, user) -> открытая сессия, за которую капает XP
+	voiceState map[voiceKey]voicePresence // (guild, user) -> где сидит и в каком состоянии (все, не только с XP)
+	closed     bool                       // после Shutdown новые войс-сессии не открываем
+
+	pendingVoice map[voiceKey]SavedVoice // сохранённые до рестарта сессии, ещё не забранные GuildCreate
+
+	spam spamFilter // последние сообщения авторов (повторы)
+
+	stopTicker chan struct{}
+	tickerDone chan struct{}
+	decayDone  chan struct{} // nil — затухание XP не запущено (нет БД)
+
+	tierSyncDone chan struct{} // nil — плановая сверка ролей за уровни выключена
+	muTierSync   sync.Mutex
+	tierSyncing  map[string]bool // серверы, где сейчас идёт сверка ролей за уровни
+	background   sync.WaitGroup  // фоновая правка ролей, запущенная командами (/levelroles sync, /xp reset-all, …)
+	stopOnce     sync.Once
+
+	muBoost     sync.Mutex
+	boosts      map[int64]*activeBoost // действующие и ещё не объявленные XP-бусты
+	lastBoostID int64                  // ID бустов без БД
+
+	muOptOut sync.Mutex
+	optOut   map[voiceKey]bool // (guild, user) -> не объявлять о новых уровнях
+
+	muReset       sync.Mutex
+	pendingResets map[string]pendingReset // ID команды /xp reset-all -> ждёт подтверждения
+}
+
+// ключ войс-сессии: один и тот же пользователь может сидеть в войсе на разных серверах
+type voiceKey struct {
+	GuildID string
+	UserID  string
 }
 
-type RolesConfig struct {
-	RoleL1to24   string
-	RoleL25to49  string
-	RoleL50to74  string
-	RoleL75to99  string
-	RoleL100Plus string
+// открытый войс-интервал: с какого момента ещё не начислено и в каком канале (для множителя)
+type voiceSession struct {
+	From      time.Time
+	ChannelID string
+	Started   time.Time // начало сессии (From сдвигает тикер, Started — нет)
+	XP        int64     // начислено тикером с начала сессии
 }
 
-func Register(s *discordgo.Session, guildID string, db *pgxpool.Pool, rc RolesConfig, afkChannelID string) (*Registry, error) {
+func Register(s *discordgo.Session, router *commands.Router, guilds *guildcfg.Store, db *pgxpool.Pool, xp config.XP, curve levelcurve.Curve, log *slog.Logger) (*Registry, error) {
 	r := &Registry{
-		s:            s,
-		DB:           db,
-		GuildID:      guildID,
-		AfkChannelID: afkChannelID,
+		s:      s,
+		DB:     db,
+		Guilds: guilds,
+		XP:     xp,
+		Curve:  curve,
+		log:    log,
+
+		voiceJoin:  make(map[voiceKey]voiceSession),
+		voiceState: make(map[voiceKey]voicePresence),
 
-		roleL1to24:   rc.RoleL1to24,
-		roleL25to49:  rc.RoleL25to49,
-		roleL50to74:  rc.RoleL50to74,
-		roleL75to99:  rc.RoleL75to99,
-		roleL100Plus: rc.RoleL100Plus,
+		pendingVoice: make(map[voiceKey]SavedVoice),
+		optOut:       make(map[voiceKey]bool),
 
-		voiceJoin: make(map[string]time.Time),
+		pendingResets: make(map[string]pendingReset),
 	}
 
 	s.AddHandler(r.onMessageCreate)
-s.AddHandler(r.onVoiceStateUpdate)
-
-go func() {
-    ticker := time.NewTicker(1 * time.Minute) // 1m в проде; можно 10s в тесте
-    defer ticker.Stop()
-
-    for range ticker.C {
-        now := time.Now().UTC()
-
-        // 1) Снимем копию карты под мьютексом (min критическая секция)
-        r.muVoice.Lock()
-        snapshot := make(map[string]time.Time, len(r.voiceJoin))
-        for uid, from := range r.voiceJoin {
-            snapshot[uid] = from
-        }
-        r.muVoice.Unlock()
-
-        // 2) Обрабатываем начисление XP без мьютекса (можно ходить в БД)
-        updates := make(map[string]time.Time, len(snapshot))
-        for uid, from := range snapshot {
-            if newFrom, added := r.addVoiceXPWithCarry(uid, from, now); added {
-                updates[uid] = newFrom
-            }
-        }
-
-        // 3) Возвращаем новые "from" под коротким локом
-        if len(updates) > 0 {
-            r.muVoice.Lock()
-            for uid, newFrom := range updates {
-                // Пользователь мог за это время уйти/перейти канал — проверим, что он ещё в карте
-                if _, ok := r.voiceJoin[uid]; ok {
-                    // (опционально) можно убедиться, что старт не менялся:
-                    // if r.voiceJoin[uid] == snapshot[uid] { r.voiceJoin[uid] = newFrom }
-                    r.voiceJoin[uid] = newFrom
-                }
-            }
-            r.muVoice.Unlock()
-        }
-    }
-}()   // конец горутины
-
-return r, nil
-}     // конец функции Register
-
-// ====== Математика уровней: threshold(L) = 3*L^2 ======
-func xpToLevel(xp int64) int {
-    if xp <= 0 { return 1 }
-    l := int(math.Floor(math.Sqrt(float64(xp) / 10.0)))
-    if l < 1 { l = 1 }
-    return l
-}
-// ====== Сообщения: +1 XP раз в минуту ======
-
-func (r *Registry) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
-    if m.GuildID != r.GuildID { return }
-    if m.Author == nil || m.Author.Bot { return }
+	s.AddHandler(r.onVoiceStateUpdate)
+	s.AddHandler(r.onGuildCreate)
+	s.AddHandler(r.onReady)
+	s.AddHandler(r.onResumed)
+	router.Add(r.levelCommand(), r.onLevelCommand)
+	router.Add(r.voiceStatsCommand(), r.onVoiceStats)
+	router.Add(r.curveCommand(), nil)
+	router.AddSub("levelcurve", "show", r.onCurveShow)
+	router.AddSub("levelcurve", "recompute", r.onCurveRecompute)
+	router.Add(r.tiersCommand(), nil)
+	router.AddSub("levelroles", "add", r.onTierAdd)
+	router.AddSub("levelroles", "remove", r.onTierRemove)
+	router.AddSub("levelroles", "list", r.onTierList)
+	router.AddSub("levelroles", "mode", r.onTierMode)
+	router.AddSub("levelroles", "exclude", r.onTierExclude)
+	router.AddSub("levelroles", "audit", r.onTierAudit)
+	router.AddSub("levelroles", "sync", r.onTierSync)
+	router.Add(r.multiplierCommand(), nil)
+	router.AddSub("xpmultiplier", "set", r.onMultiplierSet)
+	router.AddSub("xpmultiplier", "clear", r.onMultiplierClear)
+	router.AddSub("xpmultiplier", "list", r.onMultiplierList)
+	router.Add(r.boostCommand(), nil)
+	router.AddSub("xpboost", "start", r.onBoostStart)
+	router.AddSub("xpboost", "stop", r.onBoostStop)
+	router.AddSub("xpboost", "list", r.onBoostList)
+	router.Add(r.roleMultiplierCommand(), nil)
+	router.AddSub("xprole", "set", r.onRoleMultiplierSet)
+	router.AddSub("xprole", "clear", r.onRoleMultiplierClear)
+	router.AddSub("xprole", "list", r.onRoleMultiplierList)
+	router.AddSub("xprole", "stacking", r.onRoleStacking)
+	router.Add(r.levelUpCommand(), nil)
+	router.AddSub("levelup", "mode", r.onLevelUpMode)
+	router.AddSub("levelup", "template", r.onLevelUpTemplate)
+	router.AddSub("levelup", "preview", r.onLevelUpPreview)
+	router.Add(r.levelNotifyCommand(), r.onLevelNotify)
+	router.Add(r.xpCommand(), nil)
+	router.AddSub("xp", "history", r.onXPHistory)
+	router.Component(historyButton, r.onXPHistoryPage)
+	router.AddSub("xp", "add", r.onXPAdd)
+	router.AddSub("xp", "remove", r.onXPRemove)
+	router.AddSub("xp", "set", r.onXPSet)
+	router.AddSub("xp", "reset", r.onXPReset)
+	router.AddSub("xp", "reset-all", r.onXPResetAll)
+	router.Component(resetAllButton, r.onXPResetAllButton)
+	router.Add(r.xpDecayCommand(), nil)
+	router.AddSub("xpdecay", "set", r.onDecaySet)
+	router.AddSub("xpdecay", "off", r.onDecayOff)
+	router.AddSub("xpdecay", "show", r.onDecayShow)
+
+	metrics.GaugeFunc("gosha_voice_sessions", "Открытые войс-сессии, за которые капает XP.", func() float64 {
+		r.muVoice.Lock()
+		defer r.muVoice.Unlock()
+		return float64(len(r.voiceJoin))
+	})
+
+	if db != nil {
+		r.Store = xpstore.NewPG(db, curve)
+		r.History = NewPGVoiceHistory(db)
+		r.Boosts = NewPGBoostStore(db)
+		r.restoreBoosts()
+		r.OptOuts = NewPGOptOutStore(db)
+		r.restoreOptOuts()
+		if xp.VoiceDowntimeCredit > 0 {
+			r.Sessions = NewPGVoiceSessionStore(db)
+			r.restoreVoiceSessions()
+		}
+	}
 
-    ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-    defer cancel()
+	r.stopTicker = make(chan struct{})
+	r.tickerDone = make(chan struct{})
+	go r.voiceTicker(1 * time.Minute) // 1m в проде; можно 10s в тесте
+	if r.Store != nil {
+		r.decayDone = make(chan struct{})
+		go r.decayLoop(decayFirstRun, decayCheckEvery)
+		if xp.TierSyncInterval > 0 {
+			r.tierSyncDone = make(chan struct{})
+			go r.tierSyncLoop(xp.TierSyncInterval)
+		}
+	}
 
-    u, err := UpsertUser(ctx, r.DB, r.GuildID, m.Author.ID, m.Author.Username, nickFromMember(m.Member))
-    if err != nil { log.Println("[level] upsert user err:", err); return }
+	return r, nil
+} // конец функции Register
+
+// voiceTicker раз в period начисляет XP всем, кто сидит в войсе, пока не закрыт stopTicker.
+func (r *Registry) voiceTicker(period time.Duration) {
+	defer close(r.tickerDone)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stopTicker:
+			return
+		case now := <-ticker.C:
+			r.tickVoice(now.UTC())
+		}
+	}
+}
 
-    now := time.Now().UTC()
-    if u.LastMsgAt != nil && now.Sub(*u.LastMsgAt) < time.Minute { return }
+func (r *Registry) tickVoice(now time.Time) {
+	// 1) Снимем копию карты под мьютексом (min критическая секция)
+	r.muVoice.Lock()
+	snapshot := make(map[voiceKey]voiceSession, len(r.voiceJoin))
+	for k, vs := range r.voiceJoin {
+		snapshot[k] = vs
+	}
+	r.muVoice.Unlock()
 
-    newXP := u.XP + 1
-    newLevel := xpToLevel(newXP)
+	// 2) Обрабатываем начисление XP без мьютекса (можно ходить в БД)
+	type carry struct {
+		from time.Time
+		xp   int64
+	}
+	updates := make(map[voiceKey]carry, len(snapshot))
+	for k, vs := range snapshot {
+		if newFrom, moved, xp := r.addVoiceXPWithCarry(k.GuildID, k.UserID, vs.ChannelID, vs.From, now); moved {
+			updates[k] = carry{newFrom, xp}
+		}
+	}
 
-    if err := UpdateAfterMessage(ctx, r.DB, r.GuildID, m.Author.ID, newXP, &now, newLevel); err != nil {
-        log.Println("[level] UpdateAfterMessage err:", err); return
-    }
+	// 3) Возвращаем новые "from" под коротким локом
+	if len(updates) > 0 {
+		r.muVoice.Lock()
+		for k, c := range updates {
+			// Пользователь мог за это время уйти/перейти канал — проверим, что это всё та же сессия
+			if vs, ok := r.voiceJoin[k]; ok && vs.Started.Equal(snapshot[k].Started) {
+				vs.From = c.from
+				vs.XP += c.xp
+				r.voiceJoin[k] = vs
+			}
+		}
+		r.muVoice.Unlock()
+	}
 
-    // (опционально) звать applyLevelRoles только если newLevel != u.Level
-    if newLevel != u.Level {
-        if err := r.applyLevelRoles(m.Author.ID, newLevel); err != nil {
-            log.Println("[level] apply roles (message) err:", err)
-        }
-    }
+	// 4) Запоминаем, кто сидит в войсе, — на случай падения
+	if r.Sessions != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := r.saveVoiceSessions(ctx, snapshot, now); err != nil {
+			r.log.Warn("save voice sessions", "err", err)
+		}
+	}
 }
 
-// ====== Войс: 100 XP за час (пропорционально времени), игнор AFK ======
+// Shutdown останавливает тикер и закрывает все открытые войс-интервалы
+// через addVoiceXPWithCarry, чтобы при рестарте не терять накопленное время.
+// Что не успели за ctx — пропадает (с записью в лог).
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stopTicker) })
+	r.stopBoostTimers()
+	select {
+	case <-r.tickerDone:
+	case <-ctx.Done():
+		return fmt.Errorf("voice ticker: %w", ctx.Err())
+	}
+	if r.decayDone != nil {
+		select {
+		case <-r.decayDone:
+		case <-ctx.Done():
+			return fmt.Errorf("xp decay: %w", ctx.Err())
+		}
+	}
+	if r.tierSyncDone != nil {
+		select {
+		case <-r.tierSyncDone:
+		case <-ctx.Done():
+			return fmt.Errorf("tier sync: %w", ctx.Err())
+		}
+	}
+	background := make(chan struct{})
+	go func() {
+		r.background.Wait()
+		close(background)
+	}()
+	select {
+	case <-background:
+	case <-ctx.Done():
+		return fmt.Errorf("level roles: %w", ctx.Err())
+	}
 
-func (r *Registry) onVoiceStateUpdate(s *discordgo.Session, vs *discordgo.VoiceStateUpdate) {
-    if vs.GuildID != r.GuildID { return }
-
-    userID := vs.UserID
-    now := time.Now().UTC()
-    isAFK := (vs.ChannelID == r.AfkChannelID)
-
-    r.muVoice.Lock()
-    joinedAt, tracked := r.voiceJoin[userID]
-
-    // локальное решение, что делать
-    type action int
-    const (
-        none action = iota
-        closeInterval    // закрыть интервал [joinedAt, now]
-        startNew         // стартануть новый интервал с now
-        dropTracking     // убрать из карты
-    )
-    var aClose, aStart action
-
-    switch {
-    case vs.ChannelID == "":
-        if tracked { aClose = closeInterval; aStart = dropTracking }
-    case isAFK:
-        if tracked { aClose = closeInterval; aStart = dropTracking }
-    default:
-        if tracked { aClose = closeInterval; aStart = startNew }
-        if !tracked { aStart = startNew }
-    }
-    r.muVoice.Unlock()
-
-    // Закрываем интервал (добавим XP), результат не используем
-if aClose == closeInterval && tracked {
-    r.addVoiceXPWithCarry(userID, joinedAt, now) // возвраты игнорируем
+	r.muVoice.Lock()
+	open := r.voiceJoin
+	r.voiceJoin = make(map[voiceKey]voiceSession)
+	closing := make([]closedVoice, 0, len(r.voiceState))
+	for k, st := range r.voiceState {
+		vs := open[k]
+		if vs.ChannelID == st.ChannelID {
+			st.XP += vs.XP
+		}
+		closing = append(closing, closedVoice{key: k, session: vs, stay: st, left: true})
+	}
+	for k, vs := range open {
+		if _, ok := r.voiceState[k]; !ok {
+			closing = append(closing, closedVoice{key: k, session: vs})
+		}
+	}
+	r.voiceState = make(map[voiceKey]voicePresence)
+	r.closed = true
+	r.muVoice.Unlock()
+
+	now := time.Now().UTC()
+	flushed := 0
+	for _, c := range closing {
+		if ctx.Err() != nil {
+			return fmt.Errorf("flushed %d of %d voice sessions: %w", flushed, len(closing), ctx.Err())
+		}
+		r.closeVoice(c, now)
+		flushed++
+	}
+	if flushed > 0 {
+		r.log.Info("voice sessions flushed", "count", flushed)
+	}
+	// XP начислен до now; после рестарта засчитается простой, если участник так и сидит в канале
+	if r.Sessions != nil {
+		if err := r.saveVoiceSessions(ctx, open, now); err != nil {
+			return fmt.Errorf("save voice sessions: %w", err)
+		}
+	}
+	return nil
 }
 
-    // Старт/дроп под короткой блокировкой
-    r.muVoice.Lock()
-    defer r.muVoice.Unlock()
-    switch aStart {
-    case startNew:
-        r.voiceJoin[userID] = now
-    case dropTracking:
-        delete(r.voiceJoin, userID)
-    }
-}
+// ====== Математика уровней: кривая из конфига (levels.curve) ======
+func (r *Registry) xpToLevel(xp int64) int { return levelcurve.Level(r.Curve, xp) }
 
+// ====== Сообщения: xp.message_award[..message_award_max] × множители канала и ролей раз в xp.message_cooldown, без спама ======
 
-func (r *Registry) addVoiceXP(userID string, from, to time.Time) {
-	sec := to.Sub(from).Seconds()
-	 log.Printf("[level] addVoiceXP user=%s sec=%.1f", userID, sec)
-	if sec <= 0 {
+func (r *Registry) onMessageCreate(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if r.Guilds.Get(m.GuildID) == nil {
 		return
 	}
-	// 100 XP / 3600 сек => 100/3600 XP в сек; берём пол
-	xpAdd := int64(math.Floor(sec * (100.0 / 3600.0))) 
-	if xpAdd <= 0 {
+	if m.Author == nil || m.Author.Bot {
 		return
 	}
 
-	ctx := context.Background()
-	u, err := UpsertUser(ctx, r.DB, r.GuildID, userID, "", "")
-	if err != nil {
-		log.Println("[level] voice upsert user err:", err)
+	// канал без XP (множитель 0) не трогает и кулдаун
+	var roles []string
+	if m.Member != nil {
+		roles = m.Member.Roles
+	}
+	mult := r.multiplier(m.GuildID, m.ChannelID) * r.roleMultiplier(m.GuildID, roles)
+	award := int64(math.Round(float64(messageAward(r.XP)) * mult))
+	if award <= 0 {
 		return
 	}
 
-	newXP := u.XP + xpAdd
-	newLevel := xpToLevel(newXP)
-	addSec := int64(sec)
+	// анти-спам: короткие, пустые и повторные сообщения тоже не трогают кулдаун
+	if reason := r.spam.check(r.XP, voiceKey{GuildID: m.GuildID, UserID: m.Author.ID}, m.Content, time.Now()); reason != "" {
+		metrics.SkipMessage(reason)
+		r.log.Debug("message xp skipped", "guild", m.GuildID, "user", m.Author.ID, "reason", reason)
+		return
+	}
 
-	// addVoiceXP
-if err := UpdateAfterVoice(ctx, r.DB, r.GuildID, userID, newXP, newLevel, addSec); err != nil {
-    log.Println("[level] UpdateAfterVoice err:", err)
-    return
-}
-// было: if newLevel != u.Level { ... }
-if err := r.applyLevelRoles(userID, newLevel); err != nil {
-    log.Println("[level] apply roles (voice) err:", err)
+	if r.Store == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// кулдаун проверяется в той же транзакции, что и начисление
+	res, err := r.Store.Add(ctx, xpstore.Change{
+		GuildID: m.GuildID, UserID: m.Author.ID,
+		Username: m.Author.Username, DisplayName: nickFromMember(m.Member),
+		Delta: award, MessageAt: time.Now().UTC(), Cooldown: r.XP.MessageCooldown,
+		Meta: xpstore.Meta{Source: xpstore.SourceMessage},
+	})
+	if err != nil {
+		r.log.Error("message xp update", "guild", m.GuildID, "user", m.Author.ID, "err", err)
+		return
+	}
+	if res.Skipped {
+		return
+	}
+	metrics.AddXP(metrics.SourceMessage, award)
+
+	if res.LevelChanged() {
+		if err := r.applyLevelRoles(m.GuildID, m.Author.ID, res.Level); err != nil {
+			r.log.Warn("apply level roles", "guild", m.GuildID, "user", m.Author.ID, "level", res.Level, "source", "message", "err", err)
+		}
+		r.announceLevelUp(m.GuildID, m.Author.ID, m.ChannelID, res)
+	}
 }
+
+// ====== Войс: xp.voice_per_hour × множители канала и ролей (пропорционально времени), без AFK и анти-фарм ======
+
+func (r *Registry) onVoiceStateUpdate(s *discordgo.Session, vs *discordgo.VoiceStateUpdate) {
+	cfg := r.Guilds.Get(vs.GuildID)
+	if cfg == nil {
+		return
+	}
+	key := voiceKey{GuildID: vs.GuildID, UserID: vs.UserID}
+	now := time.Now().UTC()
+
+	r.muVoice.Lock()
+	if r.closed {
+		r.muVoice.Unlock()
+		return
+	}
+	// закрытые интервалы уже убраны из карты, чтобы Shutdown не закрыл их второй раз
+	closed := r.setPresenceLocked(cfg, key, presenceOf(vs.VoiceState), now)
+	r.muVoice.Unlock()
+
+	for _, c := range closed {
+		r.closeVoice(c, now)
+	}
 }
 
-// XP/сек по тарифу (100 XP за час)
-const voiceXPPerHour = 100.0
-const voiceRate = voiceXPPerHour / 3600.0   // XP в секунду
-const secondsPerXP = 3600.0 / voiceXPPerHour // сколько секунд на 1 XP
+// XP в секунду по тарифу xp.voice_per_hour с множителями канала и ролей участника
+func (r *Registry) voiceRate(guildID, channelID, userID string) float64 {
+	return r.XP.VoicePerHour / 3600.0 * r.multiplier(guildID, channelID) * r.roleMultiplier(guildID, r.memberRoles(guildID, userID))
+}
 
-// addVoiceXPWithCarry начисляет XP за интервал [from, to] и возвращает:
+// addVoiceXPWithCarry начисляет XP за интервал [from, to] в канале channelID и возвращает:
 // - newFrom: новый "старт" интервала с сохранением дробного хвоста секунд
-// - added:   начислили ли >= 1 XP (если 0 — from не трогаем, чтобы не терять хвост)
-func (r *Registry) addVoiceXPWithCarry(userID string, from, to time.Time) (time.Time, bool) {
+// - moved:   сдвинулся ли from (если XP ещё не накапал — не трогаем, чтобы не терять хвост)
+// - xp:      сколько начислено
+func (r *Registry) addVoiceXPWithCarry(guildID, userID, channelID string, from, to time.Time) (time.Time, bool, int64) {
 	sec := to.Sub(from).Seconds()
 	if sec <= 0 {
-		return from, false
+		return from, false, 0
+	}
+	rate := r.voiceRate(guildID, channelID, userID)
+	if rate <= 0 {
+		// канал без XP: время здесь не копится, иначе оно «доначислится» после смены множителя
+		return to, true, 0
 	}
 
-	xpAddFloat := sec * voiceRate
+	xpAddFloat := sec * rate
 	xpAdd := int64(math.Floor(xpAddFloat))
 	if xpAdd <= 0 {
 		// XP ещё не «накапал» — ничего не делаем и НЕ сдвигаем from
-		return from, false
+		return from, false, 0
 	}
 
-	ctx := context.Background()
-	u, err := UpsertUser(ctx, r.DB, r.GuildID, userID, "", "")
-	if err != nil {
-		log.Println("[level] voice upsert user err:", err)
-		return from, false
+	if r.Store == nil {
+		return from, false, 0
 	}
-
-	newXP := u.XP + xpAdd
-	newLevel := xpToLevel(newXP)
-
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
 	// добавляем voice-секунды полностью (фактически прошедшие)
-	if err := UpdateAfterVoice(ctx, r.DB, r.GuildID, userID, newXP, newLevel, int64(sec)); err != nil {
-		log.Println("[level] UpdateAfterVoice err:", err)
-		return from, false
+	res, err := r.Store.Add(ctx, xpstore.Change{
+		GuildID: guildID, UserID: userID, Delta: xpAdd, VoiceSec: int64(sec),
+		Meta: xpstore.Meta{Source: xpstore.SourceVoice},
+	})
+	if err != nil {
+		r.log.Error("voice xp update", "guild", guildID, "user", userID, "err", err)
+		return from, false, 0
 	}
-	if err := r.applyLevelRoles(userID, newLevel); err != nil {
-		log.Println("[level] apply roles (voice) err:", err)
+	metrics.AddXP(metrics.SourceVoice, xpAdd)
+	if err := r.applyLevelRoles(guildID, userID, res.Level); err != nil {
+		r.log.Warn("apply level roles", "guild", guildID, "user", userID, "level", res.Level, "source", "voice", "err", err)
 	}
+	r.announceLevelUp(guildID, userID, channelID, res)
 
 	// «списываем» только секунды, которые дали целые XP, хвост оставляем
-	spentSec := float64(xpAdd) * secondsPerXP
+	spentSec := float64(xpAdd) / rate
 	newFrom := from.Add(time.Duration(spentSec * float64(time.Second)))
-	return newFrom, true
+	return newFrom, true, xpAdd
 }
 
-
 func nickFromMember(m *discordgo.Member) string {
 	if m == nil {
 		return ""
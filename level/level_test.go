@@ -0,0 +1,56 @@
+package level
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosha_bot/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func voiceEvent(channelID string) *discordgo.VoiceStateUpdate {
+	return &discordgo.VoiceStateUpdate{VoiceState: &discordgo.VoiceState{
+		GuildID: guildID, UserID: "u1", ChannelID: channelID,
+	}}
+}
+
+func TestShutdownClosesVoiceSessions(t *testing.T) {
+	r, _ := setup(t)
+	r.XP = config.Default().XP
+	r.XP.VoiceMinHumans = 1
+	r.voiceJoin = make(map[voiceKey]voiceSession)
+	r.voiceState = make(map[voiceKey]voicePresence)
+	r.stopTicker = make(chan struct{})
+	r.tickerDone = make(chan struct{})
+	go r.voiceTicker(time.Hour)
+
+	r.onVoiceStateUpdate(nil, voiceEvent("vc1"))
+	if len(r.voiceJoin) != 1 {
+		t.Fatalf("join not tracked: %v", r.voiceJoin)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.voiceJoin) != 0 {
+		t.Fatalf("sessions left after shutdown: %v", r.voiceJoin)
+	}
+	select {
+	case <-r.tickerDone:
+	default:
+		t.Fatal("voice ticker still running")
+	}
+
+	// события после остановки новых сессий не открывают
+	r.onVoiceStateUpdate(nil, voiceEvent("vc2"))
+	if len(r.voiceJoin) != 0 {
+		t.Fatal("voice session opened after shutdown")
+	}
+	if err := r.Shutdown(ctx); err != nil {
+		t.Fatal("second shutdown:", err)
+	}
+}
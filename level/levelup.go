@@ -0,0 +1,392 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// OptOut — участник, отказавшийся от объявлений о своих уровнях на сервере.
+type OptOut struct {
+	GuildID string
+	UserID  string
+}
+
+// OptOutStore — таблица levelup_optout.
+type OptOutStore interface {
+	Load(ctx context.Context) ([]OptOut, error)
+	Set(ctx context.Context, o OptOut, optOut bool) error
+}
+
+type pgOptOutStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPGOptOutStore — OptOutStore поверх Postgres.
+func NewPGOptOutStore(db *pgxpool.Pool) OptOutStore { return &pgOptOutStore{db: db} }
+
+func (p *pgOptOutStore) Load(ctx context.Context) ([]OptOut, error) {
+	rows, err := p.db.Query(ctx, `SELECT guild_id, user_id FROM levelup_optout`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []OptOut
+	for rows.Next() {
+		var o OptOut
+		if err := rows.Scan(&o.GuildID, &o.UserID); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgOptOutStore) Set(ctx context.Context, o OptOut, optOut bool) error {
+	var err error
+	if optOut {
+		_, err = p.db.Exec(ctx, `INSERT INTO levelup_optout (guild_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, o.GuildID, o.UserID)
+	} else {
+		_, err = p.db.Exec(ctx, `DELETE FROM levelup_optout WHERE guild_id=$1 AND user_id=$2`, o.GuildID, o.UserID)
+	}
+	return err
+}
+
+// restoreOptOuts читает отказы от объявлений в память.
+func (r *Registry) restoreOptOuts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	list, err := r.OptOuts.Load(ctx)
+	if err != nil {
+		r.log.Error("restore levelup opt-outs", "err", err)
+		return
+	}
+	r.muOptOut.Lock()
+	defer r.muOptOut.Unlock()
+	for _, o := range list {
+		r.optOut[voiceKey{GuildID: o.GuildID, UserID: o.UserID}] = true
+	}
+}
+
+// SetOptOut включает (optOut=false) или выключает объявления о уровнях участника.
+func (r *Registry) SetOptOut(ctx context.Context, guildID, userID string, optOut bool) error {
+	if r.OptOuts != nil {
+		if err := r.OptOuts.Set(ctx, OptOut{GuildID: guildID, UserID: userID}, optOut); err != nil {
+			return err
+		}
+	}
+	r.muOptOut.Lock()
+	defer r.muOptOut.Unlock()
+	k := voiceKey{GuildID: guildID, UserID: userID}
+	if optOut {
+		if r.optOut == nil {
+			r.optOut = make(map[voiceKey]bool)
+		}
+		r.optOut[k] = true
+	} else {
+		delete(r.optOut, k)
+	}
+	return nil
+}
+
+func (r *Registry) optedOut(guildID, userID string) bool {
+	r.muOptOut.Lock()
+	defer r.muOptOut.Unlock()
+	return r.optOut[voiceKey{GuildID: guildID, UserID: userID}]
+}
+
+// ====== Объявление ======
+
+// announceLevelUp пишет о новом уровне по настройкам сервера. channelID — где заработан уровень
+// (канал сообщения или войс-канал, у которого есть свой чат); понижение уровня не объявляется.
+func (r *Registry) announceLevelUp(guildID, userID, channelID string, res xpstore.Result) {
+	if res.Level <= res.LevelBefore {
+		return
+	}
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil || !levelUpEnabled(cfg.LevelUp.Mode) || r.optedOut(guildID, userID) {
+		return
+	}
+	to, err := r.levelUpChannel(cfg, userID, channelID)
+	if err != nil {
+		r.log.Warn("open dm for level up", "guild", guildID, "user", userID, "err", err)
+		return
+	}
+	if to == "" {
+		return
+	}
+	msg := levelUpMessage(i18n.ForGuild(guildID), cfg, userID, res)
+	if _, err := r.s.ChannelMessageSendComplex(to, msg); err != nil {
+		r.log.Warn("announce level up", "guild", guildID, "user", userID, "level", res.Level, "channel", to, "err", err)
+	}
+}
+
+func levelUpEnabled(mode string) bool {
+	return mode != "" && mode != guildcfg.LevelUpOff
+}
+
+// levelUpChannel — куда писать: "same" без канала уходит туда же, куда "channel"; "" — писать некуда.
+func (r *Registry) levelUpChannel(cfg *guildcfg.Guild, userID, channelID string) (string, error) {
+	switch cfg.LevelUp.Mode {
+	case guildcfg.LevelUpDM:
+		ch, err := r.s.UserChannelCreate(userID)
+		if err != nil {
+			return "", err
+		}
+		return ch.ID, nil
+	case guildcfg.LevelUpSame:
+		if channelID != "" {
+			return channelID, nil
+		}
+		fallthrough
+	case guildcfg.LevelUpChannel:
+		if cfg.LevelUp.ChannelID != "" {
+			return cfg.LevelUp.ChannelID, nil
+		}
+		return cfg.AnnounceChannelID, nil
+	}
+	return "", nil
+}
+
+// levelUpMessage — текст или embed по шаблону сервера. Уровень, с которого выдаётся новая
+// роль-ступень, объявляется по отдельному шаблону. Пинг — только самого участника.
+func levelUpMessage(lang i18n.Lang, cfg *guildcfg.Guild, userID string, res xpstore.Result) *discordgo.MessageSend {
+	role := guildcfg.TierFor(cfg.Tiers, res.Level)
+	tmpl, def := cfg.LevelUp.Template, "levelup.default"
+	if role != "" && role != guildcfg.TierFor(cfg.Tiers, res.LevelBefore) {
+		tmpl, def = cfg.LevelUp.MilestoneTemplate, "levelup.milestone_default"
+	}
+	if tmpl == "" {
+		tmpl = i18n.T(lang, def)
+	}
+	roleText := i18n.T(lang, "levelup.no_role")
+	if role != "" {
+		roleText = "<@&" + role + ">"
+	}
+	text := strings.NewReplacer(
+		"{user}", "<@"+userID+">",
+		"{level}", fmt.Sprint(res.Level),
+		"{role}", roleText,
+		"{xp}", fmt.Sprint(res.XP),
+	).Replace(tmpl)
+
+	msg := &discordgo.MessageSend{AllowedMentions: &discordgo.MessageAllowedMentions{Users: []string{userID}}}
+	if cfg.LevelUp.Style == guildcfg.LevelUpEmbed {
+		msg.Embeds = []*discordgo.MessageEmbed{{Description: text, Color: 0xF1C40F}}
+	} else {
+		msg.Content = text
+	}
+	return msg
+}
+
+// ====== /levelup (админы) и /levelnotify (участники) ======
+
+// максимальная длина шаблона: с подстановками сообщение должно влезть в 2000 символов
+const maxLevelUpTemplate = 1500
+
+func (r *Registry) levelUpCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minLevel := float64(2)
+	return &discordgo.ApplicationCommand{
+		Name:                     "levelup",
+		Description:              "Объявления о новом уровне",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "mode", Description: "Куда и как объявлять",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type: discordgo.ApplicationCommandOptionString, Name: "mode", Description: "Куда", Required: true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "никуда", Value: guildcfg.LevelUpOff},
+							{Name: "в канал объявлений", Value: guildcfg.LevelUpChannel},
+							{Name: "туда, где заработан уровень", Value: guildcfg.LevelUpSame},
+							{Name: "в личку", Value: guildcfg.LevelUpDM},
+						},
+					},
+					{
+						Type: discordgo.ApplicationCommandOptionChannel, Name: "channel", Description: "Канал (по умолчанию — канал объявлений сервера)",
+						ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews},
+					},
+					{
+						Type: discordgo.ApplicationCommandOptionString, Name: "style", Description: "Вид сообщения",
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "текст", Value: guildcfg.LevelUpText},
+							{Name: "embed", Value: guildcfg.LevelUpEmbed},
+						},
+					},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "template", Description: "Шаблон объявления: {user}, {level}, {role}, {xp}",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type: discordgo.ApplicationCommandOptionString, Name: "kind", Description: "Какой шаблон", Required: true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "новый уровень", Value: "level"},
+							{Name: "новая роль за уровень", Value: "milestone"},
+						},
+					},
+					{Type: discordgo.ApplicationCommandOptionString, Name: "text", Description: "Текст (пусто — стандартный)", MaxLength: maxLevelUpTemplate},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "preview", Description: "Показать, как будет выглядеть объявление",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "level", Description: "Какой уровень показать", MinValue: &minLevel},
+				},
+			},
+		},
+	}
+}
+
+func (r *Registry) levelNotifyCommand() *discordgo.ApplicationCommand {
+	dm := false
+	return &discordgo.ApplicationCommand{
+		Name:         "levelnotify",
+		Description:  "Объявлять ли о твоих новых уровнях",
+		DMPermission: &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionBoolean, Name: "enabled", Description: "Объявлять", Required: true},
+		},
+	}
+}
+
+// levelUpSettings — текущие настройки объявлений (пустые, если сервер ещё не загружен).
+func (r *Registry) levelUpSettings(guildID string) guildcfg.LevelUp {
+	if g := r.Guilds.Get(guildID); g != nil {
+		return g.LevelUp
+	}
+	return guildcfg.LevelUp{}
+}
+
+func (r *Registry) onLevelUpMode(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	lu := r.levelUpSettings(ic.GuildID)
+	lu.Mode = opts["mode"].StringValue()
+	if o, ok := opts["channel"]; ok {
+		lu.ChannelID = o.ChannelValue(nil).ID
+	}
+	if o, ok := opts["style"]; ok {
+		lu.Style = o.StringValue()
+	}
+	r.saveLevelUp(s, ic, lu, "levelup mode set", i18n.T(lang, "levelup.mode_set", levelUpWhere(lang, r.Guilds.Get(ic.GuildID), lu)))
+}
+
+func (r *Registry) onLevelUpTemplate(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	kind := opts["kind"].StringValue()
+	text := ""
+	if o, ok := opts["text"]; ok {
+		text = strings.TrimSpace(o.StringValue())
+	}
+	lu := r.levelUpSettings(ic.GuildID)
+	if kind == "milestone" {
+		lu.MilestoneTemplate = text
+	} else {
+		lu.Template = text
+	}
+	key := "levelup.template_set"
+	if text == "" {
+		key = "levelup.template_reset"
+	}
+	r.saveLevelUp(s, ic, lu, "levelup template set", i18n.T(lang, key, i18n.T(lang, "levelup.kind_"+kind)))
+}
+
+func (r *Registry) saveLevelUp(s *discordgo.Session, ic *discordgo.InteractionCreate, lu guildcfg.LevelUp, event, reply string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetLevelUp(ctx, ic.GuildID, lu); err != nil {
+		logging.Interaction(r.log, ic).Error("set levelup", "err", err)
+		r.respondEphemeral(s, ic, i18n.T(i18n.For(ic.Interaction), "levelup.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info(event, "mode", lu.Mode, "channel", lu.ChannelID, "style", lu.Style)
+	r.respondEphemeral(s, ic, reply)
+}
+
+func (r *Registry) onLevelUpPreview(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Member == nil {
+		return
+	}
+	lang := i18n.For(ic.Interaction)
+	cfg := r.Guilds.Get(ic.GuildID)
+	if cfg == nil {
+		cfg = &guildcfg.Guild{GuildID: ic.GuildID}
+	}
+	lvl := 2
+	if o, ok := commands.Options(ic)["level"]; ok {
+		lvl = int(o.IntValue())
+	}
+	res := xpstore.Result{LevelBefore: lvl - 1, Level: lvl}
+	if r.Curve != nil {
+		res.XP = r.Curve.Threshold(lvl)
+	}
+	msg := levelUpMessage(i18n.ForGuild(ic.GuildID), cfg, ic.Member.User.ID, res)
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:           discordgo.MessageFlagsEphemeral,
+			Content:         i18n.T(lang, "levelup.preview", levelUpWhere(lang, cfg, cfg.LevelUp)) + "\n\n" + msg.Content,
+			Embeds:          msg.Embeds,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
+
+// levelUpWhere — куда уходят объявления, словами.
+func levelUpWhere(lang i18n.Lang, cfg *guildcfg.Guild, lu guildcfg.LevelUp) string {
+	switch lu.Mode {
+	case guildcfg.LevelUpChannel, guildcfg.LevelUpSame:
+		ch := lu.ChannelID
+		if ch == "" && cfg != nil {
+			ch = cfg.AnnounceChannelID
+		}
+		target := i18n.T(lang, "levelup.no_channel")
+		if ch != "" {
+			target = "<#" + ch + ">"
+		}
+		return i18n.T(lang, "levelup.where_"+lu.Mode, target)
+	case guildcfg.LevelUpDM:
+		return i18n.T(lang, "levelup.where_dm")
+	}
+	return i18n.T(lang, "levelup.where_off")
+}
+
+func (r *Registry) onLevelNotify(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Member == nil {
+		return
+	}
+	lang := i18n.For(ic.Interaction)
+	enabled := ic.ApplicationCommandData().Options[0].BoolValue()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.SetOptOut(ctx, ic.GuildID, ic.Member.User.ID, !enabled); err != nil {
+		logging.Interaction(r.log, ic).Error("set levelup opt-out", "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelup.failed", err))
+		return
+	}
+	if enabled {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelup.notify_on"))
+	} else {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelup.notify_off"))
+	}
+}
@@ -0,0 +1,92 @@
+package level
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gosha_bot/guildcfg"
+	"gosha_bot/xpstore"
+)
+
+func setLevelUp(t *testing.T, r *Registry, lu guildcfg.LevelUp) {
+	t.Helper()
+	if err := r.Guilds.SetLevelUp(context.Background(), guildID, lu); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLevelUpDestinations(t *testing.T) {
+	up := xpstore.Result{LevelBefore: 2, Level: 3, XP: 90}
+	cases := []struct {
+		mode, channel, want string
+	}{
+		{guildcfg.LevelUpChannel, "ann", "ann"},
+		{guildcfg.LevelUpChannel, "", "announce"}, // канал не задан — канал объявлений сервера
+		{guildcfg.LevelUpSame, "ann", "text"},
+		{guildcfg.LevelUpDM, "", "dm:u1"},
+	}
+	for _, tc := range cases {
+		r, fake := setup(t)
+		if err := r.Guilds.Apply(context.Background(), guildcfg.Guild{GuildID: guildID, AnnounceChannelID: "announce"}); err != nil {
+			t.Fatal(err)
+		}
+		setLevelUp(t, r, guildcfg.LevelUp{Mode: tc.mode, ChannelID: tc.channel})
+		r.announceLevelUp(guildID, "u1", "text", up)
+		msgs := fake.Messages(tc.want)
+		if len(msgs) != 1 || !strings.Contains(msgs[0].Content, "<@u1>") || !strings.Contains(msgs[0].Content, "3") {
+			t.Errorf("%s/%q: messages in %s = %+v", tc.mode, tc.channel, tc.want, msgs)
+		}
+	}
+}
+
+func TestLevelUpSilentCases(t *testing.T) {
+	r, fake := setup(t)
+	r.announceLevelUp(guildID, "u1", "text", xpstore.Result{LevelBefore: 1, Level: 2}) // по умолчанию выключено
+	setLevelUp(t, r, guildcfg.LevelUp{Mode: guildcfg.LevelUpSame})
+	r.announceLevelUp(guildID, "u1", "text", xpstore.Result{LevelBefore: 5, Level: 4}) // понижение
+	if err := r.SetOptOut(context.Background(), guildID, "u1", true); err != nil {
+		t.Fatal(err)
+	}
+	r.announceLevelUp(guildID, "u1", "text", xpstore.Result{LevelBefore: 1, Level: 2})
+	if msgs := fake.Messages("text"); len(msgs) != 0 {
+		t.Fatalf("nothing should be announced, got %+v", msgs)
+	}
+
+	if err := r.SetOptOut(context.Background(), guildID, "u1", false); err != nil {
+		t.Fatal(err)
+	}
+	r.announceLevelUp(guildID, "u1", "text", xpstore.Result{LevelBefore: 1, Level: 2})
+	if msgs := fake.Messages("text"); len(msgs) != 1 {
+		t.Fatalf("opted back in: got %d messages", len(msgs))
+	}
+}
+
+func TestLevelUpTemplates(t *testing.T) {
+	r, _ := setup(t)
+	setLevelUp(t, r, guildcfg.LevelUp{
+		Mode:              guildcfg.LevelUpSame,
+		Template:          "{user} → {level} ({xp} XP, {role})",
+		MilestoneTemplate: "{user} got {role} at {level}",
+	})
+	cfg := r.Guilds.Get(guildID)
+
+	msg := levelUpMessage("en", cfg, "u1", xpstore.Result{LevelBefore: 25, Level: 26, XP: 6760})
+	if want := "<@u1> → 26 (6760 XP, <@&t25>)"; msg.Content != want {
+		t.Errorf("regular: %q, want %q", msg.Content, want)
+	}
+	// 24 → 25: новая ступень — шаблон для ролей
+	msg = levelUpMessage("en", cfg, "u1", xpstore.Result{LevelBefore: 24, Level: 25, XP: 6250})
+	if want := "<@u1> got <@&t25> at 25"; msg.Content != want {
+		t.Errorf("milestone: %q, want %q", msg.Content, want)
+	}
+	if got := msg.AllowedMentions.Users; len(got) != 1 || got[0] != "u1" || len(msg.AllowedMentions.Roles) != 0 {
+		t.Errorf("only the member may be pinged, got %+v", msg.AllowedMentions)
+	}
+
+	cfg.LevelUp = guildcfg.LevelUp{Style: guildcfg.LevelUpEmbed}
+	msg = levelUpMessage("en", cfg, "u1", xpstore.Result{LevelBefore: 1, Level: 2, XP: 40})
+	if msg.Content != "" || len(msg.Embeds) != 1 || !strings.Contains(msg.Embeds[0].Description, "<@u1>") {
+		t.Errorf("default embed: %+v", msg)
+	}
+}
@@ -0,0 +1,175 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// сколько шагов вверх (ветка → канал → категория) проходим в поисках множителя
+const maxParentHops = 3
+
+// multiplier — итоговый множитель XP для канала: множитель канала × сильнейший действующий буст.
+// Канал с множителем 0 остаётся без XP и во время буста.
+func (r *Registry) multiplier(guildID, channelID string) float64 {
+	chain := []string{channelID}
+	if channelID != "" && (r.Guilds.HasMultipliers(guildID) || r.hasScopedBoost(guildID)) {
+		chain = r.channelChain(guildID, channelID)
+	}
+	return r.channelMultiplier(guildID, chain) * r.boostMultiplier(guildID, chain, time.Now())
+}
+
+// roleMultiplier — множитель за роли участника по политике сервера (max или multiply).
+func (r *Registry) roleMultiplier(guildID string, roles []string) float64 {
+	g := r.Guilds.Get(guildID)
+	if g == nil {
+		return 1
+	}
+	return g.RoleMultiplier(roles)
+}
+
+// memberRoles — роли участника, если на сервере есть множители за роли (иначе и знать незачем).
+func (r *Registry) memberRoles(guildID, userID string) []string {
+	if g := r.Guilds.Get(guildID); g == nil || len(g.RoleMultipliers) == 0 {
+		return nil
+	}
+	m, err := discord.Member(r.s, guildID, userID)
+	if err != nil {
+		r.log.Debug("member lookup for role multiplier", "guild", guildID, "user", userID, "err", err)
+		return nil
+	}
+	return m.Roles
+}
+
+// channelMultiplier — множитель первого в цепочке канала, у которого он задан, иначе 1.
+// Ветка наследует множитель канала, канал — категории.
+func (r *Registry) channelMultiplier(guildID string, chain []string) float64 {
+	for _, id := range chain {
+		if m, ok := r.Guilds.Multiplier(guildID, id); ok {
+			return m
+		}
+	}
+	return 1
+}
+
+// channelChain — канал и его родители: ветка → канал → категория.
+func (r *Registry) channelChain(guildID, channelID string) []string {
+	chain := []string{channelID}
+	for id := channelID; len(chain) <= maxParentHops; {
+		c, err := discord.Channel(r.s, id)
+		if err != nil {
+			r.log.Debug("channel lookup for multiplier", "guild", guildID, "channel", id, "err", err)
+			break
+		}
+		if c.ParentID == "" {
+			break
+		}
+		id = c.ParentID
+		chain = append(chain, id)
+	}
+	return chain
+}
+
+// /xpmultiplier set|clear|list — множители XP каналов и категорий
+func (r *Registry) multiplierCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minMult, maxMult := 0.0, 10.0
+	channelTypes := []discordgo.ChannelType{
+		discordgo.ChannelTypeGuildText, discordgo.ChannelTypeGuildNews, discordgo.ChannelTypeGuildForum,
+		discordgo.ChannelTypeGuildVoice, discordgo.ChannelTypeGuildStageVoice, discordgo.ChannelTypeGuildCategory,
+	}
+	return &discordgo.ApplicationCommand{
+		Name:                     "xpmultiplier",
+		Description:              "Множители XP для каналов и категорий",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "set", Description: "Задать множитель (0 — XP не начисляется)",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionChannel, Name: "channel", Description: "Канал или категория", Required: true, ChannelTypes: channelTypes},
+					{Type: discordgo.ApplicationCommandOptionNumber, Name: "multiplier", Description: "Множитель, например 0.5 или 2", Required: true, MinValue: &minMult, MaxValue: maxMult},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "clear", Description: "Вернуть множитель по умолчанию",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionChannel, Name: "channel", Description: "Канал или категория", Required: true, ChannelTypes: channelTypes},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "Показать заданные множители"},
+		},
+	}
+}
+
+func (r *Registry) onMultiplierSet(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	channelID := opts["channel"].ChannelValue(nil).ID
+	m := opts["multiplier"].FloatValue()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetMultiplier(ctx, ic.GuildID, channelID, m); err != nil {
+		logging.Interaction(r.log, ic).Error("set multiplier", "channel", channelID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("multiplier set", "channel", channelID, "multiplier", m)
+	if m == 0 {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.disabled", channelID))
+		return
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.set", channelID, formatMultiplier(m)))
+}
+
+func (r *Registry) onMultiplierClear(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	channelID := commands.Options(ic)["channel"].ChannelValue(nil).ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok, err := r.Guilds.ClearMultiplier(ctx, ic.GuildID, channelID)
+	switch {
+	case err != nil:
+		logging.Interaction(r.log, ic).Error("clear multiplier", "channel", channelID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.failed", err))
+	case !ok:
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.not_set", channelID))
+	default:
+		logging.Interaction(r.log, ic).Info("multiplier cleared", "channel", channelID)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.cleared", channelID))
+	}
+}
+
+func (r *Registry) onMultiplierList(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	g := r.Guilds.Get(ic.GuildID)
+	if g == nil || len(g.Multipliers) == 0 {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.empty"))
+		return
+	}
+	ids := make([]string, 0, len(g.Multipliers))
+	for id := range g.Multipliers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "<#%s> — ×%s\n", id, formatMultiplier(g.Multipliers[id]))
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "xpmultiplier.list", b.String()))
+}
+
+func formatMultiplier(m float64) string { return strconv.FormatFloat(m, 'f', -1, 64) }
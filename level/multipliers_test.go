@@ -0,0 +1,101 @@
+package level
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gosha_bot/config"
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func setupChannels(t *testing.T) (*Registry, func(id string, m float64)) {
+	t.Helper()
+	r, fake := setup(t)
+	fake.AddChannel(guildID, "cat", "", discordgo.ChannelTypeGuildCategory)
+	fake.AddChannel(guildID, "text", "cat", discordgo.ChannelTypeGuildText)
+	fake.AddChannel(guildID, "thread", "text", discordgo.ChannelTypeGuildPublicThread)
+	fake.AddChannel(guildID, "loose", "", discordgo.ChannelTypeGuildText)
+	set := func(id string, m float64) {
+		if err := r.Guilds.SetMultiplier(context.Background(), guildID, id, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r, set
+}
+
+func TestMultiplierInheritance(t *testing.T) {
+	r, set := setupChannels(t)
+	if got := r.multiplier(guildID, "thread"); got != 1 {
+		t.Fatalf("no multipliers: got %v, want 1", got)
+	}
+
+	set("cat", 2)
+	for ch, want := range map[string]float64{"cat": 2, "text": 2, "thread": 2, "loose": 1, "unknown": 1} {
+		if got := r.multiplier(guildID, ch); got != want {
+			t.Errorf("category 2: multiplier(%s) = %v, want %v", ch, got, want)
+		}
+	}
+
+	set("text", 0) // канал внутри категории перекрывает её
+	for ch, want := range map[string]float64{"cat": 2, "text": 0, "thread": 0} {
+		if got := r.multiplier(guildID, ch); got != want {
+			t.Errorf("text 0: multiplier(%s) = %v, want %v", ch, got, want)
+		}
+	}
+}
+
+func TestZeroMultiplierVoiceDoesNotAccrue(t *testing.T) {
+	r, set := setupChannels(t)
+	r.XP = config.Default().XP
+	store := xpstore.NewMemory(levelcurve.Default)
+	r.Store = store
+	set("text", 0)
+
+	from := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+	newFrom, moved, _ := r.addVoiceXPWithCarry(guildID, "u1", "thread", from, to)
+	if !moved || !newFrom.Equal(to) {
+		t.Fatalf("got %v, %v; want interval dropped up to %v", newFrom, moved, to)
+	}
+	if _, _, ok := store.Get(guildID, "u1"); ok {
+		t.Fatal("zero-multiplier channel must not touch XP")
+	}
+
+	set("text", 1)
+	newFrom, moved, _ = r.addVoiceXPWithCarry(guildID, "u1", "thread", from, to)
+	if xp, _, _ := store.Get(guildID, "u1"); !moved || xp != 200 {
+		t.Fatalf("xp = %d, moved %v; want 200 for 2h", xp, moved)
+	}
+	if !newFrom.Equal(to) {
+		t.Fatalf("from = %v, want %v", newFrom, to)
+	}
+}
+
+func TestRoleMultiplierAppliesToMember(t *testing.T) {
+	r, _ := setup(t, "t1", "other")
+	if roles := r.memberRoles(guildID, "u1"); roles != nil {
+		t.Fatalf("no role multipliers: roles = %v, want nil", roles)
+	}
+
+	ctx := context.Background()
+	if err := r.Guilds.SetRoleMultiplier(ctx, guildID, "other", 1.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Guilds.SetRoleMultiplier(ctx, guildID, "t1", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.roleMultiplier(guildID, r.memberRoles(guildID, "u1")); got != 2 {
+		t.Fatalf("max: got %v, want 2", got)
+	}
+	if err := r.Guilds.SetRoleStacking(ctx, guildID, "multiply"); err != nil {
+		t.Fatal(err)
+	}
+	total, roles, boost := r.effectiveMultiplier(guildID, "u1")
+	if total != 3 || roles != 3 || boost != 1 {
+		t.Fatalf("multiply: got %v (roles %v, boost %v), want 3", total, roles, boost)
+	}
+}
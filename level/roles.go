@@ -1,26 +1,61 @@
 package level
 
-// Выбор нужной роли по уровню
-func (r *Registry) roleForLevel(level int) (want string, toRemove []string) {
-	switch {
-	case level >= 100:
-		return r.roleL100Plus, []string{r.roleL75to99, r.roleL50to74, r.roleL25to49, r.roleL1to24}
-	case level >= 75:
-		return r.roleL75to99, []string{r.roleL50to74, r.roleL25to49, r.roleL1to24, r.roleL100Plus}
-	case level >= 50:
-		return r.roleL50to74, []string{r.roleL25to49, r.roleL1to24, r.roleL75to99, r.roleL100Plus}
-	case level >= 25:
-		return r.roleL25to49, []string{r.roleL1to24, r.roleL50to74, r.roleL75to99, r.roleL100Plus}
-	default:
-		return r.roleL1to24, []string{r.roleL25to49, r.roleL50to74, r.roleL75to99, r.roleL100Plus}
+import (
+	"fmt"
+	"slices"
+
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+)
+
+// Выбор нужных ролей по уровню с учётом режима сервера (tier_mode):
+// replace — только старшая заработанная ступень, stack — все заработанные,
+// stack_except — все заработанные, кроме исключений (их держит только старшая ступень).
+// Остальные ступени, в том числе ещё не заработанные (после штрафа), снимаются.
+func roleForLevel(cfg *guildcfg.Guild, level int) (want, toRemove []string) {
+	top := guildcfg.TierFor(cfg.Tiers, level)
+	for _, t := range cfg.Tiers {
+		keep := t.RoleID == top
+		if !keep && t.MinLevel <= level {
+			switch cfg.TierMode {
+			case guildcfg.TierStack:
+				keep = true
+			case guildcfg.TierStackExcept:
+				keep = !slices.Contains(cfg.TierExclude, t.RoleID)
+			}
+		}
+		if keep {
+			want = append(want, t.RoleID)
+		} else {
+			toRemove = append(toRemove, t.RoleID)
+		}
+	}
+	return want, toRemove
+}
+
+// TierRoleFor — какая роль положена за уровень на этом сервере ("" если не настроена).
+func (r *Registry) TierRoleFor(guildID string, level int) string {
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil {
+		return ""
 	}
+	return guildcfg.TierFor(cfg.Tiers, level)
+}
+
+// ApplyLevelRoles — то же для других модулей (пересчёт ролей после штрафа /give).
+func (r *Registry) ApplyLevelRoles(guildID, userID string, level int) error {
+	return r.applyLevelRoles(guildID, userID, level)
 }
 
-// Выдать нужную роль и снять лишние
-func (r *Registry) applyLevelRoles(userID string, level int) error {
-	want, rm := r.roleForLevel(level)
+// Выдать нужные роли и снять лишние
+func (r *Registry) applyLevelRoles(guildID, userID string, level int) error {
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil {
+		return fmt.Errorf("guild %s is not configured", guildID)
+	}
+	want, rm := roleForLevel(cfg, level)
 
-	mem, err := r.s.GuildMember(r.GuildID, userID)
+	mem, err := discord.Member(r.s, guildID, userID)
 	if err != nil {
 		return err
 	}
@@ -34,12 +69,18 @@ func (r *Registry) applyLevelRoles(userID string, level int) error {
 		return false
 	}
 
-	if want != "" && !has(want) {
-		_ = r.s.GuildMemberRoleAdd(r.GuildID, userID, want)
+	for _, rid := range want {
+		if rid != "" && !has(rid) {
+			if err := r.s.GuildMemberRoleAdd(guildID, userID, rid); err != nil {
+				r.log.Warn("add tier role", "guild", guildID, "user", userID, "role", rid, "err", err)
+			}
+		}
 	}
 	for _, rid := range rm {
 		if rid != "" && has(rid) {
-			_ = r.s.GuildMemberRoleRemove(r.GuildID, userID, rid)
+			if err := r.s.GuildMemberRoleRemove(guildID, userID, rid); err != nil {
+				r.log.Warn("remove tier role", "guild", guildID, "user", userID, "role", rid, "err", err)
+			}
 		}
 	}
 	return nil
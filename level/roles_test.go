@@ -0,0 +1,119 @@
+package level
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gosha_bot/discord/discordtest"
+	"gosha_bot/guildcfg"
+	"gosha_bot/logging"
+)
+
+const guildID = "g1"
+
+var tiers = []guildcfg.Tier{
+	{MinLevel: 1, RoleID: "t1"},
+	{MinLevel: 25, RoleID: "t25"},
+	{MinLevel: 50, RoleID: "t50"},
+	{MinLevel: 75, RoleID: "t75"},
+	{MinLevel: 100, RoleID: "t100"},
+}
+
+func setup(t *testing.T, memberRoles ...string) (*Registry, *discordtest.Fake) {
+	t.Helper()
+	fake := discordtest.New("bot")
+	for i, id := range []string{"t1", "t25", "t50", "t75", "t100", "other"} {
+		fake.AddRole(guildID, id, i+1)
+	}
+	fake.AddMember(guildID, "u1", memberRoles...)
+
+	guilds := guildcfg.NewStore(nil)
+	if err := guilds.Apply(context.Background(), guildcfg.Guild{GuildID: guildID, Tiers: tiers}); err != nil {
+		t.Fatal(err)
+	}
+	return &Registry{s: fake, Guilds: guilds, log: logging.Discard()}, fake
+}
+
+func TestApplyLevelRolesSwapsTier(t *testing.T) {
+	cases := []struct {
+		level int
+		have  []string
+		want  []string
+	}{
+		{level: 1, have: nil, want: []string{"t1"}},
+		{level: 24, have: []string{"t1", "other"}, want: []string{"other", "t1"}},
+		{level: 25, have: []string{"t1", "other"}, want: []string{"other", "t25"}},
+		{level: 80, have: []string{"t25", "t50"}, want: []string{"t75"}},
+		{level: 150, have: []string{"t1", "t25", "t50", "t75"}, want: []string{"t100"}},
+	}
+	for _, tc := range cases {
+		r, fake := setup(t, tc.have...)
+		if err := r.applyLevelRoles(guildID, "u1", tc.level); err != nil {
+			t.Fatalf("level %d: %v", tc.level, err)
+		}
+		if got := fake.MemberRoles(guildID, "u1"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("level %d: roles = %v, want %v", tc.level, got, tc.want)
+		}
+	}
+}
+
+func TestApplyLevelRolesNoopWhenAlreadyInSync(t *testing.T) {
+	r, fake := setup(t, "t50")
+
+	if err := r.applyLevelRoles(guildID, "u1", 60); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.CallCount("GuildMemberRoleAdd") + fake.CallCount("GuildMemberRoleRemove"); n != 0 {
+		t.Fatalf("expected no role changes, got %d calls", n)
+	}
+}
+
+func TestApplyLevelRolesUnknownMember(t *testing.T) {
+	r, _ := setup(t)
+
+	if err := r.applyLevelRoles(guildID, "ghost", 10); err == nil {
+		t.Fatal("expected error for unknown member")
+	}
+}
+
+func TestApplyLevelRolesUnconfiguredGuild(t *testing.T) {
+	r, fake := setup(t)
+
+	if err := r.applyLevelRoles("other-guild", "u1", 10); err == nil {
+		t.Fatal("expected error for guild without settings")
+	}
+	if fake.CallCount("GuildMember") != 0 {
+		t.Fatal("must not touch Discord for unconfigured guild")
+	}
+}
+
+func TestApplyLevelRolesTierModes(t *testing.T) {
+	cases := []struct {
+		mode    string
+		exclude []string
+		level   int
+		have    []string
+		want    []string
+	}{
+		{mode: guildcfg.TierStack, level: 80, have: []string{"t1", "other"}, want: []string{"other", "t1", "t25", "t50", "t75"}},
+		// после штрафа снимаются только незаработанные ступени
+		{mode: guildcfg.TierStack, level: 30, have: []string{"t1", "t25", "t50", "t75"}, want: []string{"t1", "t25"}},
+		{mode: guildcfg.TierStackExcept, exclude: []string{"t1"}, level: 80, have: []string{"t1"}, want: []string{"t25", "t50", "t75"}},
+		// исключённая ступень остаётся, пока она старшая
+		{mode: guildcfg.TierStackExcept, exclude: []string{"t1"}, level: 10, have: nil, want: []string{"t1"}},
+		{mode: guildcfg.TierReplace, exclude: []string{"t1"}, level: 80, have: []string{"t25", "t50"}, want: []string{"t75"}},
+	}
+	for _, tc := range cases {
+		r, fake := setup(t, tc.have...)
+		if err := r.Guilds.SetTierMode(context.Background(), guildID, tc.mode, tc.exclude); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.applyLevelRoles(guildID, "u1", tc.level); err != nil {
+			t.Fatalf("%s level %d: %v", tc.mode, tc.level, err)
+		}
+		if got := fake.MemberRoles(guildID, "u1"); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s level %d: roles = %v, want %v", tc.mode, tc.level, got, tc.want)
+		}
+	}
+}
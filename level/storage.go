@@ -2,69 +2,58 @@ package level
 
 import (
 	"context"
-	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type UserRow struct {
-	GuildID     string
-	UserID      string
-	Username    string
-	DisplayName string
-	XP          int64
-	Level       int
-	LastMsgAt   *time.Time
-	VoiceSec    int64
-}
-
-func UpsertUser(ctx context.Context, db *pgxpool.Pool, guildID, userID, username, display string) (*UserRow, error) {
-	_, err := db.Exec(ctx, `
-INSERT INTO users_levels (guild_id, user_id, username, display_name)
-VALUES ($1, $2, NULLIF($3,''), NULLIF($4,''))
-ON CONFLICT (guild_id, user_id) DO UPDATE
-SET
-  username     = COALESCE(NULLIF($3,''), users_levels.username),
-  display_name = COALESCE(NULLIF($4,''), users_levels.display_name),
-  updated_at   = now()
-`, guildID, userID, username, display)
+// RecomputeLevels пересчитывает колонку level по xp для всех строк сервера
+// (после смены кривой). Пишет только изменившиеся строки, одной транзакцией.
+func RecomputeLevels(ctx context.Context, db *pgxpool.Pool, guildID string, levelFor func(xp int64) int) (scanned, changed int, err error) {
+	tx, err := db.Begin(ctx)
 	if err != nil {
-		return nil, err
+		return 0, 0, err
 	}
-	return GetUser(ctx, db, guildID, userID)
-}
+	defer tx.Rollback(ctx)
 
-func GetUser(ctx context.Context, db *pgxpool.Pool, guildID, userID string) (*UserRow, error) {
-	var u UserRow
-	err := db.QueryRow(ctx, `
-SELECT guild_id, user_id, COALESCE(username,''), COALESCE(display_name,''),
-       xp, level, last_msg_at, voice_sec_accum
-FROM users_levels
-WHERE guild_id=$1 AND user_id=$2
-`, guildID, userID).Scan(
-		&u.GuildID, &u.UserID, &u.Username, &u.DisplayName,
-		&u.XP, &u.Level, &u.LastMsgAt, &u.VoiceSec,
-	)
+	rows, err := tx.Query(ctx, `SELECT user_id, xp, level FROM users_levels WHERE guild_id=$1 FOR UPDATE`, guildID)
 	if err != nil {
-		return nil, err
+		return 0, 0, err
+	}
+	type upd struct {
+		userID string
+		level  int
+	}
+	var updates []upd
+	for rows.Next() {
+		var userID string
+		var xp int64
+		var level int
+		if err := rows.Scan(&userID, &xp, &level); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		scanned++
+		if l := levelFor(xp); l != level {
+			updates = append(updates, upd{userID, l})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
 	}
-	return &u, nil
-}
-
-func UpdateAfterMessage(ctx context.Context, db *pgxpool.Pool, guildID, userID string, newXP int64, now *time.Time, newLevel int) error {
-	_, err := db.Exec(ctx, `
-UPDATE users_levels
-SET xp=$1, level=$2, last_msg_at=$3, updated_at=now()
-WHERE guild_id=$4 AND user_id=$5
-`, newXP, newLevel, now, guildID, userID)
-	return err
-}
 
-func UpdateAfterVoice(ctx context.Context, db *pgxpool.Pool, guildID, userID string, newXP int64, newLevel int, addSec int64) error {
-	_, err := db.Exec(ctx, `
-UPDATE users_levels
-SET xp=$1, level=$2, voice_sec_accum = voice_sec_accum + $3, updated_at=now()
-WHERE guild_id=$4 AND user_id=$5
-`, newXP, newLevel, addSec, guildID, userID)
-	return err
+	batch := &pgx.Batch{}
+	for _, u := range updates {
+		batch.Queue(`UPDATE users_levels SET level=$1, updated_at=now() WHERE guild_id=$2 AND user_id=$3`, u.level, guildID, u.userID)
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return 0, 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, err
+	}
+	return scanned, len(updates), nil
 }
@@ -0,0 +1,185 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /levelroles add|remove|list|mode|exclude|audit|sync — ступени ролей за уровни (level_tiers), режим их выдачи и сверка
+func (r *Registry) tiersCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minLevel := float64(1)
+	return &discordgo.ApplicationCommand{
+		Name:                     "levelroles",
+		Description:              "Роли за уровни",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Выдавать роль с указанного уровня",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionInteger, Name: "level", Description: "С какого уровня", Required: true, MinValue: &minLevel},
+					{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Какую роль выдавать", Required: true},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Больше не выдавать роль за уровень",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Какую роль убрать из ступеней", Required: true},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "Показать роли за уровни"},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "mode", Description: "Копить роли за уровни или оставлять только старшую",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type: discordgo.ApplicationCommandOptionString, Name: "mode", Description: "Режим", Required: true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "только старшая роль", Value: guildcfg.TierReplace},
+							{Name: "все заработанные роли", Value: guildcfg.TierStack},
+							{Name: "все, кроме исключений", Value: guildcfg.TierStackExcept},
+						},
+					},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "exclude", Description: "Добавить роль в исключения (не копится) или убрать из них",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Роль за уровень", Required: true},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "audit", Description: "Проверить, у кого роли за уровни расходятся с уровнем (ничего не меняет)"},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "sync", Description: "Привести роли за уровни всех участников к их уровням"},
+		},
+	}
+}
+
+func (r *Registry) onTierAdd(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	lvl := int(opts["level"].IntValue())
+	role := opts["role"].RoleValue(s, ic.GuildID)
+
+	switch {
+	case role.ID == ic.GuildID:
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.everyone"))
+		return
+	case role.Managed:
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.managed"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetTier(ctx, ic.GuildID, guildcfg.Tier{MinLevel: lvl, RoleID: role.ID}); err != nil {
+		logging.Interaction(r.log, ic).Error("set tier", "level", lvl, "role", role.ID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("tier set", "level", lvl, "role", role.ID)
+	r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.added", role.ID, lvl))
+}
+
+func (r *Registry) onTierRemove(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	roleID := commands.Options(ic)["role"].RoleValue(s, ic.GuildID).ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok, err := r.Guilds.RemoveTier(ctx, ic.GuildID, roleID)
+	switch {
+	case err != nil:
+		logging.Interaction(r.log, ic).Error("remove tier", "role", roleID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.failed", err))
+	case !ok:
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.not_tier", roleID))
+	default:
+		logging.Interaction(r.log, ic).Info("tier removed", "role", roleID)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.removed", roleID))
+	}
+}
+
+func (r *Registry) onTierList(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	g := r.Guilds.Get(ic.GuildID)
+	if g == nil || len(g.Tiers) == 0 {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.empty"))
+		return
+	}
+	var b strings.Builder
+	for _, t := range g.Tiers {
+		fmt.Fprintf(&b, "lvl %d+ — <@&%s>", t.MinLevel, t.RoleID)
+		if g.TierMode == guildcfg.TierStackExcept && slices.Contains(g.TierExclude, t.RoleID) {
+			b.WriteString(i18n.T(lang, "levelroles.excluded_mark"))
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("\n" + i18n.T(lang, "levelroles.mode", tierModeName(lang, g.TierMode)))
+	r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.list", b.String()))
+}
+
+func (r *Registry) onTierMode(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	mode := commands.Options(ic)["mode"].StringValue()
+	var exclude []string
+	if g := r.Guilds.Get(ic.GuildID); g != nil {
+		exclude = g.TierExclude
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetTierMode(ctx, ic.GuildID, mode, exclude); err != nil {
+		logging.Interaction(r.log, ic).Error("set tier mode", "mode", mode, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("tier mode set", "mode", mode)
+	r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.mode_set", tierModeName(lang, mode)))
+}
+
+// onTierExclude переключает роль в списке исключений режима stack_except.
+func (r *Registry) onTierExclude(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	roleID := commands.Options(ic)["role"].RoleValue(s, ic.GuildID).ID
+	g := r.Guilds.Get(ic.GuildID)
+	if g == nil || !r.Guilds.IsTierRole(ic.GuildID, roleID) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.not_tier", roleID))
+		return
+	}
+	exclude, key := slices.Clone(g.TierExclude), "levelroles.included"
+	if i := slices.Index(exclude, roleID); i >= 0 {
+		exclude = slices.Delete(exclude, i, i+1)
+	} else {
+		exclude, key = append(exclude, roleID), "levelroles.excluded"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetTierMode(ctx, ic.GuildID, g.TierMode, exclude); err != nil {
+		logging.Interaction(r.log, ic).Error("set tier exclusions", "role", roleID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("tier exclusions set", "role", roleID, "excluded", key == "levelroles.excluded")
+	reply := i18n.T(lang, key, roleID)
+	if g.TierMode != guildcfg.TierStackExcept {
+		reply += "\n" + i18n.T(lang, "levelroles.exclude_inactive")
+	}
+	r.respondEphemeral(s, ic, reply)
+}
+
+func tierModeName(lang i18n.Lang, mode string) string {
+	if mode == "" {
+		mode = guildcfg.TierReplace
+	}
+	return i18n.T(lang, "levelroles.mode_"+mode)
+}
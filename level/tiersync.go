@@ -0,0 +1,328 @@
+package level
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gosha_bot/adminlog"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Сверка ролей за уровни (/levelroles audit|sync и плановая по xp.tier_sync_interval).
+const (
+	tierSyncPage     = 1000                   // участников за запрос GuildMembers (максимум Discord)
+	tierSyncPause    = 300 * time.Millisecond // между изменениями ролей: не упираться в rate limit
+	tierSyncProgress = 3 * time.Second        // не чаще обновлять сообщение о ходе сверки
+	tierReportLines  = 15                     // расхождений поимённо в отчёте
+)
+
+var errNoTiers = errors.New("no level roles configured")
+
+// tierDiff — расхождение ролей-ступеней участника с его уровнем.
+type tierDiff struct {
+	UserID string
+	Level  int
+	Add    []string
+	Remove []string
+}
+
+// tierReport — итог сверки; в режиме исправления Diffs — то, что исправлялось.
+type tierReport struct {
+	Checked int        // участников сервера (без ботов)
+	Diffs   []tierDiff // у кого роли расходятся с уровнем
+	Left    int        // строк users_levels, чьих владельцев нет на сервере
+	Failed  int        // изменений ролей, которые Discord не принял
+}
+
+func (t tierReport) roles() (added, removed int) {
+	for _, d := range t.Diffs {
+		added += len(d.Add)
+		removed += len(d.Remove)
+	}
+	return added, removed
+}
+
+// tierDiffFor — что выдать и снять участнику с ролями have на уровне level.
+// Без строки в users_levels (hasXP == false) ступени не выдаются, только снимаются лишние.
+func tierDiffFor(cfg *guildcfg.Guild, have []string, level int, hasXP bool) (add, remove []string) {
+	want, rm := roleForLevel(cfg, level)
+	if !hasXP {
+		rm, want = append(rm, want...), nil
+	}
+	for _, rid := range want {
+		if rid != "" && !slices.Contains(have, rid) {
+			add = append(add, rid)
+		}
+	}
+	for _, rid := range rm {
+		if rid != "" && slices.Contains(have, rid) {
+			remove = append(remove, rid)
+		}
+	}
+	return add, remove
+}
+
+// reconcileTiers сверяет роли-ступени всех участников сервера с уровнями из users_levels
+// и, если fix, исправляет их с паузой pause между запросами. progress вызывается после
+// каждого участника; отмена ctx прерывает сверку и возвращает то, что успели.
+func (r *Registry) reconcileTiers(ctx context.Context, guildID string, fix bool, pause time.Duration, progress func(tierReport)) (tierReport, error) {
+	var rep tierReport
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil || len(cfg.Tiers) == 0 {
+		return rep, errNoTiers
+	}
+	levels, err := r.Store.Levels(ctx, guildID)
+	if err != nil {
+		return rep, err
+	}
+	seen := 0
+	for after := ""; ; {
+		members, err := r.s.GuildMembers(guildID, after, tierSyncPage)
+		if err != nil {
+			return rep, err
+		}
+		for _, m := range members {
+			after = m.User.ID
+			if m.User.Bot {
+				continue
+			}
+			level, hasXP := levels[m.User.ID]
+			if hasXP {
+				seen++
+			}
+			rep.Checked++
+			if add, remove := tierDiffFor(cfg, m.Roles, level, hasXP); len(add)+len(remove) > 0 {
+				rep.Diffs = append(rep.Diffs, tierDiff{UserID: m.User.ID, Level: level, Add: add, Remove: remove})
+				if fix {
+					if err := r.fixTiers(ctx, guildID, m.User.ID, add, remove, pause, "sync", &rep); err != nil {
+						return rep, err
+					}
+				}
+			}
+			if progress != nil {
+				progress(rep)
+			}
+		}
+		if len(members) < tierSyncPage {
+			break
+		}
+	}
+	rep.Left = len(levels) - seen
+	return rep, nil
+}
+
+// fixTiers выдаёт add и снимает remove, выжидая pause перед каждым запросом к Discord;
+// отказы Discord считаются в rep.Failed, ошибка — только отмена ctx.
+func (r *Registry) fixTiers(ctx context.Context, guildID, userID string, add, remove []string, pause time.Duration, source string, rep *tierReport) error {
+	call := func(op string, roleID string, fn func(string, string, string, ...discordgo.RequestOption) error) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+		if err := fn(guildID, userID, roleID); err != nil {
+			rep.Failed++
+			r.log.Warn(op+" tier role", "guild", guildID, "user", userID, "role", roleID, "source", source, "err", err)
+		}
+		return nil
+	}
+	for _, rid := range add {
+		if err := call("add", rid, r.s.GuildMemberRoleAdd); err != nil {
+			return err
+		}
+	}
+	for _, rid := range remove {
+		if err := call("remove", rid, r.s.GuildMemberRoleRemove); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stopContext — контекст, который отменяется при Shutdown.
+func (r *Registry) stopContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-r.stopTicker:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// startTierSync отмечает, что на сервере идёт сверка; false — уже идёт.
+func (r *Registry) startTierSync(guildID string) bool {
+	r.muTierSync.Lock()
+	defer r.muTierSync.Unlock()
+	if r.tierSyncing == nil {
+		r.tierSyncing = make(map[string]bool)
+	}
+	if r.tierSyncing[guildID] {
+		return false
+	}
+	r.tierSyncing[guildID] = true
+	return true
+}
+
+func (r *Registry) finishTierSync(guildID string) {
+	r.muTierSync.Lock()
+	defer r.muTierSync.Unlock()
+	delete(r.tierSyncing, guildID)
+}
+
+// tierSyncLoop раз в every сверяет и исправляет роли за уровни на всех серверах со ступенями.
+func (r *Registry) tierSyncLoop(every time.Duration) {
+	defer close(r.tierSyncDone)
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-r.stopTicker:
+			return
+		case <-t.C:
+		}
+		for _, id := range r.Guilds.IDs() {
+			if g := r.Guilds.Get(id); g == nil || len(g.Tiers) == 0 || !r.startTierSync(id) {
+				continue
+			}
+			ctx, cancel := r.stopContext()
+			rep, err := r.reconcileTiers(ctx, id, true, tierSyncPause, nil)
+			cancel()
+			r.finishTierSync(id)
+			r.logTierSync(id, nil, rep)
+			if err != nil {
+				r.log.Error("tier sync", "guild", id, "err", err)
+			}
+		}
+	}
+}
+
+func (r *Registry) logTierSync(guildID string, moderator *discordgo.User, rep tierReport) {
+	added, removed := rep.roles()
+	r.log.Info("tier roles synced", "guild", guildID, "checked", rep.Checked, "fixed", len(rep.Diffs),
+		"added", added, "removed", removed, "failed", rep.Failed)
+	if r.AdminLog != nil && len(rep.Diffs) > 0 {
+		r.AdminLog.PostTierSync(guildID, moderator, adminlog.TierSync{
+			Checked: rep.Checked, Fixed: len(rep.Diffs), Added: added, Removed: removed, Failed: rep.Failed,
+		})
+	}
+}
+
+// ====== /levelroles audit|sync ======
+
+func (r *Registry) onTierAudit(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.runTierSync(s, ic, false)
+}
+
+func (r *Registry) onTierSync(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.runTierSync(s, ic, true)
+}
+
+// runTierSync отвечает отложенным сообщением и сверяет роли в фоне, обновляя его по ходу.
+func (r *Registry) runTierSync(s *discordgo.Session, ic *discordgo.InteractionCreate, fix bool) {
+	lang := i18n.For(ic.Interaction)
+	if r.Store == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.no_db"))
+		return
+	}
+	if !r.startTierSync(ic.GuildID) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "levelroles.sync_busy"))
+		return
+	}
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		r.finishTierSync(ic.GuildID)
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+		return
+	}
+	r.background.Add(1)
+	go func() {
+		defer r.background.Done()
+		defer r.finishTierSync(ic.GuildID)
+		r.tierSyncReply(s, ic, fix, "")
+	}()
+}
+
+// tierSyncReply сверяет роли за уровни сервера ic.GuildID, показывая ход и итог в ответе
+// на интеракцию (под заголовком head, если он есть). Сверку на сервере уже занял вызывающий.
+func (r *Registry) tierSyncReply(s *discordgo.Session, ic *discordgo.InteractionCreate, fix bool, head string) {
+	lang := i18n.For(ic.Interaction)
+	edit := func(content string) {
+		if head != "" {
+			content = head + "\n\n" + content
+		}
+		if _, err := s.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{Content: &content}); err != nil {
+			// токен интеракции живёт 15 минут: большой сервер может сверяться дольше
+			logging.Interaction(r.log, ic).Debug("edit tier sync progress", "err", err)
+		}
+	}
+
+	ctx, cancel := r.stopContext()
+	defer cancel()
+	edit(i18n.T(lang, "levelroles.sync_progress", 0, 0))
+	last := time.Now()
+	rep, err := r.reconcileTiers(ctx, ic.GuildID, fix, tierSyncPause, func(rep tierReport) {
+		if time.Since(last) >= tierSyncProgress {
+			last = time.Now()
+			edit(i18n.T(lang, "levelroles.sync_progress", rep.Checked, len(rep.Diffs)))
+		}
+	})
+	if fix {
+		r.logTierSync(ic.GuildID, ic.Member.User, rep)
+	}
+	switch {
+	case errors.Is(err, errNoTiers):
+		edit(i18n.T(lang, "levelroles.empty"))
+	case err != nil:
+		logging.Interaction(r.log, ic).Error("tier sync", "fix", fix, "err", err)
+		edit(i18n.T(lang, "levelroles.sync_failed", err) + "\n\n" + tierReportText(lang, rep, fix))
+	default:
+		edit(tierReportText(lang, rep, fix))
+	}
+}
+
+func tierReportText(lang i18n.Lang, rep tierReport, fix bool) string {
+	added, removed := rep.roles()
+	key := "levelroles.audit_report"
+	if fix {
+		key = "levelroles.sync_report"
+	}
+	var b strings.Builder
+	b.WriteString(i18n.T(lang, key, rep.Checked, len(rep.Diffs), added, removed))
+	if rep.Left > 0 {
+		b.WriteString("\n" + i18n.T(lang, "levelroles.report_left", rep.Left))
+	}
+	if rep.Failed > 0 {
+		b.WriteString("\n" + i18n.T(lang, "levelroles.report_failed", rep.Failed))
+	}
+	if len(rep.Diffs) > 0 {
+		b.WriteString("\n")
+	}
+	for i, d := range rep.Diffs {
+		if i == tierReportLines {
+			b.WriteString("\n" + i18n.T(lang, "levelroles.report_more", len(rep.Diffs)-i))
+			break
+		}
+		fmt.Fprintf(&b, "\n<@%s> (lvl %d):", d.UserID, d.Level)
+		for _, rid := range d.Add {
+			fmt.Fprintf(&b, " +<@&%s>", rid)
+		}
+		for _, rid := range d.Remove {
+			fmt.Fprintf(&b, " −<@&%s>", rid)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,90 @@
+package level
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+)
+
+func TestReconcileTiersAuditThenSync(t *testing.T) {
+	r, fake := setup(t, "t1", "other")   // уровень 50, а роль осталась от первой ступени
+	fake.AddMember(guildID, "u2", "t25") // XP нет — ступень лишняя
+	fake.AddMember(guildID, "u3", "t1")  // в порядке
+	store := xpstore.NewMemory(levelcurve.Default)
+	r.Store, r.Curve = store, levelcurve.Default
+	ctx := context.Background()
+	for user, level := range map[string]int{"u1": 50, "u3": 10, "gone": 30} {
+		if _, err := store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: user, Delta: levelcurve.Default.Threshold(level)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	audit, err := r.reconcileTiers(ctx, guildID, false, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Checked != 3 || audit.Left != 1 || len(audit.Diffs) != 2 {
+		t.Fatalf("audit = %+v", audit)
+	}
+	if d := audit.Diffs[0]; d.UserID != "u1" || d.Level != 50 || !slices.Equal(d.Add, []string{"t50"}) || !slices.Equal(d.Remove, []string{"t1"}) {
+		t.Fatalf("u1 diff = %+v", d)
+	}
+	if d := audit.Diffs[1]; d.UserID != "u2" || len(d.Add) != 0 || !slices.Equal(d.Remove, []string{"t25"}) {
+		t.Fatalf("u2 diff = %+v", d)
+	}
+	if n := fake.CallCount("GuildMemberRoleAdd") + fake.CallCount("GuildMemberRoleRemove"); n != 0 {
+		t.Fatalf("audit changed roles: %d calls", n)
+	}
+
+	var progress int
+	sync, err := r.reconcileTiers(ctx, guildID, true, 0, func(tierReport) { progress++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, removed := sync.roles(); len(sync.Diffs) != 2 || added != 1 || removed != 2 || sync.Failed != 0 || progress != 3 {
+		t.Fatalf("sync = %+v (progress %d)", sync, progress)
+	}
+	for user, want := range map[string][]string{"u1": {"other", "t50"}, "u2": {}, "u3": {"t1"}} {
+		if got := fake.MemberRoles(guildID, user); !slices.Equal(got, want) {
+			t.Errorf("%s roles = %v, want %v", user, got, want)
+		}
+	}
+
+	if again, err := r.reconcileTiers(ctx, guildID, false, 0, nil); err != nil || len(again.Diffs) != 0 {
+		t.Fatalf("after sync: %+v, %v", again, err)
+	}
+}
+
+func TestReconcileTiersSkipsWhileRunning(t *testing.T) {
+	r, _ := setup(t)
+	if !r.startTierSync(guildID) {
+		t.Fatal("first start refused")
+	}
+	if r.startTierSync(guildID) {
+		t.Fatal("second concurrent start allowed")
+	}
+	r.finishTierSync(guildID)
+	if !r.startTierSync(guildID) {
+		t.Fatal("start after finish refused")
+	}
+}
+
+func TestReapplyLevelRolesAfterResetAll(t *testing.T) {
+	r, fake := setup(t, "t50", "other")
+	fake.AddMember(guildID, "u2") // ступеней нет — менять нечего
+	r.background.Add(1)
+	r.reapplyLevelRoles(guildID, []xpstore.MemberResult{
+		{UserID: "u1", Result: xpstore.Result{Level: 0}},
+		{UserID: "u2", Result: xpstore.Result{Level: 0}},
+	})
+	r.background.Wait()
+	if got, want := fake.MemberRoles(guildID, "u1"), []string{"other"}; !slices.Equal(got, want) {
+		t.Fatalf("u1 roles = %v, want %v", got, want)
+	}
+	if n := fake.CallCount("GuildMemberRoleAdd") + fake.CallCount("GuildMemberRoleRemove"); n != 1 {
+		t.Fatalf("role calls = %d, want 1", n)
+	}
+}
@@ -0,0 +1,311 @@
+package level
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// VoiceInterval — одно пребывание участника в одном канале (с XP или без).
+type VoiceInterval struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+	Start     time.Time
+	End       time.Time
+	XP        int64 // начислено за сессию
+}
+
+// VoiceHistory — таблица voice_intervals.
+type VoiceHistory interface {
+	Record(ctx context.Context, iv VoiceInterval) error
+	Since(ctx context.Context, guildID, userID string, since time.Time) ([]VoiceInterval, error) // закончившиеся после since
+}
+
+type pgVoiceHistory struct {
+	db *pgxpool.Pool
+}
+
+// NewPGVoiceHistory — VoiceHistory поверх Postgres.
+func NewPGVoiceHistory(db *pgxpool.Pool) VoiceHistory { return &pgVoiceHistory{db: db} }
+
+func (p *pgVoiceHistory) Record(ctx context.Context, iv VoiceInterval) error {
+	_, err := p.db.Exec(ctx, `
+INSERT INTO voice_intervals (guild_id, user_id, channel_id, started_at, ended_at, xp)
+VALUES ($1, $2, $3, $4, $5, $6)`,
+		iv.GuildID, iv.UserID, iv.ChannelID, iv.Start, iv.End, iv.XP)
+	return err
+}
+
+func (p *pgVoiceHistory) Since(ctx context.Context, guildID, userID string, since time.Time) ([]VoiceInterval, error) {
+	rows, err := p.db.Query(ctx, `
+SELECT channel_id, started_at, ended_at, xp FROM voice_intervals
+WHERE guild_id=$1 AND user_id=$2 AND ended_at > $3
+ORDER BY started_at`, guildID, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []VoiceInterval
+	for rows.Next() {
+		iv := VoiceInterval{GuildID: guildID, UserID: userID}
+		if err := rows.Scan(&iv.ChannelID, &iv.Start, &iv.End, &iv.XP); err != nil {
+			return nil, err
+		}
+		out = append(out, iv)
+	}
+	return out, rows.Err()
+}
+
+// closeVoice начисляет XP за хвост закрытой сессии и, если пребывание в канале закончилось,
+// пишет его в историю — в том числе без XP (один, заглушён, AFK).
+func (r *Registry) closeVoice(c closedVoice, now time.Time) {
+	var xp int64
+	if c.session.ChannelID != "" {
+		_, _, xp = r.addVoiceXPWithCarry(c.key.GuildID, c.key.UserID, c.session.ChannelID, c.session.From, now)
+	}
+	if !c.left {
+		// участник остался в канале: хвост XP запишется вместе с пребыванием
+		if xp > 0 {
+			r.creditStay(c.key, c.stay.Since, xp)
+		}
+		return
+	}
+	if r.History == nil || c.stay.Bot || c.stay.Since.IsZero() || !c.stay.Since.Before(now) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	iv := VoiceInterval{
+		GuildID: c.key.GuildID, UserID: c.key.UserID, ChannelID: c.stay.ChannelID,
+		Start: c.stay.Since, End: now, XP: c.stay.XP + xp,
+	}
+	if err := r.History.Record(ctx, iv); err != nil {
+		r.log.Error("record voice interval", "guild", iv.GuildID, "user", iv.UserID, "err", err)
+	}
+}
+
+// ====== Сводка ======
+
+type voiceSummary struct {
+	Total    time.Duration
+	Sessions int
+	XP       int64
+	Longest  VoiceInterval // обрезанная по периоду
+	Channels map[string]time.Duration
+	Hours    [24]time.Duration // по часу суток (UTC)
+}
+
+// summarizeVoice считает сводку по интервалам, обрезанным по [since, now] (since нулевой — без начала).
+func summarizeVoice(intervals []VoiceInterval, since, now time.Time) voiceSummary {
+	sum := voiceSummary{Channels: map[string]time.Duration{}}
+	for _, iv := range intervals {
+		if !since.IsZero() && iv.Start.Before(since) {
+			iv.Start = since
+		}
+		if iv.End.After(now) {
+			iv.End = now
+		}
+		d := iv.End.Sub(iv.Start)
+		if d <= 0 {
+			continue
+		}
+		sum.Total += d
+		sum.Sessions++
+		sum.XP += iv.XP
+		sum.Channels[iv.ChannelID] += d
+		if d > sum.Longest.End.Sub(sum.Longest.Start) {
+			sum.Longest = iv
+		}
+		// раскладываем по часам суток
+		for t := iv.Start.UTC(); t.Before(iv.End); {
+			next := t.Truncate(time.Hour).Add(time.Hour)
+			if next.After(iv.End) {
+				next = iv.End
+			}
+			sum.Hours[t.Hour()] += next.Sub(t)
+			t = next
+		}
+	}
+	return sum
+}
+
+// Периоды /voicestats.
+const (
+	periodDay   = "day"
+	periodWeek  = "week"
+	periodMonth = "month"
+	periodAll   = "all"
+)
+
+func periodSince(period string, now time.Time) time.Time {
+	switch period {
+	case periodDay:
+		return now.Add(-24 * time.Hour)
+	case periodMonth:
+		return now.AddDate(0, 0, -30)
+	case periodAll:
+		return time.Time{}
+	default:
+		return now.AddDate(0, 0, -7)
+	}
+}
+
+// ====== /voicestats ======
+
+func (r *Registry) voiceStatsCommand() *discordgo.ApplicationCommand {
+	return &discordgo.ApplicationCommand{
+		Name:        "voicestats",
+		Description: "Статистика войса (своя или другого пользователя)",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Пользователь (по умолчанию — ты)"},
+			{
+				Type: discordgo.ApplicationCommandOptionString, Name: "period", Description: "Период (по умолчанию — неделя)",
+				Choices: []*discordgo.ApplicationCommandOptionChoice{
+					{Name: "сутки", Value: periodDay},
+					{Name: "неделя", Value: periodWeek},
+					{Name: "месяц", Value: periodMonth},
+					{Name: "всё время", Value: periodAll},
+				},
+			},
+		},
+	}
+}
+
+func (r *Registry) onVoiceStats(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Member == nil {
+		return
+	}
+	lang := i18n.For(ic.Interaction)
+	if r.History == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "voicestats.no_db"))
+		return
+	}
+	targetID, period := ic.Member.User.ID, periodWeek
+	for _, o := range ic.ApplicationCommandData().Options {
+		switch o.Name {
+		case "user":
+			if u := o.UserValue(nil); u != nil {
+				targetID = u.ID
+			}
+		case "period":
+			period = o.StringValue()
+		}
+	}
+
+	now := time.Now().UTC()
+	since := periodSince(period, now)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	intervals, err := r.History.Since(ctx, ic.GuildID, targetID, since)
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("load voice intervals", "target", targetID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "voicestats.failed"))
+		return
+	}
+	// текущее пребывание ещё не в истории — добавим его до текущего момента
+	r.muVoice.Lock()
+	key := voiceKey{GuildID: ic.GuildID, UserID: targetID}
+	if st, ok := r.voiceState[key]; ok && !st.Since.IsZero() {
+		iv := VoiceInterval{ChannelID: st.ChannelID, Start: st.Since, End: now, XP: st.XP}
+		if vs, ok := r.voiceJoin[key]; ok && vs.ChannelID == st.ChannelID {
+			iv.XP += vs.XP
+		}
+		intervals = append(intervals, iv)
+	}
+	r.muVoice.Unlock()
+
+	embed := voiceStatsEmbed(lang, targetID, period, summarizeVoice(intervals, since, now))
+	err = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Embeds:          []*discordgo.MessageEmbed{embed},
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
+
+func voiceStatsEmbed(lang i18n.Lang, userID, period string, sum voiceSummary) *discordgo.MessageEmbed {
+	embed := &discordgo.MessageEmbed{
+		Title:       i18n.T(lang, "voicestats.title", i18n.T(lang, "voicestats.period_"+period)),
+		Description: fmt.Sprintf("<@%s>", userID),
+		Color:       0x5865F2,
+		Footer:      &discordgo.MessageEmbedFooter{Text: i18n.T(lang, "voicestats.footer")},
+	}
+	if sum.Sessions == 0 {
+		embed.Description += "\n" + i18n.T(lang, "voicestats.empty")
+		return embed
+	}
+
+	type chanTime struct {
+		id string
+		d  time.Duration
+	}
+	var chans []chanTime
+	for id, d := range sum.Channels {
+		chans = append(chans, chanTime{id, d})
+	}
+	slices.SortFunc(chans, func(a, b chanTime) int { return cmp.Compare(b.d, a.d) })
+	var top strings.Builder
+	for i, c := range chans {
+		if i == 5 {
+			fmt.Fprintf(&top, "%s\n", i18n.T(lang, "voicestats.more_channels", len(chans)-i))
+			break
+		}
+		fmt.Fprintf(&top, "<#%s> — %s\n", c.id, formatVoiceDuration(lang, c.d))
+	}
+
+	longest := sum.Longest
+	embed.Fields = []*discordgo.MessageEmbedField{
+		{Name: i18n.T(lang, "voicestats.total"), Value: formatVoiceDuration(lang, sum.Total), Inline: true},
+		{Name: i18n.T(lang, "voicestats.sessions"), Value: fmt.Sprint(sum.Sessions), Inline: true},
+		{Name: i18n.T(lang, "field.xp"), Value: fmt.Sprint(sum.XP), Inline: true},
+		{
+			Name: i18n.T(lang, "voicestats.longest"),
+			Value: i18n.T(lang, "voicestats.longest_value",
+				formatVoiceDuration(lang, longest.End.Sub(longest.Start)), longest.ChannelID, longest.Start.Unix()),
+		},
+		{Name: i18n.T(lang, "voicestats.channels"), Value: top.String()},
+		{Name: i18n.T(lang, "voicestats.hours"), Value: "```\n" + hourHistogram(sum.Hours) + "\n```"},
+	}
+	return embed
+}
+
+// hourHistogram — спарклайн по 24 часам суток и подписи часов под ним.
+func hourHistogram(hours [24]time.Duration) string {
+	const bars = "▁▂▃▄▅▆▇█"
+	levels := []rune(bars)
+	peak := slices.Max(hours[:])
+	var b strings.Builder
+	for _, d := range hours {
+		switch {
+		case d == 0:
+			b.WriteRune(' ')
+		case peak > 0:
+			b.WriteRune(levels[int(int64(d)*int64(len(levels)-1)/int64(peak))])
+		}
+	}
+	b.WriteString("\n0     6     12    18   23")
+	return b.String()
+}
+
+// formatVoiceDuration — «3ч 05м»; меньше минуты — «<1м».
+func formatVoiceDuration(lang i18n.Lang, d time.Duration) string {
+	if d < time.Minute {
+		return i18n.T(lang, "voicestats.under_minute")
+	}
+	m := int64(d / time.Minute)
+	return i18n.T(lang, "voicestats.duration", m/60, m%60)
+}
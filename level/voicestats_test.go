@@ -0,0 +1,121 @@
+package level
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+type fakeHistory struct {
+	mu  sync.Mutex
+	ivs []VoiceInterval
+}
+
+func (f *fakeHistory) Record(_ context.Context, iv VoiceInterval) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ivs = append(f.ivs, iv)
+	return nil
+}
+
+func (f *fakeHistory) Since(_ context.Context, guildID, userID string, since time.Time) ([]VoiceInterval, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []VoiceInterval
+	for _, iv := range f.ivs {
+		if iv.GuildID == guildID && iv.UserID == userID && iv.End.After(since) {
+			out = append(out, iv)
+		}
+	}
+	return out, nil
+}
+
+func TestClosedSessionsAreRecorded(t *testing.T) {
+	r := setupVoice(t)
+	h := &fakeHistory{}
+	r.History = h
+
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", nil)) // один — XP не капает, но время идёт
+	r.onVoiceStateUpdate(nil, voiceState("b", "vc", nil))
+	r.onVoiceStateUpdate(nil, voiceState("b", "", nil)) // b ушёл — a снова один
+	if len(h.ivs) != 1 || h.ivs[0].UserID != "b" {
+		t.Fatalf("after b left: %+v, want only b's interval", h.ivs)
+	}
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", func(vs *discordgo.VoiceState) { vs.SelfDeaf = true }))
+	r.onVoiceStateUpdate(nil, voiceState("a", "afk", nil)) // сменил канал — пребывание в vc закончилось
+	r.onVoiceStateUpdate(nil, voiceState("a", "", nil))
+
+	if len(h.ivs) != 3 {
+		t.Fatalf("recorded %d intervals, want 3: %+v", len(h.ivs), h.ivs)
+	}
+	a, b, afk := h.ivs[1], h.ivs[0], h.ivs[2]
+	if a.UserID != "a" || a.ChannelID != "vc" || a.Start.After(b.Start) || a.End.Before(b.End) {
+		t.Errorf("a's stay in vc must cover the whole visit, alone and deaf time included: a=%+v b=%+v", a, b)
+	}
+	if afk.UserID != "a" || afk.ChannelID != "afk" || afk.XP != 0 || afk.End.Before(afk.Start) {
+		t.Errorf("afk interval %+v", afk)
+	}
+}
+
+func TestStayCollectsXPOfItsSessions(t *testing.T) {
+	r := setupVoice(t)
+	h := &fakeHistory{}
+	r.History = h
+	deaf := func(vs *discordgo.VoiceState) { vs.SelfDeaf = true }
+	ka := voiceKey{GuildID: guildID, UserID: "a"}
+
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", nil))
+	r.onVoiceStateUpdate(nil, voiceState("b", "vc", nil))
+	vs := r.voiceJoin[ka]
+	vs.XP = 5 // начислил тикер
+	r.voiceJoin[ka] = vs
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", deaf)) // XP-сессия закрылась, пребывание — нет
+	r.onVoiceStateUpdate(nil, voiceState("a", "vc", nil))
+	vs = r.voiceJoin[ka]
+	vs.XP = 3
+	r.voiceJoin[ka] = vs
+	r.onVoiceStateUpdate(nil, voiceState("a", "", nil))
+
+	if len(h.ivs) != 1 || h.ivs[0].UserID != "a" || h.ivs[0].XP != 8 {
+		t.Fatalf("intervals = %+v, want one stay of a with 8 XP", h.ivs)
+	}
+}
+
+func TestSummarizeVoice(t *testing.T) {
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	ivs := []VoiceInterval{
+		{ChannelID: "talk", Start: at(9, 30), End: at(11, 0), XP: 150},
+		{ChannelID: "game", Start: at(20, 0), End: at(20, 45), XP: 75},
+		{ChannelID: "talk", Start: at(-2, 0), End: at(1, 0), XP: 300}, // началось до периода
+	}
+	sum := summarizeVoice(ivs, day, at(23, 0))
+
+	if sum.Total != 3*time.Hour+15*time.Minute {
+		t.Errorf("total = %v", sum.Total)
+	}
+	if sum.Sessions != 3 || sum.XP != 525 {
+		t.Errorf("sessions = %d, xp = %d", sum.Sessions, sum.XP)
+	}
+	if sum.Channels["talk"] != 2*time.Hour+30*time.Minute || sum.Channels["game"] != 45*time.Minute {
+		t.Errorf("channels = %v", sum.Channels)
+	}
+	if !sum.Longest.Start.Equal(at(9, 30)) {
+		t.Errorf("longest = %+v", sum.Longest)
+	}
+	for h, want := range map[int]time.Duration{0: time.Hour, 9: 30 * time.Minute, 10: time.Hour, 20: 45 * time.Minute, 22: 0} {
+		if sum.Hours[h] != want {
+			t.Errorf("hour %d = %v, want %v", h, sum.Hours[h], want)
+		}
+	}
+
+	hist := hourHistogram(sum.Hours)
+	bars := []rune(strings.Split(hist, "\n")[0])
+	if len(bars) != 24 || bars[10] != '█' || bars[3] != ' ' {
+		t.Errorf("histogram %q", hist)
+	}
+}
@@ -0,0 +1,271 @@
+package level
+
+import (
+	"context"
+	"time"
+
+	"gosha_bot/discord"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SavedVoice — открытая войс-сессия, сохранённая на случай рестарта.
+type SavedVoice struct {
+	GuildID   string
+	UserID    string
+	ChannelID string
+	SeenAt    time.Time // до этого момента бот точно видел участника в канале
+}
+
+// VoiceSessionStore — таблица voice_sessions. Save заменяет всё содержимое.
+type VoiceSessionStore interface {
+	Load(ctx context.Context) ([]SavedVoice, error)
+	Save(ctx context.Context, sessions []SavedVoice) error
+}
+
+type pgVoiceSessionStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPGVoiceSessionStore — VoiceSessionStore поверх Postgres.
+func NewPGVoiceSessionStore(db *pgxpool.Pool) VoiceSessionStore { return &pgVoiceSessionStore{db: db} }
+
+func (p *pgVoiceSessionStore) Load(ctx context.Context) ([]SavedVoice, error) {
+	rows, err := p.db.Query(ctx, `SELECT guild_id, user_id, channel_id, seen_at FROM voice_sessions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SavedVoice
+	for rows.Next() {
+		var v SavedVoice
+		if err := rows.Scan(&v.GuildID, &v.UserID, &v.ChannelID, &v.SeenAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (p *pgVoiceSessionStore) Save(ctx context.Context, sessions []SavedVoice) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `DELETE FROM voice_sessions`); err != nil {
+		return err
+	}
+	batch := &pgx.Batch{}
+	for _, v := range sessions {
+		batch.Queue(`INSERT INTO voice_sessions (guild_id, user_id, channel_id, seen_at) VALUES ($1, $2, $3, $4)`,
+			v.GuildID, v.UserID, v.ChannelID, v.SeenAt)
+	}
+	if batch.Len() > 0 {
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// ====== Восстановление войс-сессий: GuildCreate/Ready/Resumed ======
+
+// пауза между REST-запросами участников при восстановлении войса (rate limit); в тестах обнуляется
+var memberLookupDelay = 250 * time.Millisecond
+
+// onGuildCreate — сервер стал доступен (старт или новая сессия gateway): кто уже сидит в войсе,
+// тому XP начинает капать сразу, не дожидаясь перезахода.
+func (r *Registry) onGuildCreate(s *discordgo.Session, gc *discordgo.GuildCreate) {
+	r.reconcileGuild(gc.ID, gc.VoiceStates, gc.Members, true)
+}
+
+// onReady — новая сессия gateway: что было до неё, неизвестно. Серверы закрываются
+// по (пустым) данным Ready, а заново заполняются следом идущими GuildCreate.
+func (r *Registry) onReady(s *discordgo.Session, ev *discordgo.Ready) {
+	for _, g := range ev.Guilds {
+		r.reconcileGuild(g.ID, g.VoiceStates, g.Members, false)
+	}
+}
+
+// onResumed — сессия продолжилась после обрыва: сверяемся с войс-состояниями из State.
+func (r *Registry) onResumed(s *discordgo.Session, _ *discordgo.Resumed) {
+	if s == nil || s.State == nil {
+		return
+	}
+	type guildVoice struct {
+		id     string
+		states []*discordgo.VoiceState
+	}
+	var guilds []guildVoice
+	s.State.RLock()
+	for _, g := range s.State.Guilds {
+		guilds = append(guilds, guildVoice{g.ID, append([]*discordgo.VoiceState(nil), g.VoiceStates...)})
+	}
+	s.State.RUnlock()
+	for _, g := range guilds {
+		r.reconcileGuild(g.id, g.states, nil, false)
+	}
+}
+
+// reconcileGuild заменяет войс-состояния сервера на states и пересчитывает все его сессии:
+// ушедшим за время обрыва XP начисляется до текущего момента, пришедшим — открывается.
+// members — участники, пришедшие вместе с states (GuildCreate), чтобы не спрашивать их по REST.
+// final — states полные (GuildCreate): сохранённые до рестарта сессии сервера больше не нужны.
+func (r *Registry) reconcileGuild(guildID string, states []*discordgo.VoiceState, members []*discordgo.Member, final bool) {
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var err error
+		cfg, err = r.Guilds.Ensure(ctx, guildID) // GuildCreate мог прийти раньше, чем main создал настройки
+		cancel()
+		if err != nil || cfg == nil {
+			return
+		}
+	}
+	known := make(map[string]*discordgo.Member, len(members))
+	for _, m := range members {
+		if m != nil && m.User != nil {
+			known[m.User.ID] = m
+		}
+	}
+	presence := make(map[voiceKey]voicePresence, len(states))
+	lookups := 0
+	for _, vs := range states {
+		if vs == nil || vs.ChannelID == "" {
+			continue
+		}
+		p := presenceOf(vs)
+		if vs.Member == nil {
+			p.Bot = r.voiceMemberBot(guildID, vs.UserID, known, &lookups)
+		}
+		presence[voiceKey{GuildID: guildID, UserID: vs.UserID}] = p
+	}
+	now := time.Now().UTC()
+
+	r.muVoice.Lock()
+	if r.closed {
+		r.muVoice.Unlock()
+		return
+	}
+	seen := map[voiceKey]bool{}
+	var keys []voiceKey
+	add := func(k voiceKey) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	left := map[voiceKey]voicePresence{}
+	move := func(k voiceKey, p voicePresence) {
+		if st, ok := r.movePresenceLocked(k, p, now); ok {
+			left[k] = st
+		}
+		add(k)
+	}
+	for k := range r.voiceState {
+		if _, still := presence[k]; k.GuildID == guildID && !still {
+			move(k, voicePresence{})
+		}
+	}
+	for k := range r.voiceJoin {
+		if k.GuildID == guildID {
+			add(k)
+		}
+	}
+	for k, p := range presence {
+		move(k, p)
+	}
+	closed := r.closeStaysLocked(r.reevaluateLocked(cfg, keys, r.listenersLocked(guildID, nil), now), left)
+	if final {
+		for k := range r.pendingVoice {
+			if k.GuildID == guildID {
+				delete(r.pendingVoice, k)
+			}
+		}
+	}
+	open := 0
+	for k := range r.voiceJoin {
+		if k.GuildID == guildID {
+			open++
+		}
+	}
+	r.muVoice.Unlock()
+
+	for _, c := range closed {
+		r.closeVoice(c, now)
+	}
+	r.log.Debug("voice sessions reconciled", "guild", guildID, "in_voice", len(presence), "earning", open, "closed", len(closed))
+}
+
+// voiceMemberBot — бот ли участник, у чьего войс-состояния нет Member: сначала участники
+// из GuildCreate и State, и только потом REST — с паузой между запросами, чтобы
+// переподключение к большому серверу не упёрлось в rate limit.
+func (r *Registry) voiceMemberBot(guildID, userID string, known map[string]*discordgo.Member, lookups *int) bool {
+	if m := known[userID]; m != nil {
+		return m.User.Bot
+	}
+	if m := discord.StateMember(r.s, guildID, userID); m != nil && m.User != nil {
+		return m.User.Bot
+	}
+	if *lookups > 0 {
+		time.Sleep(memberLookupDelay)
+	}
+	*lookups++
+	m, err := r.s.GuildMember(guildID, userID)
+	return err == nil && m.User != nil && m.User.Bot
+}
+
+// sessionStartLocked — с какого момента считать новую сессию: обычно now, но если участник
+// сидел в том же канале до рестарта, простой засчитывается (не больше xp.voice_downtime_credit).
+func (r *Registry) sessionStartLocked(k voiceKey, channelID string, now time.Time) time.Time {
+	saved, ok := r.pendingVoice[k]
+	if !ok {
+		return now
+	}
+	delete(r.pendingVoice, k)
+	if saved.ChannelID != channelID || !saved.SeenAt.Before(now) {
+		return now
+	}
+	if from := now.Add(-r.XP.VoiceDowntimeCredit); saved.SeenAt.Before(from) {
+		return from
+	}
+	return saved.SeenAt
+}
+
+// restoreVoiceSessions читает сессии, сохранённые до рестарта; их заберут GuildCreate.
+func (r *Registry) restoreVoiceSessions() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	saved, err := r.Sessions.Load(ctx)
+	if err != nil {
+		r.log.Error("restore voice sessions", "err", err)
+		return
+	}
+	r.muVoice.Lock()
+	for _, v := range saved {
+		r.pendingVoice[voiceKey{GuildID: v.GuildID, UserID: v.UserID}] = v
+	}
+	r.muVoice.Unlock()
+	if len(saved) > 0 {
+		r.log.Info("voice sessions restored", "count", len(saved))
+	}
+}
+
+// saveVoiceSessions сохраняет открытые сессии (seen_at = at) и ещё не забранные сохранённые.
+func (r *Registry) saveVoiceSessions(ctx context.Context, open map[voiceKey]voiceSession, at time.Time) error {
+	r.muVoice.Lock()
+	out := make([]SavedVoice, 0, len(open)+len(r.pendingVoice))
+	for k, vs := range open {
+		out = append(out, SavedVoice{GuildID: k.GuildID, UserID: k.UserID, ChannelID: vs.ChannelID, SeenAt: at})
+	}
+	for k, v := range r.pendingVoice {
+		if _, ok := open[k]; !ok {
+			out = append(out, v)
+		}
+	}
+	r.muVoice.Unlock()
+	return r.Sessions.Save(ctx, out)
+}
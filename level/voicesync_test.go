@@ -0,0 +1,108 @@
+package level
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"gosha_bot/discord/discordtest"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func init() { memberLookupDelay = 0 }
+
+func voiceStates(users map[string]string) []*discordgo.VoiceState {
+	var out []*discordgo.VoiceState
+	for u, ch := range users {
+		out = append(out, &discordgo.VoiceState{GuildID: guildID, UserID: u, ChannelID: ch})
+	}
+	return out
+}
+
+func TestGuildCreateSeedsVoiceSessions(t *testing.T) {
+	r := setupVoice(t)
+	r.onGuildCreate(nil, &discordgo.GuildCreate{Guild: &discordgo.Guild{
+		ID:          guildID,
+		VoiceStates: voiceStates(map[string]string{"a": "vc", "b": "vc", "c": "solo"}),
+	}})
+	if got := earning(r); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("earning %v, want [a b] (c is alone)", got)
+	}
+
+	// после обрыва: a ушёл, c пришёл к b
+	r.reconcileGuild(guildID, voiceStates(map[string]string{"b": "vc", "c": "vc"}), nil, false)
+	if got := earning(r); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("after resume: earning %v, want [b c]", got)
+	}
+
+	// новая сессия gateway без войс-состояний закрывает всё
+	r.onReady(nil, &discordgo.Ready{Guilds: []*discordgo.Guild{{ID: guildID}}})
+	if got := earning(r); got != nil {
+		t.Fatalf("after ready: earning %v, want none", got)
+	}
+	if len(r.voiceState) != 0 {
+		t.Fatalf("presence left: %v", r.voiceState)
+	}
+}
+
+func TestSeedSkipsBots(t *testing.T) {
+	r := setupVoice(t)
+	bot := &discordgo.VoiceState{GuildID: guildID, UserID: "music", ChannelID: "vc",
+		Member: &discordgo.Member{User: &discordgo.User{ID: "music", Bot: true}}}
+	r.reconcileGuild(guildID, append(voiceStates(map[string]string{"u1": "vc"}), bot), nil, true)
+	if got := earning(r); got != nil {
+		t.Fatalf("earning %v, want none (a bot is not company)", got)
+	}
+}
+
+func TestGuildCreateUsesEmbeddedMembers(t *testing.T) {
+	r := setupVoice(t)
+	fake := r.s.(*discordtest.Fake)
+	r.onGuildCreate(nil, &discordgo.GuildCreate{Guild: &discordgo.Guild{
+		ID:          guildID,
+		VoiceStates: voiceStates(map[string]string{"u1": "vc", "music": "vc"}),
+		Members: []*discordgo.Member{
+			{User: &discordgo.User{ID: "u1"}},
+			{User: &discordgo.User{ID: "music", Bot: true}},
+		},
+	}})
+	if n := fake.CallCount("GuildMember"); n != 0 {
+		t.Fatalf("GuildMember called %d times, want 0: members came with GuildCreate", n)
+	}
+	if got := earning(r); got != nil {
+		t.Fatalf("earning %v, want none (a bot is not company)", got)
+	}
+}
+
+func TestDowntimeCredit(t *testing.T) {
+	now := time.Now().UTC()
+	cases := []struct {
+		name  string
+		saved SavedVoice
+		want  time.Duration // насколько раньше now начинается сессия
+	}{
+		{"short downtime", SavedVoice{ChannelID: "vc", SeenAt: now.Add(-10 * time.Minute)}, 10 * time.Minute},
+		{"capped", SavedVoice{ChannelID: "vc", SeenAt: now.Add(-5 * time.Hour)}, time.Hour},
+		{"moved channel", SavedVoice{ChannelID: "other", SeenAt: now.Add(-10 * time.Minute)}, 0},
+	}
+	for _, tc := range cases {
+		r := setupVoice(t)
+		r.XP.VoiceDowntimeCredit = time.Hour
+		r.pendingVoice = map[voiceKey]SavedVoice{}
+		for _, u := range []string{"a", "b"} {
+			sv := tc.saved
+			sv.GuildID, sv.UserID = guildID, u
+			r.pendingVoice[voiceKey{guildID, u}] = sv
+		}
+		r.reconcileGuild(guildID, voiceStates(map[string]string{"a": "vc", "b": "vc"}), nil, true)
+
+		from := r.voiceJoin[voiceKey{guildID, "a"}].From
+		if got := now.Sub(from).Round(time.Minute); got != tc.want {
+			t.Errorf("%s: credited %v, want %v", tc.name, got, tc.want)
+		}
+		if len(r.pendingVoice) != 0 {
+			t.Errorf("%s: pending sessions left: %v", tc.name, r.pendingVoice)
+		}
+	}
+}
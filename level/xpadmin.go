@@ -0,0 +1,323 @@
+package level
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /xp add|remove|set|reset|reset-all — ручное изменение XP модераторами
+
+// кнопки подтверждения /xp reset-all: custom_id "xp:resetall:<id>:yes|no"
+const resetAllButton = "xp:resetall"
+
+// сколько ждём подтверждения /xp reset-all
+const resetAllTTL = time.Minute
+
+// наибольшее значение amount: с запасом до переполнения и по-прежнему «много»
+const maxXPAmount = 100_000_000
+
+type pendingReset struct {
+	GuildID string
+	ActorID string
+	Reason  string
+	Expires time.Time
+}
+
+// AttachLogger подключает админ-лог для /xp.
+func (r *Registry) AttachLogger(l *adminlog.Logger) { r.AdminLog = l }
+
+// SetAdminRoles — роли, которым можно менять XP без прав администратора (admin_role_ids).
+func (r *Registry) SetAdminRoles(ids []string) {
+	r.adminRoles = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		r.adminRoles[id] = true
+	}
+}
+
+// canManageXP — администратор или «Управление сервером», либо роль из admin_role_ids.
+// Права команды в Discord можно переопределить, поэтому проверяем и здесь.
+func (r *Registry) canManageXP(m *discordgo.Member) bool {
+	if m == nil {
+		return false
+	}
+	if m.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageServer) != 0 {
+		return true
+	}
+	for _, id := range m.Roles {
+		if r.adminRoles[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func xpAdminOptions() []*discordgo.ApplicationCommandOption {
+	minAmount, zero := float64(1), float64(0)
+	user := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Пользователь", Required: true}
+	amount := func(min *float64) *discordgo.ApplicationCommandOption {
+		return &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionInteger, Name: "amount", Description: "Сколько XP", Required: true, MinValue: min, MaxValue: maxXPAmount}
+	}
+	reason := &discordgo.ApplicationCommandOption{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина (попадёт в журнал и админ-лог)", Required: true, MaxLength: 200}
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommand, Name: "add", Description: "Начислить XP",
+			Options: []*discordgo.ApplicationCommandOption{user, amount(&minAmount), reason},
+		},
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommand, Name: "remove", Description: "Снять XP (не ниже нуля)",
+			Options: []*discordgo.ApplicationCommandOption{user, amount(&minAmount), reason},
+		},
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommand, Name: "set", Description: "Установить XP",
+			Options: []*discordgo.ApplicationCommandOption{user, amount(&zero), reason},
+		},
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommand, Name: "reset", Description: "Обнулить XP участника",
+			Options: []*discordgo.ApplicationCommandOption{user, reason},
+		},
+		{
+			Type: discordgo.ApplicationCommandOptionSubCommand, Name: "reset-all", Description: "Обнулить XP всего сервера (с подтверждением)",
+			Options: []*discordgo.ApplicationCommandOption{reason},
+		},
+	}
+}
+
+func (r *Registry) onXPAdd(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.onXPChange(s, ic, "add")
+}
+
+func (r *Registry) onXPRemove(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.onXPChange(s, ic, "remove")
+}
+
+func (r *Registry) onXPSet(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.onXPChange(s, ic, "set")
+}
+
+func (r *Registry) onXPReset(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	r.onXPChange(s, ic, "reset")
+}
+
+func (r *Registry) onXPChange(s *discordgo.Session, ic *discordgo.InteractionCreate, action string) {
+	lang := i18n.For(ic.Interaction)
+	if !r.canManageXP(ic.Member) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.no_perms"))
+		return
+	}
+	if r.Store == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.no_db"))
+		return
+	}
+	opts := commands.Options(ic)
+	target := resolvedUser(ic, opts["user"].UserValue(nil).ID)
+	if target.Bot {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.bot"))
+		return
+	}
+	reason := strings.TrimSpace(opts["reason"].StringValue())
+	var amount int64
+	if o, ok := opts["amount"]; ok {
+		amount = o.IntValue()
+	}
+
+	res, err := r.changeXPBy(ic.GuildID, target.ID, action, amount,
+		xpstore.Meta{Source: xpstore.SourceAdmin, ActorID: ic.Member.User.ID, Reason: reason})
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("change xp", "target", target.ID, "action", action, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("xp changed", "target", target.ID, "action", action,
+		"xp_before", res.XPBefore, "xp", res.XP, "level_before", res.LevelBefore, "level", res.Level)
+
+	if r.AdminLog != nil {
+		r.AdminLog.PostXPChange(ic.GuildID, target, ic.Member.User, adminlog.XPChange{
+			Action: action, XPBefore: res.XPBefore, XPAfter: res.XP, LevelBefore: res.LevelBefore, LevelAfter: res.Level,
+		}, reason)
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "xp.changed", target.ID, res.XPBefore, res.XP, res.LevelBefore, res.Level))
+}
+
+// changeXPBy применяет одно действие /xp и пересчитывает роли за уровень.
+func (r *Registry) changeXPBy(guildID, userID, action string, amount int64, m xpstore.Meta) (xpstore.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var res xpstore.Result
+	var err error
+	switch action {
+	case "add":
+		res, err = r.Store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: userID, Delta: amount, Meta: m})
+	case "remove":
+		res, err = r.Store.Add(ctx, xpstore.Change{GuildID: guildID, UserID: userID, Delta: -amount, Meta: m})
+	case "set":
+		res, err = r.Store.Set(ctx, guildID, userID, amount, m)
+	default:
+		res, err = r.Store.Set(ctx, guildID, userID, 0, m)
+	}
+	if err != nil {
+		return res, err
+	}
+	// участник мог уйти с сервера — XP всё равно изменён
+	if err := r.applyLevelRoles(guildID, userID, res.Level); err != nil {
+		r.log.Warn("apply level roles", "guild", guildID, "user", userID, "level", res.Level, "source", "admin", "err", err)
+	}
+	return res, nil
+}
+
+// resolvedUser — пользователь из опции команды (с именем, если Discord его прислал).
+func resolvedUser(ic *discordgo.InteractionCreate, id string) *discordgo.User {
+	if res := ic.ApplicationCommandData().Resolved; res != nil {
+		if u, ok := res.Users[id]; ok {
+			return u
+		}
+	}
+	return &discordgo.User{ID: id}
+}
+
+// ====== /xp reset-all ======
+
+func (r *Registry) onXPResetAll(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	if !r.canManageXP(ic.Member) {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.no_perms"))
+		return
+	}
+	if r.Store == nil {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xp.no_db"))
+		return
+	}
+	r.muReset.Lock()
+	if r.pendingResets == nil {
+		r.pendingResets = make(map[string]pendingReset)
+	}
+	now := time.Now()
+	for id, p := range r.pendingResets {
+		if now.After(p.Expires) {
+			delete(r.pendingResets, id)
+		}
+	}
+	r.pendingResets[ic.ID] = pendingReset{
+		GuildID: ic.GuildID, ActorID: ic.Member.User.ID,
+		Reason: strings.TrimSpace(commands.Options(ic)["reason"].StringValue()), Expires: now.Add(resetAllTTL),
+	}
+	r.muReset.Unlock()
+
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Content: i18n.T(lang, "xp.reset_all_confirm", now.Add(resetAllTTL).Unix()),
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+					discordgo.Button{Label: i18n.T(lang, "xp.reset_all_yes"), Style: discordgo.DangerButton, CustomID: resetAllButton + ":" + ic.ID + ":yes"},
+					discordgo.Button{Label: i18n.T(lang, "xp.reset_all_no"), Style: discordgo.SecondaryButton, CustomID: resetAllButton + ":" + ic.ID + ":no"},
+				}},
+			},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
+
+func (r *Registry) onXPResetAllButton(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	parts := strings.Split(ic.MessageComponentData().CustomID, ":")
+	if len(parts) != 4 || ic.Member == nil {
+		return
+	}
+	r.muReset.Lock()
+	p, ok := r.pendingResets[parts[2]]
+	delete(r.pendingResets, parts[2])
+	r.muReset.Unlock()
+
+	switch {
+	case !ok || time.Now().After(p.Expires) || p.GuildID != ic.GuildID || p.ActorID != ic.Member.User.ID:
+		r.updateMessage(s, ic, i18n.T(lang, "xp.reset_all_expired"))
+		return
+	case parts[3] != "yes":
+		r.updateMessage(s, ic, i18n.T(lang, "xp.reset_all_cancelled"))
+		return
+	case !r.canManageXP(ic.Member):
+		r.updateMessage(s, ic, i18n.T(lang, "xp.no_perms"))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	resets, err := r.Store.ResetAll(ctx, ic.GuildID, xpstore.Meta{Source: xpstore.SourceAdmin, ActorID: p.ActorID, Reason: p.Reason})
+	if err != nil {
+		logging.Interaction(r.log, ic).Error("reset all xp", "err", err)
+		r.updateMessage(s, ic, i18n.T(lang, "xp.failed", err))
+		return
+	}
+	var total int64
+	for _, rs := range resets {
+		total += rs.XPBefore
+	}
+	logging.Interaction(r.log, ic).Info("xp reset for the whole guild", "members", len(resets), "xp_before", total)
+	if r.AdminLog != nil {
+		r.AdminLog.PostXPResetAll(ic.GuildID, ic.Member.User, len(resets), total, p.Reason)
+	}
+	r.updateMessage(s, ic, i18n.T(lang, "xp.reset_all_done", len(resets), total))
+
+	// роли снимаем в фоне: на большом сервере это сотни запросов к Discord
+	r.background.Add(1)
+	go r.reapplyLevelRoles(ic.GuildID, resets)
+}
+
+// reapplyLevelRoles пересчитывает роли за уровень после массового изменения XP —
+// с той же паузой между запросами, что и /levelroles sync; Shutdown прерывает его.
+func (r *Registry) reapplyLevelRoles(guildID string, resets []xpstore.MemberResult) {
+	defer r.background.Done()
+	cfg := r.Guilds.Get(guildID)
+	if cfg == nil || len(cfg.Tiers) == 0 {
+		return
+	}
+	ctx, cancel := r.stopContext()
+	defer cancel()
+	var rep tierReport
+	for i, rs := range resets {
+		mem, err := discord.Member(r.s, guildID, rs.UserID)
+		if err != nil {
+			rep.Failed++
+			r.log.Debug("get member", "guild", guildID, "user", rs.UserID, "source", "admin", "err", err)
+			continue
+		}
+		add, remove := tierDiffFor(cfg, mem.Roles, rs.Level, true)
+		if len(add)+len(remove) == 0 {
+			continue
+		}
+		rep.Diffs = append(rep.Diffs, tierDiff{UserID: rs.UserID, Level: rs.Level, Add: add, Remove: remove})
+		if err := r.fixTiers(ctx, guildID, rs.UserID, add, remove, tierSyncPause, "admin", &rep); err != nil {
+			r.log.Warn("level roles reapply interrupted", "guild", guildID, "members", len(resets), "done", i, "err", err)
+			return
+		}
+	}
+	added, removed := rep.roles()
+	r.log.Info("level roles reapplied", "guild", guildID, "members", len(resets), "added", added, "removed", removed, "failed", rep.Failed)
+}
+
+// updateMessage заменяет сообщение с кнопками текстом и убирает кнопки.
+func (r *Registry) updateMessage(s *discordgo.Session, ic *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Components: []discordgo.MessageComponent{},
+		},
+	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("respond", "err", err)
+	}
+}
@@ -0,0 +1,70 @@
+package level
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"gosha_bot/levelcurve"
+	"gosha_bot/xpstore"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestChangeXPByAppliesRolesAndLedger(t *testing.T) {
+	r, fake := setup(t, "t1", "other")
+	store := xpstore.NewMemory(levelcurve.Default)
+	r.Store = store
+	meta := xpstore.Meta{Source: xpstore.SourceAdmin, ActorID: "mod", Reason: "ивент"}
+
+	res, err := r.changeXPBy(guildID, "u1", "set", levelcurve.Default.Threshold(50), meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.XPBefore != 0 || res.Level != 50 {
+		t.Fatalf("set: %+v", res)
+	}
+	if got := fake.MemberRoles(guildID, "u1"); !slices.Equal(got, []string{"other", "t50"}) {
+		t.Fatalf("roles after set = %v", got)
+	}
+
+	res, err = r.changeXPBy(guildID, "u1", "remove", levelcurve.Default.Threshold(50)+1000, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.XP != 0 || res.Level != 1 {
+		t.Fatalf("remove must clamp at zero: %+v", res)
+	}
+	if got := fake.MemberRoles(guildID, "u1"); !slices.Equal(got, []string{"other", "t1"}) {
+		t.Fatalf("roles after remove = %v", got)
+	}
+
+	entries, total, err := store.History(context.Background(), guildID, "u1", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || entries[0].Source != xpstore.SourceAdmin || entries[0].ActorID != "mod" || entries[0].Reason != "ивент" {
+		t.Fatalf("ledger = %d entries, newest %+v", total, entries[0])
+	}
+}
+
+func TestCanManageXP(t *testing.T) {
+	r := &Registry{}
+	r.SetAdminRoles([]string{"mods"})
+	cases := []struct {
+		name string
+		m    *discordgo.Member
+		want bool
+	}{
+		{"administrator", &discordgo.Member{Permissions: discordgo.PermissionAdministrator}, true},
+		{"manage server", &discordgo.Member{Permissions: discordgo.PermissionManageServer}, true},
+		{"admin role", &discordgo.Member{Roles: []string{"x", "mods"}}, true},
+		{"member", &discordgo.Member{Roles: []string{"x"}, Permissions: discordgo.PermissionSendMessages}, false},
+		{"no member", nil, false},
+	}
+	for _, c := range cases {
+		if got := r.canManageXP(c.m); got != c.want {
+			t.Errorf("%s: canManageXP = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package level
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gosha_bot/commands"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// /xprole set|clear|list|stacking — множители XP за роли (бустеры, спонсоры)
+func (r *Registry) roleMultiplierCommand() *discordgo.ApplicationCommand {
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	dm := false
+	minMult, maxMult := 0.0, 10.0
+	return &discordgo.ApplicationCommand{
+		Name:                     "xprole",
+		Description:              "Множители XP за роли",
+		DefaultMemberPermissions: &adminPerm,
+		DMPermission:             &dm,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "set", Description: "Задать множитель XP для роли",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Роль", Required: true},
+					{Type: discordgo.ApplicationCommandOptionNumber, Name: "multiplier", Description: "Множитель, например 1.5", Required: true, MinValue: &minMult, MaxValue: maxMult},
+				},
+			},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "clear", Description: "Убрать множитель роли",
+				Options: []*discordgo.ApplicationCommandOption{
+					{Type: discordgo.ApplicationCommandOptionRole, Name: "role", Description: "Роль", Required: true},
+				},
+			},
+			{Type: discordgo.ApplicationCommandOptionSubCommand, Name: "list", Description: "Показать множители ролей"},
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand, Name: "stacking", Description: "Как складываются несколько ролей",
+				Options: []*discordgo.ApplicationCommandOption{
+					{
+						Type: discordgo.ApplicationCommandOptionString, Name: "policy", Description: "Политика", Required: true,
+						Choices: []*discordgo.ApplicationCommandOptionChoice{
+							{Name: "max — сильнейшая роль", Value: guildcfg.StackMax},
+							{Name: "multiply — перемножить", Value: guildcfg.StackMultiply},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (r *Registry) onRoleMultiplierSet(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	opts := commands.Options(ic)
+	roleID := opts["role"].RoleValue(nil, ic.GuildID).ID
+	m := opts["multiplier"].FloatValue()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetRoleMultiplier(ctx, ic.GuildID, roleID, m); err != nil {
+		logging.Interaction(r.log, ic).Error("set role multiplier", "role", roleID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("role multiplier set", "role", roleID, "multiplier", m)
+	r.respondEphemeral(s, ic, i18n.T(lang, "xprole.set", roleID, formatMultiplier(m)))
+}
+
+func (r *Registry) onRoleMultiplierClear(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	roleID := commands.Options(ic)["role"].RoleValue(nil, ic.GuildID).ID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ok, err := r.Guilds.ClearRoleMultiplier(ctx, ic.GuildID, roleID)
+	switch {
+	case err != nil:
+		logging.Interaction(r.log, ic).Error("clear role multiplier", "role", roleID, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.failed", err))
+	case !ok:
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.not_set", roleID))
+	default:
+		logging.Interaction(r.log, ic).Info("role multiplier cleared", "role", roleID)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.cleared", roleID))
+	}
+}
+
+func (r *Registry) onRoleMultiplierList(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	g := r.Guilds.Get(ic.GuildID)
+	if g == nil || len(g.RoleMultipliers) == 0 {
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.empty"))
+		return
+	}
+	ids := make([]string, 0, len(g.RoleMultipliers))
+	for id := range g.RoleMultipliers {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var b strings.Builder
+	for _, id := range ids {
+		fmt.Fprintf(&b, "<@&%s> — ×%s\n", id, formatMultiplier(g.RoleMultipliers[id]))
+	}
+	r.respondEphemeral(s, ic, i18n.T(lang, "xprole.list", stackingName(lang, g.RoleStacking), b.String()))
+}
+
+func (r *Registry) onRoleStacking(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
+	policy := commands.Options(ic)["policy"].StringValue()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Guilds.SetRoleStacking(ctx, ic.GuildID, policy); err != nil {
+		logging.Interaction(r.log, ic).Error("set role stacking", "policy", policy, "err", err)
+		r.respondEphemeral(s, ic, i18n.T(lang, "xprole.failed", err))
+		return
+	}
+	logging.Interaction(r.log, ic).Info("role stacking set", "policy", policy)
+	r.respondEphemeral(s, ic, i18n.T(lang, "xprole.stacking_set", stackingName(lang, policy)))
+}
+
+func stackingName(lang i18n.Lang, policy string) string {
+	if policy == guildcfg.StackMultiply {
+		return i18n.T(lang, "xprole.stacking_multiply")
+	}
+	return i18n.T(lang, "xprole.stacking_max")
+}
+
+// effectiveMultiplier — множитель участника вне зависимости от канала: роли × буст на весь сервер.
+// Для /level; множители каналов и канальные бусты сюда не входят.
+func (r *Registry) effectiveMultiplier(guildID, userID string) (total, roles, boost float64) {
+	roles = r.roleMultiplier(guildID, r.memberRoles(guildID, userID))
+	boost = r.boostMultiplier(guildID, nil, time.Now())
+	return roles * boost, roles, boost
+}
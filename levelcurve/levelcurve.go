@@ -0,0 +1,150 @@
+package levelcurve
+
+import (
+	"fmt"
+	"math"
+
+	"gosha_bot/config"
+)
+
+// Curve — кривая уровней: сколько XP нужно набрать, чтобы оказаться на уровне L.
+// Уровни начинаются с 1, Threshold(1) всегда 0.
+type Curve interface {
+	Threshold(level int) int64
+	MaxLevel() int  // 0 — без потолка
+	String() string // для логов и /levelcurve show
+}
+
+// Default — кривая, на которой бот работал исходно: threshold(L) = 10*L².
+var Default Curve = Quadratic{Factor: 10}
+
+// New — кривая из секции levels конфига.
+func New(cfg config.Levels) (Curve, error) {
+	switch cfg.Curve {
+	case "", config.CurveQuadratic:
+		f := cfg.Factor
+		if f == 0 {
+			f = 10
+		}
+		return Quadratic{Factor: f}, nil
+	case config.CurveExponential:
+		return Exponential{Base: cfg.Base, Growth: cfg.Growth}, nil
+	case config.CurveMEE6:
+		return MEE6{}, nil
+	case config.CurveTable:
+		return Table(cfg.Table), nil
+	}
+	return nil, fmt.Errorf("levels.curve: %q is not supported", cfg.Curve)
+}
+
+// потолок поиска уровня: дальше XP всё равно упирается в int64
+const searchLimit = 1 << 20
+
+// Level — уровень для xp: максимальный L, для которого Threshold(L) <= xp.
+func Level(c Curve, xp int64) int {
+	if xp <= 0 {
+		return 1
+	}
+	max := c.MaxLevel()
+	if max <= 0 || max > searchLimit {
+		max = searchLimit
+	}
+	// галопом до первого уровня, которого xp не хватает, затем бинарный поиск
+	lo, hi := 1, 2
+	for hi <= max && c.Threshold(hi) <= xp {
+		lo, hi = hi, hi*2
+	}
+	if hi > max+1 {
+		hi = max + 1
+	}
+	for hi-lo > 1 {
+		mid := lo + (hi-lo)/2
+		if c.Threshold(mid) <= xp {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// Progress — уровень и границы текущего уровня [cur, next).
+// На последнем уровне кривой next == cur.
+func Progress(c Curve, xp int64) (level int, cur, next int64) {
+	level = Level(c, xp)
+	cur = c.Threshold(level)
+	if max := c.MaxLevel(); max > 0 && level >= max {
+		return level, cur, cur
+	}
+	return level, cur, c.Threshold(level + 1)
+}
+
+// ---- кривые ----
+
+// Quadratic: threshold(L) = Factor*L² (уровень 1 — с нуля).
+type Quadratic struct{ Factor float64 }
+
+func (q Quadratic) Threshold(level int) int64 {
+	if level <= 1 {
+		return 0
+	}
+	return saturate(q.Factor * float64(level) * float64(level))
+}
+func (q Quadratic) MaxLevel() int  { return 0 }
+func (q Quadratic) String() string { return fmt.Sprintf("quadratic(%g·L²)", q.Factor) }
+
+// Exponential: переход с уровня k на k+1 стоит Base*Growth^(k-1),
+// threshold(L) — сумма переходов до L.
+type Exponential struct{ Base, Growth float64 }
+
+func (e Exponential) Threshold(level int) int64 {
+	if level <= 1 {
+		return 0
+	}
+	n := float64(level - 1)
+	if e.Growth == 1 {
+		return saturate(e.Base * n)
+	}
+	return saturate(e.Base * (math.Pow(e.Growth, n) - 1) / (e.Growth - 1))
+}
+func (e Exponential) MaxLevel() int { return 0 }
+func (e Exponential) String() string {
+	return fmt.Sprintf("exponential(base %g, ×%g)", e.Base, e.Growth)
+}
+
+// MEE6: переход с уровня n на n+1 (в счёте MEE6, с нуля) стоит 5n² + 50n + 100.
+// Уровень 0 MEE6 — наш уровень 1.
+type MEE6 struct{}
+
+func (MEE6) Threshold(level int) int64 {
+	if level <= 1 {
+		return 0
+	}
+	// Σ_{n=0}^{m-1} (5n² + 50n + 100), m = level-1
+	m := float64(level - 1)
+	return saturate(5*(m-1)*m*(2*m-1)/6 + 50*(m-1)*m/2 + 100*m)
+}
+func (MEE6) MaxLevel() int  { return 0 }
+func (MEE6) String() string { return "mee6(5n²+50n+100)" }
+
+// Table: явные пороги, Table[i] — XP для уровня i+2. Выше таблицы уровней нет.
+type Table []int64
+
+func (t Table) Threshold(level int) int64 {
+	switch {
+	case level <= 1:
+		return 0
+	case level-2 < len(t):
+		return t[level-2]
+	}
+	return math.MaxInt64
+}
+func (t Table) MaxLevel() int  { return len(t) + 1 }
+func (t Table) String() string { return fmt.Sprintf("table(%d levels)", len(t)+1) }
+
+func saturate(f float64) int64 {
+	if f >= math.MaxInt64 || math.IsInf(f, 1) || math.IsNaN(f) {
+		return math.MaxInt64
+	}
+	return int64(math.Ceil(f - 1e-9))
+}
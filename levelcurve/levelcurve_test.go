@@ -0,0 +1,99 @@
+package levelcurve
+
+import (
+	"math"
+	"testing"
+
+	"gosha_bot/config"
+)
+
+// исходная формула бота: L = floor(sqrt(xp/10)), минимум 1
+func legacyLevel(xp int64) int {
+	l := int(math.Floor(math.Sqrt(float64(xp) / 10.0)))
+	if l < 1 {
+		l = 1
+	}
+	return l
+}
+
+func TestDefaultMatchesLegacyFormula(t *testing.T) {
+	for xp := int64(0); xp <= 200_000; xp++ {
+		if got, want := Level(Default, xp), legacyLevel(xp); got != want {
+			t.Fatalf("Level(%d) = %d, want %d", xp, got, want)
+		}
+	}
+}
+
+func TestThresholdBoundaries(t *testing.T) {
+	curves := []Curve{
+		Quadratic{Factor: 3},
+		Exponential{Base: 100, Growth: 1.5},
+		Exponential{Base: 50, Growth: 1},
+		MEE6{},
+		Table{10, 50, 200},
+	}
+	for _, c := range curves {
+		for l := 2; l <= 40; l++ {
+			if max := c.MaxLevel(); max > 0 && l > max {
+				break
+			}
+			th := c.Threshold(l)
+			if th <= c.Threshold(l-1) {
+				t.Fatalf("%s: threshold(%d)=%d is not above threshold(%d)", c, l, th, l-1)
+			}
+			if got := Level(c, th); got != l {
+				t.Errorf("%s: Level(%d) = %d, want %d", c, th, got, l)
+			}
+			if got := Level(c, th-1); got != l-1 {
+				t.Errorf("%s: Level(%d) = %d, want %d", c, th-1, got, l-1)
+			}
+		}
+	}
+}
+
+func TestMEE6Thresholds(t *testing.T) {
+	// MEE6: 0→1 стоит 100, 1→2 — 155, 2→3 — 220
+	for l, want := range map[int]int64{1: 0, 2: 100, 3: 255, 4: 475} {
+		if got := (MEE6{}).Threshold(l); got != want {
+			t.Errorf("threshold(%d) = %d, want %d", l, got, want)
+		}
+	}
+}
+
+func TestTableCapsLevel(t *testing.T) {
+	c := Table{100, 300}
+	if got := Level(c, math.MaxInt64); got != 3 {
+		t.Fatalf("Level(max) = %d, want 3", got)
+	}
+	lvl, cur, next := Progress(c, 10_000)
+	if lvl != 3 || cur != 300 || next != cur {
+		t.Errorf("Progress = %d, %d, %d; want 3, 300, 300", lvl, cur, next)
+	}
+	lvl, cur, next = Progress(c, 150)
+	if lvl != 2 || cur != 100 || next != 300 {
+		t.Errorf("Progress = %d, %d, %d; want 2, 100, 300", lvl, cur, next)
+	}
+}
+
+func TestHugeXPDoesNotOverflow(t *testing.T) {
+	for _, c := range []Curve{Default, Exponential{Base: 10, Growth: 2}, MEE6{}} {
+		l := Level(c, math.MaxInt64)
+		if l < 2 || c.Threshold(l) > math.MaxInt64 || c.Threshold(l) < 0 {
+			t.Errorf("%s: Level(MaxInt64) = %d", c, l)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := New(config.Levels{})
+	if err != nil || c != Default {
+		t.Fatalf("empty config = %v, %v; want default", c, err)
+	}
+	if _, err := New(config.Levels{Curve: "cubic"}); err == nil {
+		t.Error("unknown curve accepted")
+	}
+	c, err = New(config.Levels{Curve: config.CurveTable, Table: []int64{5, 9}})
+	if err != nil || c.MaxLevel() != 3 {
+		t.Errorf("table = %v, %v", c, err)
+	}
+}
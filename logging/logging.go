@@ -0,0 +1,147 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+
+	"gosha_bot/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// New — корневой логгер по секции log конфига. secrets — строки, которые нельзя
+// печатать ни в каком виде (токен, DSN): они вырезаются из сообщений и атрибутов.
+// Заодно перенаправляет стандартный log (discordgo, pgx, сторонний код) в этот же логгер.
+func New(w io.Writer, cfg config.Log, secrets ...string) (*slog.Logger, error) {
+	base, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, fmt.Errorf("log.level: %w", err)
+	}
+	modules := make(map[string]slog.Level, len(cfg.Modules))
+	for name, lv := range cfg.Modules {
+		l, err := ParseLevel(lv)
+		if err != nil {
+			return nil, fmt.Errorf("log.modules.%s: %w", name, err)
+		}
+		modules[name] = l
+	}
+
+	red := newRedactor(secrets)
+	opts := &slog.HandlerOptions{
+		Level:       slog.LevelDebug, // фильтрует levelHandler, здесь пропускаем всё
+		ReplaceAttr: red.replaceAttr,
+	}
+	var inner slog.Handler
+	switch cfg.Format {
+	case "", "text":
+		inner = slog.NewTextHandler(w, opts)
+	case "json":
+		inner = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("log.format: %q is not supported (text, json)", cfg.Format)
+	}
+
+	l := slog.New(&levelHandler{inner: inner, level: base, base: base, modules: modules})
+	log.SetFlags(0)
+	log.SetOutput(stdWriter{l: Module(l, "std")})
+	return l, nil
+}
+
+// ParseLevel — "debug" | "info" | "warn" | "error"; пусто — info.
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if strings.TrimSpace(s) == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("%q is not a level (debug, info, warn, error)", s)
+	}
+	return l, nil
+}
+
+// Module — логгер модуля: атрибут module=name и уровень из log.modules.<name>
+// (если он там задан, иначе общий log.level).
+func Module(l *slog.Logger, name string) *slog.Logger {
+	if h, ok := l.Handler().(*levelHandler); ok {
+		return slog.New(h.forModule(name)).With("module", name)
+	}
+	return l.With("module", name)
+}
+
+// Interaction — логгер с полями конкретной интеракции: сервер, пользователь,
+// команда (или custom_id компонента) и ID интеракции.
+func Interaction(l *slog.Logger, ic *discordgo.InteractionCreate) *slog.Logger {
+	if ic == nil || ic.Interaction == nil {
+		return l
+	}
+	attrs := []any{slog.String("interaction", ic.ID)}
+	if ic.GuildID != "" {
+		attrs = append(attrs, slog.String("guild", ic.GuildID))
+	}
+	switch {
+	case ic.Member != nil && ic.Member.User != nil:
+		attrs = append(attrs, slog.String("user", ic.Member.User.ID))
+	case ic.User != nil:
+		attrs = append(attrs, slog.String("user", ic.User.ID))
+	}
+	switch ic.Type {
+	case discordgo.InteractionApplicationCommand, discordgo.InteractionApplicationCommandAutocomplete:
+		attrs = append(attrs, slog.String("command", ic.ApplicationCommandData().Name))
+	case discordgo.InteractionMessageComponent:
+		attrs = append(attrs, slog.String("component", ic.MessageComponentData().CustomID))
+	case discordgo.InteractionModalSubmit:
+		attrs = append(attrs, slog.String("component", ic.ModalSubmitData().CustomID))
+	}
+	return l.With(attrs...)
+}
+
+// Discard — логгер в никуда (тесты, модули без переданного логгера).
+func Discard() *slog.Logger { return slog.New(slog.DiscardHandler) }
+
+// ---- уровни по модулям ----
+
+type levelHandler struct {
+	inner   slog.Handler
+	level   slog.Level // уровень этого логгера (общий или модуля)
+	base    slog.Level
+	modules map[string]slog.Level
+}
+
+func (h *levelHandler) forModule(name string) *levelHandler {
+	c := *h
+	c.level = h.base
+	if l, ok := h.modules[name]; ok {
+		c.level = l
+	}
+	return &c
+}
+
+func (h *levelHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level }
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.inner.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithAttrs(attrs)
+	return &c
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	c := *h
+	c.inner = h.inner.WithGroup(name)
+	return &c
+}
+
+// stdWriter — приёмник для стандартного log: каждая строка — запись уровня info.
+type stdWriter struct{ l *slog.Logger }
+
+func (w stdWriter) Write(p []byte) (int, error) {
+	w.l.Info(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
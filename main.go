@@ -3,12 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
-	"strings"
-	"sync"
+	"os/signal"
+	"syscall"
 	"time"
-	"math"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -16,42 +16,46 @@ import (
 
 	"gosha_bot/adminlog"
 	"gosha_bot/clear"
+	"gosha_bot/commands"
+	"gosha_bot/config"
+	"gosha_bot/give"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
 	"gosha_bot/level"
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
+	"gosha_bot/metrics"
+	"gosha_bot/migrate"
 	"gosha_bot/mute"
-	"gosha_bot/selfrole"
 	"gosha_bot/remove"
+	"gosha_bot/selfrole"
 	"gosha_bot/top"
-	"gosha_bot/give"
 )
 
 func main() {
-	var registerOnce sync.Once
-
 	_ = godotenv.Load(".env")
 
-	must := func(k string) string {
-		v := os.Getenv(k)
-		if v == "" {
-			log.Fatal(k, " is empty")
-		}
-		return v
+	// конфиг: CONFIG_FILE (обязателен, если задан) или ./config.yaml (если есть); секреты — из env
+	cfgPath := os.Getenv("CONFIG_FILE")
+	cfgRequired := cfgPath != ""
+	if !cfgRequired {
+		cfgPath = "config.yaml"
 	}
+	cfg, err := config.Load(cfgPath, cfgRequired)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config:", err)
+		os.Exit(1)
+	}
+	token := cfg.Discord.Token
 
-	// ENV
-	token := must("DISCORD_TOKEN")
-	guildID := must("GUILD_ID")
-	if guildID == "" {
-    log.Fatal("GUILD_ID is empty")
-}
-	muteRoleID := must("MUTE_ROLE_ID")
-	logChID := os.Getenv("ADMIN_LOG_CHANNEL_ID")
-	keepCatID := os.Getenv("KEEP_CATEGORY_ID")
-
-	fmt.Println("[env dbg] DISCORD_TOKEN length:", len(token))
-	log.Println("[dbg] cwd:", func() string { d, _ := os.Getwd(); return d }())
-	for _, k := range []string{"GUILD_ID", "WELCOME_CHANNEL_ID", "SELF_ROLE_ID", "MUTE_ROLE_ID", "ADMIN_LOG_CHANNEL_ID", "KEEP_CATEGORY_ID"} {
-		log.Printf("[env dbg] %s=%q", k, os.Getenv(k))
+	// журнал: токен и DSN вырезаются из любых сообщений, включая чужие (discordgo, pgx)
+	logger, err := logging.New(os.Stderr, cfg.Log, token, cfg.Database.DSN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "log:", err)
+		os.Exit(1)
 	}
+	mainLog := logging.Module(logger, "main")
+	mainLog.Info("config loaded", "file", cfgPath, "guilds", len(cfg.Guilds), "db", cfg.Database.DSN != "")
 
 	// Session + intents
 	intents := discordgo.IntentsGuilds |
@@ -62,275 +66,192 @@ func main() {
 
 	s, err := discordgo.New("Bot " + token)
 	if err != nil {
-		log.Fatal("discord session:", err)
+		fatal(mainLog, "discord session", err)
 	}
 	s.Identify.Intents = intents
-	log.Printf("[dbg] intents mask: %d", s.Identify.Intents)
-
-	// init selfrole + clear
-	if err := selfrole.Init(s); err != nil {
-		log.Fatal("selfrole init:", err)
-	}
-	clear.AddHandler(s)
-
-	// admin log
-	adm := adminlog.Init(s, guildID, logChID)
+	s.Client.Transport = metrics.Transport(s.Client.Transport) // считаем ошибки REST
+	mainLog.Debug("gateway intents", "mask", int(s.Identify.Intents))
 
 	// DB
 	var pool *pgxpool.Pool
-	if dsn := os.Getenv("POSTGRES_DSN"); dsn != "" {
-		pool = mustDBPool(dsn)
-		defer pool.Close()
+	if dsn := cfg.Database.DSN; dsn != "" {
+		pool = mustDBPool(dsn, logger)
+		metrics.WatchPool(pool)
 	} else {
-		log.Println("[warn] POSTGRES_DSN is empty — DB features limited")
+		mainLog.Warn("POSTGRES_DSN is empty — DB features limited")
 	}
-	
 
-	// mute
-	mr, err := mute.Register(s, guildID, keepCatID, logChID, muteRoleID, pool)
-	if err != nil {
-		log.Fatal("mute register:", err)
+	// метрики (опционально)
+	var metricsSrv *http.Server
+	if cfg.Metrics.Listen != "" {
+		metricsSrv, err = metrics.Serve(cfg.Metrics.Listen, logging.Module(logger, "metrics"))
+		if err != nil {
+			fatal(mainLog, "metrics", err)
+		}
 	}
-	mr.AttachLogger(adm)
-	mr.SetRetentionDays(3)
 
-	// level
-	_, err = level.Register(s, guildID, pool, level.RolesConfig{
-		RoleL1to24:   "1401993276730380531",
-		RoleL25to49:  "1401993388345262133",
-		RoleL50to74:  "1401993503420190760",
-		RoleL75to99:  "1401993577495527534",
-		RoleL100Plus: "1401993637839245434",
-	}, "636654459682029578") // AFK
-	if err != nil {
-		log.Fatal("level.Register:", err)
+	// настройки серверов (guild_settings); без БД живут только в памяти
+	guilds := guildcfg.NewStore(pool)
+	applyGuildConfig(guilds, cfg.Guilds, mainLog)
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := guilds.LoadAll(ctx); err != nil {
+			fatal(mainLog, "guild settings", err)
+		}
+		cancel()
 	}
 
-	wireRemove(s, guildID, pool, adm)
-	wireGive(s, guildID, pool, adm)
-
+	// язык ответов: guild_settings.locale перекрывает локаль пользователя
+	i18n.UseGuildOverride(guilds.Locale)
 
-	// --- /level (EMBED) ---
-	s.AddHandler(func(s *discordgo.Session, ic *discordgo.InteractionCreate) {
-		if ic.Type != discordgo.InteractionApplicationCommand {
-			return
-		}
-		data := ic.ApplicationCommandData()
-		if data.Name != "level" {
-			return
-		}
+	// одна кривая уровней на level, give и top
+	curve, err := levelcurve.New(cfg.Levels)
+	if err != nil {
+		fatal(mainLog, "level curve", err)
+	}
+	mainLog.Info("level curve", "curve", curve.String())
 
-		// чей уровень
-		targetID := ic.Member.User.ID
-		targetTag := ic.Member.User.Username
-		if len(data.Options) > 0 && data.Options[0].Type == discordgo.ApplicationCommandOptionUser {
-			if u := data.Options[0].UserValue(s); u != nil {
-				targetID = u.ID
-				targetTag = u.Username
-			}
-		}
+	// все slash-команды модулей объявляются здесь, на серверы уходят через router.Sync
+	router := commands.New(logging.Module(logger, "commands"))
 
-		// из БД
-		var xp int64 = 0
-		var lvl int = 1
-		if pool != nil {
-			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-			defer cancel()
-			_ = pool.QueryRow(ctx,
-				`SELECT xp, level FROM users_levels WHERE guild_id=$1 AND user_id=$2`,
-				guildID, targetID,
-			).Scan(&xp, &lvl)
+	// init selfrole + clear
+	if cfg.Modules.SelfRole {
+		if err := selfrole.Init(s, router, guilds, logging.Module(logger, "selfrole")); err != nil {
+			fatal(mainLog, "selfrole init", err)
 		}
+	}
+	if cfg.Modules.Clear {
+		clear.Register(router, logging.Module(logger, "clear"))
+	}
 
-				// пороги (10*L^2)
-		prev := int64(10 * lvl * lvl)
-		next := int64(10 * (lvl+1) * (lvl+1))
+	// admin log
+	var adm *adminlog.Logger
+	if cfg.Modules.AdminLog {
+		adm = adminlog.Init(s, guilds, logging.Module(logger, "adminlog"))
+	}
 
-		// клампим xp в [prev, next] и защищаемся от деления на 0
-		if next <= prev {
-			next = prev + 1
-		}
-		if xp < prev {
-			xp = prev
-		}
-		if xp > next {
-			xp = next
+	// mute
+	var mr *mute.Registry
+	if cfg.Modules.Mute {
+		mr, err = mute.Register(s, router, guilds, pool, logging.Module(logger, "mute"))
+		if err != nil {
+			fatal(mainLog, "mute register", err)
 		}
+		mr.AttachLogger(adm)
+		mr.SetRetentionDays(cfg.Mute.RetentionDays)
+	}
 
-		need := next - xp
-		prog := float64(xp-prev) / float64(next-prev) // 0..1
-
-		// прогресс-бар на 10 клеток c округлением (а не усечением)
-		const cells = 10
-		filled := int(math.Round(prog * float64(cells)))
-		if filled < 0 {
-			filled = 0
+	// level
+	var lr *level.Registry
+	if cfg.Modules.Level {
+		lr, err = level.Register(s, router, guilds, pool, cfg.XP, curve, logging.Module(logger, "level"))
+		if err != nil {
+			fatal(mainLog, "level register", err)
 		}
-		if filled > cells {
-			filled = cells
+		lr.AttachLogger(adm)
+		lr.SetAdminRoles(cfg.AdminRoleIDs)
+	}
+
+	if cfg.Modules.Remove {
+		wireRemove(s, router, guilds, adm, cfg, logger)
+	}
+	if cfg.Modules.Give {
+		gr := wireGive(s, router, guilds, pool, adm, cfg, curve, logger)
+		if lr != nil {
+			gr.SetLevelRoles(lr.ApplyLevelRoles)
 		}
+	}
+	if cfg.Modules.Top {
+		top.Register(router, pool, curve, logging.Module(logger, "top"))
+	}
 
-		// используем эмодзи одинаковой ширины
-		var bar strings.Builder
-		for i := 0; i < cells; i++ {
-			if i < filled {
-				bar.WriteString("🟩")
-			} else {
-				bar.WriteString("⬜")
-			}
+	// один диспетчер на все интеракции
+	router.Attach(s)
+
+	// На каждом сервере: настройки + полный набор slash-команд (лишние удаляются)
+	s.AddHandler(func(s *discordgo.Session, gc *discordgo.GuildCreate) {
+		i18n.RememberGuild(gc.ID, discordgo.Locale(gc.PreferredLocale))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := guilds.Ensure(ctx, gc.ID)
+		cancel()
+		if err != nil {
+			mainLog.Error("ensure guild settings", "guild", gc.ID, "err", err)
+		}
+		if err := router.Sync(s, gc.ID); err != nil {
+			mainLog.Error("sync commands", "guild", gc.ID, "err", err)
 		}
-		percent := int(math.Round(prog * 100))
+	})
 
+	// запуск
+	if err := s.Open(); err != nil {
+		fatal(mainLog, "open gateway", err)
+	}
 
+	mainLog.Info("bot is up")
 
-		// аватар
-		thumb := ""
-		if u, _ := s.User(targetID); u != nil {
-			thumb = discordgo.EndpointUserAvatar(u.ID, u.Avatar)
-		}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop() // повторный Ctrl+C — немедленный выход
 
-		// тир-роль
-		tier := func(level int) string {
-			switch {
-			case level >= 100:
-				return "<@&1401993637839245434>"
-			case level >= 75:
-				return "<@&1401993577495527534>"
-			case level >= 50:
-				return "<@&1401993503420190760>"
-			case level >= 25:
-				return "<@&1401993388345262133>"
-			default:
-				return "<@&1401993276730380531>"
-			}
-		}
+	mainLog.Info("shutting down", "timeout", cfg.ShutdownTimeout)
+	shutdown(mainLog, cfg.ShutdownTimeout, router, lr, mr, s, metricsSrv, pool)
+	mainLog.Info("bot is down")
+}
 
-		embed := &discordgo.MessageEmbed{
-			Title:       "Уровень и опыт",
-			Description: fmt.Sprintf("**%s**", targetTag),
-			Color:       0x5865F2,
-			Thumbnail:   &discordgo.MessageEmbedThumbnail{URL: thumb},
-			Fields: []*discordgo.MessageEmbedField{
-				{Name: "Уровень", Value: fmt.Sprintf("%d", lvl), Inline: true},
-				{Name: "XP", Value: fmt.Sprintf("%d", xp), Inline: true},
-				{Name: "Тир-роль", Value: tier(lvl), Inline: true},
-			    {Name: "Прогресс", Value: fmt.Sprintf("%s  %d%%", bar.String(), percent), Inline: false},
-				{Name: "До следующего", Value: fmt.Sprintf("%d XP → lvl %d", need, lvl+1), Inline: true},
-			},
-			Footer: &discordgo.MessageEmbedFooter{
-				Text: "Войс: 100 XP/час",
-			},
-		}
+// fatal — ошибка старта: пишем в журнал и выходим
+func fatal(log *slog.Logger, msg string, err error) {
+	log.Error(msg, "err", err)
+	os.Exit(1)
+}
 
-		_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Embeds: []*discordgo.MessageEmbed{embed},
-			},
-		})
-	})
+// shutdown останавливает всё по порядку, укладываясь в общий дедлайн:
+// интеракции → войс-XP → муты → gateway → метрики → пул БД.
+// Ошибка шага логируется, остальные шаги всё равно выполняются.
+func shutdown(log *slog.Logger, timeout time.Duration, router *commands.Router, lr *level.Registry, mr *mute.Registry,
+	s *discordgo.Session, metricsSrv *http.Server, pool *pgxpool.Pool) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	// --- /welcome (служебная) ---
-	s.AddHandler(func(s *discordgo.Session, ic *discordgo.InteractionCreate) {
-		if ic.Type != discordgo.InteractionApplicationCommand {
-			return
-		}
-		if ic.ApplicationCommandData().Name != "welcome" {
-			return
+	step := func(name string, fn func(context.Context) error) {
+		if err := fn(ctx); err != nil {
+			log.Error("shutdown step failed", "step", name, "err", err)
 		}
-		_ = selfrole.SendWelcome(s, ic.Member.User.ID)
-		_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Отправил сообщение в welcome-канал.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
-	})
-
-	// Регистрация slash-команд после Ready
-	s.AddHandler(func(s *discordgo.Session, r *discordgo.Ready) {
-		registerOnce.Do(func() {
-			log.Println("[cmd] registering slash commands…")
-			appID := r.User.ID
-
-			cmds := []*discordgo.ApplicationCommand{
-				{
-					Name:        "mute",
-					Description: "Выдать мут пользователю на N минут",
-					Options: []*discordgo.ApplicationCommandOption{
-						{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Кому выдать мут", Required: true},
-						{Type: discordgo.ApplicationCommandOptionInteger, Name: "minutes", Description: "На сколько минут", Required: true, MinValue: func() *float64 { v := 1.0; return &v }()},
-						{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина", Required: false},
-					},
-				},
-				{
-					Name:        "unmute",
-					Description: "Снять мут с пользователя",
-					Options: []*discordgo.ApplicationCommandOption{
-						{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "С кого снять мут", Required: true},
-						{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина", Required: false},
-					},
-				},
-				{
-					Name:        "clear",
-					Description: "Удалить последние N сообщений в этом канале",
-					Options: []*discordgo.ApplicationCommandOption{
-						func() *discordgo.ApplicationCommandOption {
-							opt := &discordgo.ApplicationCommandOption{
-								Type:        discordgo.ApplicationCommandOptionInteger,
-								Name:        "count",
-								Description: "Сколько (1–100)",
-								Required:    true,
-							}
-							clear.SetMinMaxRange(opt, 1, 100)
-							return opt
-						}(),
-					},
-				},
-				{
-					Name:        "level",
-					Description: "Показать уровень и XP (свой или другого пользователя)",
-					Options: []*discordgo.ApplicationCommandOption{
-						{
-							Type:        discordgo.ApplicationCommandOptionUser,
-							Name:        "user",
-							Description: "Пользователь (по умолчанию — ты)",
-							Required:    false,
-						},
-					},
-				},
-				{
-                           Name:        "top",
-                           Description: "Показать топ-10 пользователей по XP",
-                },
-			}
+	}
 
-			for _, c := range cmds {
-				if _, err := s.ApplicationCommandCreate(appID, guildID, c); err != nil {
-					log.Println("[cmd] create:", c.Name, err)
-				}
+	step("interactions", router.Shutdown)
+	if lr != nil {
+		step("level", lr.Shutdown)
+	}
+	if mr != nil {
+		step("mute", mr.Shutdown)
+	}
+	step("gateway", func(context.Context) error { return s.Close() })
+	if metricsSrv != nil {
+		step("metrics", metricsSrv.Shutdown)
+	}
+	if pool != nil {
+		// pool.Close ждёт, пока вернут все соединения, — не дольше дедлайна
+		step("db", func(ctx context.Context) error {
+			done := make(chan struct{})
+			go func() {
+				pool.Close()
+				close(done)
+			}()
+			select {
+			case <-done:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
 			}
-			log.Println("[cmd] registered")
 		})
-	})
-
-    top.Register(s, pool)
-
-	// запуск
-	if err := s.Open(); err != nil {
-		log.Fatal("open gateway:", err)
 	}
-	defer s.Close()
-
-	log.Println("Bot is up")
-	select {}
 }
 
-func mustDBPool(dsn string) *pgxpool.Pool {
+func mustDBPool(dsn string, logger *slog.Logger) *pgxpool.Pool {
+	log := logging.Module(logger, "main")
 	cfg, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
-		log.Fatal(err)
+		fatal(log, "parse dsn", err)
 	}
 	cfg.MaxConns = 5
 
@@ -339,48 +260,94 @@ func mustDBPool(dsn string) *pgxpool.Pool {
 
 	pool, err := pgxpool.NewWithConfig(ctx, cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal(log, "connect db", err)
 	}
 	if err := pool.Ping(ctx); err != nil {
-		log.Fatal(err)
+		fatal(log, "ping db", err)
+	}
+
+	// схема БД: накатываем недостающие миграции до регистрации модулей
+	mctx, mcancel := context.WithTimeout(context.Background(), time.Minute)
+	defer mcancel()
+	if err := migrate.Up(mctx, pool, logging.Module(logger, "migrate")); err != nil {
+		fatal(log, "migrate", err)
 	}
 	return pool
 }
 
-func mustSliceEnv(key string) []string {
-	v := strings.TrimSpace(os.Getenv(key))
-	if v == "" {
-		return nil
+// applyGuildConfig записывает серверы из конфига в guild_settings.
+// Что перезаписывается при каждом старте, а что переносится один раз — см. guildcfg.Store.Apply.
+func applyGuildConfig(guilds *guildcfg.Store, list []config.Guild, log *slog.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, g := range list {
+		err := guilds.Apply(ctx, guildcfg.Guild{
+			GuildID:           g.ID,
+			MuteRoleID:        g.MuteRoleID,
+			LogChannelID:      g.LogChannelID,
+			KeepCategoryID:    g.KeepCategoryID,
+			WelcomeChannelID:  g.WelcomeChannelID,
+			SelfRoleID:        g.SelfRoleID,
+			AfkChannelID:      g.AfkChannelID,
+			AnnounceChannelID: g.AnnounceChannelID,
+			Locale:            g.Locale,
+			RoleStacking:      g.RoleStacking,
+			Tiers:             tiersFromConfig(g.TierRoles),
+			TierMode:          g.TierMode,
+			TierExclude:       g.TierExclude,
+			LevelUp: guildcfg.LevelUp{
+				Mode:              g.LevelUp.Mode,
+				ChannelID:         g.LevelUp.ChannelID,
+				Style:             g.LevelUp.Style,
+				Template:          g.LevelUp.Template,
+				MilestoneTemplate: g.LevelUp.MilestoneTemplate,
+			},
+			Decay: guildcfg.Decay{
+				Percent:    g.XPDecay.Percent,
+				AfterDays:  g.XPDecay.AfterDays,
+				FloorLevel: g.XPDecay.FloorLevel,
+			},
+			Penalties: guildcfg.PenaltyRoles{
+				Warn1: g.PenaltyRoles.Warn1,
+				Warn2: g.PenaltyRoles.Warn2,
+				Kick:  g.PenaltyRoles.Kick,
+			},
+		})
+		if err != nil {
+			log.Error("apply guild settings", "guild", g.ID, "err", err)
+			os.Exit(1)
+		}
 	}
-	// поддержка "id1,id2,id3" или с пробелами
-	parts := strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
-	out := make([]string, 0, len(parts))
-	for _, p := range parts {
-		if p = strings.TrimSpace(p); p != "" {
-			out = append(out, p)
+}
+
+// tiersFromConfig — старые пять ролей tier_roles как ступени level_tiers
+func tiersFromConfig(t config.TierRoles) []guildcfg.Tier {
+	var out []guildcfg.Tier
+	for _, x := range []guildcfg.Tier{
+		{MinLevel: 1, RoleID: t.L1to24},
+		{MinLevel: 25, RoleID: t.L25to49},
+		{MinLevel: 50, RoleID: t.L50to74},
+		{MinLevel: 75, RoleID: t.L75to99},
+		{MinLevel: 100, RoleID: t.L100Plus},
+	} {
+		if x.RoleID != "" {
+			out = append(out, x)
 		}
 	}
 	return out
 }
 
-func wireRemove(s *discordgo.Session, guildID string, pool *pgxpool.Pool, logger *adminlog.Logger) {
-    adminRoleIDs := mustSliceEnv("ADMIN_ROLE_IDS")
-    protected    := mustSliceEnv("PROTECTED_ROLE_IDS")
-
-    _, err := remove.Register(s, guildID, adminRoleIDs, protected, pool, logger)
-    if err != nil {
-        log.Fatal("remove.Register:", err)
-    }
+func wireRemove(s *discordgo.Session, router *commands.Router, guilds *guildcfg.Store, adm *adminlog.Logger, cfg config.Config, logger *slog.Logger) {
+	_, err := remove.Register(s, router, guilds, cfg.AdminRoleIDs, cfg.ProtectedRoleIDs, adm, logging.Module(logger, "remove"))
+	if err != nil {
+		fatal(logging.Module(logger, "main"), "remove register", err)
+	}
 }
 
-func wireGive(s *discordgo.Session, guildID string, pool *pgxpool.Pool, logger *adminlog.Logger) {
-    adminRoleIDs := mustSliceEnv("ADMIN_ROLE_IDS")
-    protected    := mustSliceEnv("PROTECTED_ROLE_IDS")
-
-    _, err := give.Register(s, guildID, adminRoleIDs, protected, pool, logger)
-    if err != nil {
-        log.Fatal("give.Register:", err)
-    }
+func wireGive(s *discordgo.Session, router *commands.Router, guilds *guildcfg.Store, pool *pgxpool.Pool, adm *adminlog.Logger, cfg config.Config, curve levelcurve.Curve, logger *slog.Logger) *give.Registry {
+	gr, err := give.Register(s, router, guilds, cfg.AdminRoleIDs, cfg.ProtectedRoleIDs, pool, adm, cfg.Give, curve, logging.Module(logger, "give"))
+	if err != nil {
+		fatal(logging.Module(logger, "main"), "give register", err)
+	}
+	return gr
 }
-
-
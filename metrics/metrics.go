@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry — все метрики бота. Модули пишут в него всегда,
+// а наружу он отдаётся только если в конфиге задан metrics.listen.
+var Registry = prometheus.NewRegistry()
+
+var (
+	commandsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosha_commands_total",
+		Help: "Обработанные интеракции по команде и результату (ok, panic, unknown).",
+	}, []string{"command", "outcome"})
+
+	interactionSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gosha_interaction_duration_seconds",
+		Help:    "Время работы хендлера интеракции.",
+		Buckets: []float64{.05, .1, .25, .5, 1, 2, 3, 5, 10},
+	}, []string{"command"})
+
+	xpAwarded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosha_xp_awarded_total",
+		Help: "Начисленный XP по источнику (message, voice).",
+	}, []string{"source"})
+
+	messagesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosha_messages_skipped_total",
+		Help: "Сообщения без XP по правилам анти-спама, по причине.",
+	}, []string{"reason"})
+
+	restErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gosha_discord_rest_errors_total",
+		Help: "Ошибки REST API Discord по HTTP-коду (error — сетевая ошибка).",
+	}, []string{"code"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		commandsTotal, interactionSeconds, xpAwarded, messagesSkipped, restErrors,
+	)
+}
+
+// Исходы команды для ObserveCommand.
+const (
+	OutcomeOK      = "ok"
+	OutcomePanic   = "panic"
+	OutcomeUnknown = "unknown" // интеракция без зарегистрированного хендлера
+)
+
+// ObserveCommand — одна обработанная интеракция.
+func ObserveCommand(command, outcome string, d time.Duration) {
+	commandsTotal.WithLabelValues(command, outcome).Inc()
+	if outcome != OutcomeUnknown {
+		interactionSeconds.WithLabelValues(command).Observe(d.Seconds())
+	}
+}
+
+// Источники XP для AddXP.
+const (
+	SourceMessage = "message"
+	SourceVoice   = "voice"
+)
+
+// AddXP — сколько XP начислено из источника.
+func AddXP(source string, n int64) {
+	if n > 0 {
+		xpAwarded.WithLabelValues(source).Add(float64(n))
+	}
+}
+
+// SkipMessage — сообщение не дало XP по правилу анти-спама reason.
+func SkipMessage(reason string) {
+	messagesSkipped.WithLabelValues(reason).Inc()
+}
+
+// GaugeFunc — гейдж, значение которого считается в момент скрейпа
+// (размер карты, число строк в БД и т.п.). Регистрировать один раз.
+func GaugeFunc(name, help string, fn func() float64) {
+	Registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, fn))
+}
+
+// Transport считает неуспешные REST-запросы к Discord. Ставится в s.Client.Transport.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripper{next: next}
+}
+
+type roundTripper struct{ next http.RoundTripper }
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	switch {
+	case err != nil:
+		restErrors.WithLabelValues("error").Inc()
+	case resp.StatusCode >= 400:
+		restErrors.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	}
+	return resp, err
+}
+
+// WatchPool отдаёт статистику pgxpool.
+func WatchPool(pool *pgxpool.Pool) {
+	Registry.MustRegister(&poolCollector{pool: pool})
+}
+
+var (
+	poolAcquired  = prometheus.NewDesc("gosha_db_pool_acquired_conns", "Занятые соединения.", nil, nil)
+	poolIdle      = prometheus.NewDesc("gosha_db_pool_idle_conns", "Свободные соединения.", nil, nil)
+	poolTotal     = prometheus.NewDesc("gosha_db_pool_total_conns", "Всего открытых соединений.", nil, nil)
+	poolMax       = prometheus.NewDesc("gosha_db_pool_max_conns", "Лимит соединений пула.", nil, nil)
+	poolAcquires  = prometheus.NewDesc("gosha_db_pool_acquires_total", "Успешные Acquire.", nil, nil)
+	poolEmpty     = prometheus.NewDesc("gosha_db_pool_empty_acquires_total", "Acquire, которым пришлось ждать соединение.", nil, nil)
+	poolCanceled  = prometheus.NewDesc("gosha_db_pool_canceled_acquires_total", "Acquire, отменённые по контексту.", nil, nil)
+	poolWaitTotal = prometheus.NewDesc("gosha_db_pool_acquire_seconds_total", "Суммарное время ожидания Acquire.", nil, nil)
+)
+
+type poolCollector struct{ pool *pgxpool.Pool }
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolAcquired, poolIdle, poolTotal, poolMax, poolAcquires, poolEmpty, poolCanceled, poolWaitTotal} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(poolAcquired, prometheus.GaugeValue, float64(st.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdle, prometheus.GaugeValue, float64(st.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotal, prometheus.GaugeValue, float64(st.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMax, prometheus.GaugeValue, float64(st.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquires, prometheus.CounterValue, float64(st.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolEmpty, prometheus.CounterValue, float64(st.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceled, prometheus.CounterValue, float64(st.CanceledAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolWaitTotal, prometheus.CounterValue, st.AcquireDuration().Seconds())
+}
+
+// Serve поднимает HTTP-листенер с /metrics. Порт занимается сразу,
+// чтобы ошибка конфига всплыла на старте, а не в фоне.
+func Serve(addr string, log *slog.Logger) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("metrics server stopped", "err", err)
+		}
+	}()
+	log.Info("metrics listening", "addr", ln.Addr().String())
+	return srv, nil
+}
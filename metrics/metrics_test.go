@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gosha_bot/logging"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestTransportCountsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	before429 := testutil.ToFloat64(restErrors.WithLabelValues("429"))
+	beforeNet := testutil.ToFloat64(restErrors.WithLabelValues("error"))
+
+	client := &http.Client{Transport: Transport(nil)}
+	for _, path := range []string{"/ok", "/limited", "/limited"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get("http://127.0.0.1:1/unreachable"); err == nil {
+		t.Fatal("expected connection error")
+	}
+
+	if got := testutil.ToFloat64(restErrors.WithLabelValues("429")) - before429; got != 2 {
+		t.Errorf("429 errors = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(restErrors.WithLabelValues("error")) - beforeNet; got != 1 {
+		t.Errorf("network errors = %v, want 1", got)
+	}
+}
+
+func TestServeExposesMetrics(t *testing.T) {
+	ObserveCommand("level", OutcomeOK, 30*time.Millisecond)
+	AddXP(SourceVoice, 5)
+
+	srv, err := Serve("127.0.0.1:0", logging.Discard())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// адрес знает только листенер; проще отдать хендлер напрямую
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`gosha_commands_total{command="level",outcome="ok"}`,
+		`gosha_interaction_duration_seconds_bucket{command="level"`,
+		`gosha_xp_awarded_total{source="voice"}`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("/metrics has no %s", want)
+		}
+	}
+}
+
+func TestAddXPIgnoresNonPositive(t *testing.T) {
+	before := testutil.ToFloat64(xpAwarded.WithLabelValues(SourceMessage))
+	AddXP(SourceMessage, 0)
+	AddXP(SourceMessage, -3)
+	if got := testutil.ToFloat64(xpAwarded.WithLabelValues(SourceMessage)); got != before {
+		t.Fatalf("xp counter moved: %v → %v", before, got)
+	}
+}
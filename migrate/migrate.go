@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Миграции лежат в sql/ и вшиваются в бинарник.
+// Имя файла: NNNN_описание.sql, NNNN — номер версии (строго по возрастанию).
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// ключ pg_advisory_lock, чтобы два инстанса бота не накатывали схему одновременно
+const lockKey = 0x6f736861 // "osha"
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// ErrDBAhead — в БД применены миграции, о которых этот бинарник не знает.
+type ErrDBAhead struct {
+	DB, Binary int
+}
+
+func (e *ErrDBAhead) Error() string {
+	return fmt.Sprintf("схема БД версии %d новее, чем знает бинарник (%d) — обнови бота", e.DB, e.Binary)
+}
+
+// All возвращает все вшитые миграции, отсортированные по версии.
+func All() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		num, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+		if !ok {
+			return nil, fmt.Errorf("migrate: плохое имя файла %q (нужно NNNN_name.sql)", name)
+		}
+		v, err := strconv.Atoi(num)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("migrate: плохой номер версии в %q", name)
+		}
+		if prev, dup := seen[v]; dup {
+			return nil, fmt.Errorf("migrate: версия %d повторяется (%s, %s)", v, prev, name)
+		}
+		seen[v] = name
+		body, err := files.ReadFile("sql/" + name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Migration{Version: v, Name: rest, SQL: string(body)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+// Up применяет все недостающие миграции, каждую в своей транзакции.
+// Если БД уже новее бинарника — возвращает *ErrDBAhead и ничего не трогает.
+func Up(ctx context.Context, db *pgxpool.Pool, log *slog.Logger) error {
+	all, err := All()
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: acquire: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("migrate: lock: %w", err)
+	}
+	defer func() {
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}()
+
+	if _, err := conn.Exec(ctx, `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version    INTEGER     PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return fmt.Errorf("migrate: schema_version: %w", err)
+	}
+
+	var current int
+	if err := conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&current); err != nil {
+		return fmt.Errorf("migrate: read version: %w", err)
+	}
+
+	latest := 0
+	if len(all) > 0 {
+		latest = all[len(all)-1].Version
+	}
+	if current > latest {
+		return &ErrDBAhead{DB: current, Binary: latest}
+	}
+
+	for _, m := range all {
+		if m.Version <= current {
+			continue
+		}
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return fmt.Errorf("migrate: begin %04d: %w", m.Version, err)
+		}
+		if _, err := tx.Exec(ctx, m.SQL); err != nil {
+			_ = tx.Rollback(ctx)
+			return fmt.Errorf("migrate: %04d_%s: %w", m.Version, m.Name, err)
+		}
+		if _, err := tx.Exec(ctx,
+			`INSERT INTO schema_version (version, name) VALUES ($1, $2)`,
+			m.Version, m.Name,
+		); err != nil {
+			_ = tx.Rollback(ctx)
+			return fmt.Errorf("migrate: record %04d: %w", m.Version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return fmt.Errorf("migrate: commit %04d: %w", m.Version, err)
+		}
+		log.Info("migration applied", "version", m.Version, "name", m.Name)
+	}
+
+	if current == latest {
+		log.Info("schema is up to date", "version", current)
+	}
+	return nil
+}
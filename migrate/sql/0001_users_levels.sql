@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users_levels (
+    guild_id        TEXT        NOT NULL,
+    user_id         TEXT        NOT NULL,
+    username        TEXT,
+    display_name    TEXT,
+    xp              BIGINT      NOT NULL DEFAULT 0,
+    level           INTEGER     NOT NULL DEFAULT 1,
+    last_msg_at     TIMESTAMPTZ,
+    voice_sec_accum BIGINT      NOT NULL DEFAULT 0,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS users_levels_guild_xp_idx
+    ON users_levels (guild_id, xp DESC);
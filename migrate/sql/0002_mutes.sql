@@ -0,0 +1,24 @@
+CREATE SCHEMA IF NOT EXISTS gosha;
+
+CREATE TABLE IF NOT EXISTS gosha.mutes (
+    id               BIGSERIAL   PRIMARY KEY,
+    guild_id         TEXT        NOT NULL,
+    user_id          TEXT        NOT NULL,
+    moderator_id     TEXT        NOT NULL,
+    reason           TEXT        NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    end_at           TIMESTAMPTZ NOT NULL,
+    duration_minutes INTEGER     NOT NULL,
+    roles_removed    JSONB       NOT NULL DEFAULT '[]',
+    status           TEXT        NOT NULL DEFAULT 'active'
+                     CHECK (status IN ('active', 'completed', 'canceled')),
+    unmuted_at       TIMESTAMPTZ,
+    restored_count   INTEGER     NOT NULL DEFAULT 0
+);
+
+-- не больше одного активного мута на пользователя
+CREATE UNIQUE INDEX IF NOT EXISTS mutes_one_active_idx
+    ON gosha.mutes (guild_id, user_id) WHERE status = 'active';
+
+CREATE INDEX IF NOT EXISTS mutes_status_end_idx
+    ON gosha.mutes (status, end_at);
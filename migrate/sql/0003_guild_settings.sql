@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS guild_settings (
+    guild_id           TEXT        PRIMARY KEY,
+    mute_role_id       TEXT        NOT NULL DEFAULT '',
+    log_channel_id     TEXT        NOT NULL DEFAULT '',
+    keep_category_id   TEXT        NOT NULL DEFAULT '',
+    welcome_channel_id TEXT        NOT NULL DEFAULT '',
+    self_role_id       TEXT        NOT NULL DEFAULT '',
+    afk_channel_id     TEXT        NOT NULL DEFAULT '',
+    role_l1_24         TEXT        NOT NULL DEFAULT '',
+    role_l25_49        TEXT        NOT NULL DEFAULT '',
+    role_l50_74        TEXT        NOT NULL DEFAULT '',
+    role_l75_99        TEXT        NOT NULL DEFAULT '',
+    role_l100_plus     TEXT        NOT NULL DEFAULT '',
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
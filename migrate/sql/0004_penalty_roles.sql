@@ -0,0 +1,4 @@
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS penalty_warn1_role_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS penalty_warn2_role_id TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS penalty_kick_role_id  TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+-- язык бота на сервере: '' — по локали пользователя/сервера, иначе 'ru' или 'en'
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT '';
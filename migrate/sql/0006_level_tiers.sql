@@ -0,0 +1,25 @@
+-- роли за уровни: сколько угодно ступеней на сервер (min_level → role_id)
+CREATE TABLE IF NOT EXISTS level_tiers (
+    guild_id   TEXT        NOT NULL,
+    min_level  INT         NOT NULL CHECK (min_level >= 1),
+    role_id    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, min_level),
+    UNIQUE (guild_id, role_id)
+);
+
+-- переносим пять фиксированных ролей из guild_settings
+INSERT INTO level_tiers (guild_id, min_level, role_id)
+SELECT guild_id, t.min_level, t.role_id
+FROM guild_settings,
+     LATERAL (VALUES (1, role_l1_24), (25, role_l25_49), (50, role_l50_74),
+                     (75, role_l75_99), (100, role_l100_plus)) AS t(min_level, role_id)
+WHERE t.role_id <> ''
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE guild_settings
+    DROP COLUMN IF EXISTS role_l1_24,
+    DROP COLUMN IF EXISTS role_l25_49,
+    DROP COLUMN IF EXISTS role_l50_74,
+    DROP COLUMN IF EXISTS role_l75_99,
+    DROP COLUMN IF EXISTS role_l100_plus;
@@ -0,0 +1,8 @@
+-- множители XP: channel_id — текстовый/голосовой канал или категория; 0 — XP не начисляется
+CREATE TABLE IF NOT EXISTS xp_multipliers (
+    guild_id   TEXT             NOT NULL,
+    channel_id TEXT             NOT NULL,
+    multiplier DOUBLE PRECISION NOT NULL CHECK (multiplier >= 0),
+    updated_at TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, channel_id)
+);
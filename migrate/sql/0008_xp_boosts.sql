@@ -0,0 +1,17 @@
+-- канал для объявлений бота (XP-бусты, повышения уровня)
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS announce_channel_id TEXT NOT NULL DEFAULT '';
+
+-- XP-бусты: временный множитель на весь сервер (channel_id = '') или на канал/категорию
+CREATE TABLE IF NOT EXISTS xp_boosts (
+    id         BIGSERIAL        PRIMARY KEY,
+    guild_id   TEXT             NOT NULL,
+    channel_id TEXT             NOT NULL DEFAULT '',
+    multiplier DOUBLE PRECISION NOT NULL CHECK (multiplier > 0),
+    starts_at  TIMESTAMPTZ      NOT NULL,
+    ends_at    TIMESTAMPTZ      NOT NULL,
+    created_by TEXT             NOT NULL DEFAULT '',
+    ended      BOOLEAN          NOT NULL DEFAULT false -- конец объявлен (или буст остановлен)
+);
+
+CREATE INDEX IF NOT EXISTS xp_boosts_open ON xp_boosts (ends_at) WHERE NOT ended;
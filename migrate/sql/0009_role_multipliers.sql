@@ -0,0 +1,12 @@
+-- множители XP за роли (бустеры, спонсоры); как складываются несколько ролей — role_stacking
+CREATE TABLE IF NOT EXISTS role_multipliers (
+    guild_id   TEXT             NOT NULL,
+    role_id    TEXT             NOT NULL,
+    multiplier DOUBLE PRECISION NOT NULL CHECK (multiplier >= 0),
+    updated_at TIMESTAMPTZ      NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, role_id)
+);
+
+-- '' — по умолчанию (max), 'max' — сильнейшая роль, 'multiply' — произведение
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS role_stacking TEXT NOT NULL DEFAULT '';
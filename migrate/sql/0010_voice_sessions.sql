@@ -0,0 +1,9 @@
+-- открытые войс-сессии на момент остановки (и раз в минуту на случай падения):
+-- после рестарта время простоя засчитывается, но не больше xp.voice_downtime_credit
+CREATE TABLE IF NOT EXISTS voice_sessions (
+    guild_id   TEXT        NOT NULL,
+    user_id    TEXT        NOT NULL,
+    channel_id TEXT        NOT NULL,
+    seen_at    TIMESTAMPTZ NOT NULL, -- до этого момента бот точно видел участника в канале
+    PRIMARY KEY (guild_id, user_id)
+);
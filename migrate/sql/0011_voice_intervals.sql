@@ -0,0 +1,12 @@
+-- история войса: каждая закрытая сессия (вход → выход, смена канала, потеря права на XP)
+CREATE TABLE IF NOT EXISTS voice_intervals (
+    id         BIGSERIAL   PRIMARY KEY,
+    guild_id   TEXT        NOT NULL,
+    user_id    TEXT        NOT NULL,
+    channel_id TEXT        NOT NULL,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at   TIMESTAMPTZ NOT NULL,
+    xp         BIGINT      NOT NULL DEFAULT 0 -- начислено за сессию
+);
+
+CREATE INDEX IF NOT EXISTS voice_intervals_user ON voice_intervals (guild_id, user_id, ended_at);
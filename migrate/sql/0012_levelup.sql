@@ -0,0 +1,16 @@
+-- объявления о новом уровне: куда ('' / 'off' — никуда, 'channel', 'same', 'dm'),
+-- в каком виде ('' / 'text', 'embed') и по каким шаблонам ('' — стандартный текст)
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS levelup_mode               TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS levelup_channel_id         TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS levelup_style              TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS levelup_template           TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS levelup_milestone_template TEXT NOT NULL DEFAULT '';
+
+-- участники, которые не хотят объявлений о своих уровнях
+CREATE TABLE IF NOT EXISTS levelup_optout (
+    guild_id   TEXT        NOT NULL,
+    user_id    TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (guild_id, user_id)
+);
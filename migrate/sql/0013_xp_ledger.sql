@@ -0,0 +1,26 @@
+-- журнал изменений XP: каждая строка пишется в той же транзакции, что и само изменение;
+-- строки не меняются и не удаляются (триггер ниже)
+CREATE TABLE IF NOT EXISTS xp_ledger (
+    id         BIGSERIAL   PRIMARY KEY,
+    guild_id   TEXT        NOT NULL,
+    user_id    TEXT        NOT NULL,
+    delta      BIGINT      NOT NULL,
+    xp_after   BIGINT      NOT NULL,
+    source     TEXT        NOT NULL,            -- message | voice | penalty | ...
+    actor_id   TEXT        NOT NULL DEFAULT '', -- кто изменил; '' — сам бот
+    reason     TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS xp_ledger_user_idx ON xp_ledger (guild_id, user_id, id DESC);
+
+CREATE OR REPLACE FUNCTION xp_ledger_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'xp_ledger is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS xp_ledger_append_only ON xp_ledger;
+CREATE TRIGGER xp_ledger_append_only
+    BEFORE UPDATE OR DELETE ON xp_ledger
+    FOR EACH ROW EXECUTE FUNCTION xp_ledger_append_only();
@@ -0,0 +1,17 @@
+-- затухание XP: когда участник последний раз получал XP в войсе (сообщения — last_msg_at)
+-- и когда у него последний раз списывали XP за неактивность
+ALTER TABLE users_levels
+    ADD COLUMN IF NOT EXISTS last_voice_at TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS decayed_at    TIMESTAMPTZ;
+
+-- войс до этой миграции — по закрытым интервалам
+UPDATE users_levels u SET last_voice_at = v.ended_at
+FROM (SELECT guild_id, user_id, max(ended_at) AS ended_at FROM voice_intervals GROUP BY guild_id, user_id) v
+WHERE u.guild_id = v.guild_id AND u.user_id = v.user_id AND u.last_voice_at IS NULL;
+
+-- политика сервера: decay_percent% XP в неделю после decay_after_days дней без сообщений и войса,
+-- не ниже уровня decay_floor_level; decay_percent = 0 — выключено
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS decay_percent     DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (decay_percent >= 0 AND decay_percent <= 100),
+    ADD COLUMN IF NOT EXISTS decay_after_days  INTEGER          NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS decay_floor_level INTEGER          NOT NULL DEFAULT 0;
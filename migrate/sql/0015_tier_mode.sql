@@ -0,0 +1,5 @@
+-- как выдаются роли за уровни: '' / 'replace' — только старшая ступень, 'stack' — все заработанные,
+-- 'stack_except' — все заработанные, кроме tier_exclude (их снимают, когда заработана ступень выше)
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS tier_mode    TEXT   NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS tier_exclude TEXT[] NOT NULL DEFAULT '{}';
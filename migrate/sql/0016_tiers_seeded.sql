@@ -0,0 +1,8 @@
+-- tier_roles из конфига переносятся в level_tiers один раз: после этого ступенями управляет
+-- только /levelroles, и удалённая ступень не возвращается при рестарте.
+-- Серверы, у которых ступени уже есть, считаются перенесёнными.
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS tiers_seeded BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE guild_settings SET tiers_seeded = true
+WHERE guild_id IN (SELECT DISTINCT guild_id FROM level_tiers);
@@ -0,0 +1,11 @@
+-- Настройки, которыми управляют слэш-команды (сложение ролей, объявления о новом уровне, затухание,
+-- режим ступеней), берутся из конфига один раз: дальше их меняют только команды, и рестарт
+-- не затирает то, что выставили админы. Серверы, у которых они уже заданы, считаются перенесёнными.
+ALTER TABLE guild_settings
+    ADD COLUMN IF NOT EXISTS settings_seeded BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE guild_settings SET settings_seeded = true
+WHERE role_stacking <> '' OR tier_mode <> '' OR cardinality(tier_exclude) > 0
+   OR levelup_mode <> '' OR levelup_channel_id <> '' OR levelup_style <> ''
+   OR levelup_template <> '' OR levelup_milestone_template <> ''
+   OR decay_percent <> 0 OR decay_after_days <> 0 OR decay_floor_level <> 0;
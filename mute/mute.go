@@ -2,89 +2,239 @@ package mute
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
+	"gosha_bot/metrics"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Registry struct {
-    GuildID      string
-    LogChannelID string
-    KeepCategory string
-    MutedRoleID  string
-    s            *discordgo.Session
-    unmuteTimers map[string]*time.Timer
-    DB           *pgxpool.Pool
+	Guilds *guildcfg.Store
+	s      discord.Session
+	Store  Store // nil — БД не настроена
 
-    // ↓ добавь это
-    RetentionDays int // через сколько дней чистим completed/canceled; по умолчанию 30
+	muTimers     sync.Mutex
+	unmuteTimers map[string]*time.Timer // ключ: guildID + ":" + userID
 
-    AdminLog *adminlog.Logger
+	// ↓ добавь это
+	RetentionDays int // через сколько дней чистим completed/canceled; по умолчанию 30
+
+	AdminLog *adminlog.Logger
+	log      *slog.Logger
+
+	stop        chan struct{} // закрывается в Shutdown
+	stopOnce    sync.Once
+	maintenance sync.WaitGroup
+}
+
+// Register объявляет /mute и /unmute в роутере. Роль мута и лог-канал берутся из настроек сервера (guildcfg).
+func Register(s *discordgo.Session, router *commands.Router, guilds *guildcfg.Store, db *pgxpool.Pool, log *slog.Logger) (*Registry, error) {
+	var store Store
+	if db != nil {
+		store = NewPGStore(db)
+	}
+	r := newRegistry(s, guilds, store)
+	r.log = log
+
+	minMinutes := 1.0
+	router.Add(&discordgo.ApplicationCommand{
+		Name:        "mute",
+		Description: "Выдать мут пользователю на N минут",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "Кому выдать мут", Required: true},
+			{Type: discordgo.ApplicationCommandOptionInteger, Name: "minutes", Description: "На сколько минут", Required: true, MinValue: &minMinutes},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина", Required: false},
+		},
+	}, r.guard(r.handleMute))
+	router.Add(&discordgo.ApplicationCommand{
+		Name:        "unmute",
+		Description: "Снять мут с пользователя",
+		Options: []*discordgo.ApplicationCommandOption{
+			{Type: discordgo.ApplicationCommandOptionUser, Name: "user", Description: "С кого снять мут", Required: true},
+			{Type: discordgo.ApplicationCommandOptionString, Name: "reason", Description: "Причина", Required: false},
+		},
+	}, r.guard(r.handleUnmute))
+
+	metrics.GaugeFunc("gosha_unmute_timers", "Запланированные таймеры авто-размута.", func() float64 {
+		r.muTimers.Lock()
+		defer r.muTimers.Unlock()
+		return float64(len(r.unmuteTimers))
+	})
+
+	// запускаем обслуживание, если есть БД
+	if r.Store != nil {
+		metrics.GaugeFunc("gosha_active_mutes", "Активные муты в БД (все серверы).", func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			n, err := r.Store.CountActive(ctx)
+			if err != nil {
+				r.log.Warn("count active mutes", "err", err)
+				return math.NaN()
+			}
+			return float64(n)
+		})
+		r.restoreTimers()
+		r.startDailyMaintenance()
+	}
+
+	return r, nil
+}
+
+func newRegistry(api discord.Session, guilds *guildcfg.Store, store Store) *Registry {
+	return &Registry{
+		Guilds:       guilds,
+		s:            api,
+		Store:        store,
+		unmuteTimers: make(map[string]*time.Timer),
+		stop:         make(chan struct{}),
+		log:          logging.Discard(),
+
+		RetentionDays: 30, // ← дефолт
+	}
+}
+
+// роль мута на сервере ("" — не настроена)
+func (r *Registry) mutedRoleID(guildID string) string {
+	if cfg := r.Guilds.Get(guildID); cfg != nil {
+		return cfg.MuteRoleID
+	}
+	return ""
 }
 
+func (r *Registry) logChannelID(guildID string) string {
+	if cfg := r.Guilds.Get(guildID); cfg != nil {
+		return cfg.LogChannelID
+	}
+	return ""
+}
 
-func Register(s *discordgo.Session, guildID, keepCategoryID, logChannelID, mutedRoleID string, db *pgxpool.Pool) (*Registry, error) {
-    if mutedRoleID == "" {
-        return nil, fmt.Errorf("MUTE_ROLE_ID is empty (нужен ID существующей роли)")
-    }
-    r := &Registry{
-        GuildID:      guildID,
-        LogChannelID: logChannelID,
-        KeepCategory: keepCategoryID,
-        MutedRoleID:  mutedRoleID,
-        s:            s,
-        unmuteTimers: make(map[string]*time.Timer),
-        DB:           db,
+func timerKey(guildID, userID string) string { return guildID + ":" + userID }
 
-        RetentionDays: 30, // ← дефолт
-    }
-    s.AddHandler(r.onInteraction)
+// setUnmuteTimer заменяет таймер авто-размута (старый останавливается); nil — просто снять.
+func (r *Registry) setUnmuteTimer(guildID, userID string, t *time.Timer) {
+	r.muTimers.Lock()
+	defer r.muTimers.Unlock()
+	k := timerKey(guildID, userID)
+	if old, ok := r.unmuteTimers[k]; ok {
+		old.Stop()
+		delete(r.unmuteTimers, k)
+	}
+	if t != nil {
+		r.unmuteTimers[k] = t
+	}
+}
 
-    // запускаем обслуживание, если есть БД
-    if r.DB != nil {
-        r.startDailyMaintenance()
-    }
+// scheduleUnmute ставит авто-размут через d (d <= 0 — сразу), заменяя прежний таймер.
+func (r *Registry) scheduleUnmute(guildID, userID string, d time.Duration) {
+	k := timerKey(guildID, userID)
+	r.muTimers.Lock()
+	defer r.muTimers.Unlock()
+	if old, ok := r.unmuteTimers[k]; ok {
+		old.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		if err := r.forceUnmute(guildID, userID, "auto-unmute"); err != nil {
+			r.log.Error("auto-unmute", "guild", guildID, "user", userID, "err", err)
+		}
+		r.muTimers.Lock()
+		if r.unmuteTimers[k] == t { // за это время могли замьютить заново
+			delete(r.unmuteTimers, k)
+		}
+		r.muTimers.Unlock()
+	})
+	r.unmuteTimers[k] = t
+}
 
-    return r, nil
+// restoreTimers поднимает таймеры для активных мутов из БД: таймеры живут только в памяти
+// и пропадают при рестарте. Просроченные за время простоя снимаются сразу.
+func (r *Registry) restoreTimers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	active, err := r.Store.ListActive(ctx)
+	if err != nil {
+		r.log.Error("restore unmute timers", "err", err)
+		return
+	}
+	now := time.Now()
+	for _, m := range active {
+		r.scheduleUnmute(m.GuildID, m.UserID, m.EndAt.Sub(now))
+	}
+	if len(active) > 0 {
+		r.log.Info("unmute timers restored", "count", len(active))
+	}
 }
 
+// Shutdown останавливает ежедневную чистку и все таймеры авто-размута.
+// Муты остаются active в БД, при следующем старте таймеры поднимет restoreTimers.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stop) })
 
-func (r *Registry) onInteraction(s *discordgo.Session, ic *discordgo.InteractionCreate) {
-	if ic.GuildID == "" || ic.Member == nil { return }
-	if ic.Type != discordgo.InteractionApplicationCommand { return }
-	switch ic.ApplicationCommandData().Name {
-	case "mute":
-		r.handleMute(ic)
-	case "unmute":
-		r.handleUnmute(ic)
+	r.muTimers.Lock()
+	for k, t := range r.unmuteTimers {
+		t.Stop()
+		delete(r.unmuteTimers, k)
+	}
+	r.muTimers.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.maintenance.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("mute maintenance: %w", ctx.Err())
+	}
+}
+
+// команды работают только на сервере
+func (r *Registry) guard(h func(ic *discordgo.InteractionCreate)) commands.Handler {
+	return func(_ *discordgo.Session, ic *discordgo.InteractionCreate) {
+		if ic.GuildID == "" || ic.Member == nil {
+			return
+		}
+		if ic.Type != discordgo.InteractionApplicationCommand {
+			return
+		}
+		h(ic)
 	}
 }
 
 // ---------- public handlers ----------
 
 func (r *Registry) handleMute(ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
 	// защита от паники + гарантированный ответ
 	defer func() {
 		if rec := recover(); rec != nil {
-			log.Println("[mute] panic:", rec)
-			editReply(r.s, ic, "❌ Внутренняя ошибка при обработке команды.")
+			logging.Interaction(r.log, ic).Error("mute panicked", "panic", fmt.Sprint(rec))
+			r.editReply(ic, i18n.T(lang, "common.internal_error"))
 		}
 	}()
-	ackEphemeral(r.s, ic) // моментальный ACK
-
-    opts := ic.ApplicationCommandData().Options
-if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
-    editReply(r.s, ic, "⛔ Неверные аргументы команды.")
-    return
-}
+	r.ackEphemeral(ic) // моментальный ACK
 
+	opts := ic.ApplicationCommandData().Options
+	if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
+		r.editReply(ic, i18n.T(lang, "mute.bad_args"))
+		return
+	}
 
 	gid := ic.GuildID
 	target := ic.ApplicationCommandData().Options[0].UserValue(nil)
@@ -94,60 +244,82 @@ if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
 		reason = strings.TrimSpace(ic.ApplicationCommandData().Options[2].StringValue())
 	}
 
-	if err := r.checkPermissions(ic, target.ID); err != nil { editReply(r.s, ic, "⛔ "+err.Error()); return }
-	if minutes <= 0 { editReply(r.s, ic, "⛔ minutes должен быть > 0"); return }
-	if r.DB == nil { editReply(r.s, ic, "⛔ DB недоступна — POSTGRES_DSN не настроен"); return }
+	if r.mutedRoleID(gid) == "" {
+		r.editReply(ic, i18n.T(lang, "mute.no_role"))
+		return
+	}
+	if err := r.checkPermissions(ic, target.ID, lang); err != nil {
+		r.editReply(ic, "⛔ "+err.Error())
+		return
+	}
+	if minutes <= 0 {
+		r.editReply(ic, i18n.T(lang, "mute.bad_minutes"))
+		return
+	}
+	if r.Store == nil {
+		r.editReply(ic, i18n.T(lang, "common.no_db"))
+		return
+	}
 
 	exists, err := r.hasActiveMute(gid, target.ID)
-	if err != nil { editReply(r.s, ic, "DB error: "+err.Error()); return }
-	if exists { editReply(r.s, ic, "⛔ У пользователя уже есть активный мут."); return }
+	if err != nil {
+		r.editReply(ic, i18n.T(lang, "mute.db_error", err))
+		return
+	}
+	if exists {
+		r.editReply(ic, i18n.T(lang, "mute.already"))
+		return
+	}
 
 	rolesToRemove, err := r.computeRolesToRemove(gid, target.ID)
-	if err != nil { editReply(r.s, ic, "❌ Роли: "+err.Error()); return }
+	if err != nil {
+		r.editReply(ic, i18n.T(lang, "mute.roles_failed", err))
+		return
+	}
 
 	removed, err := r.dropRoles(gid, target.ID, rolesToRemove)
-	if err != nil { editReply(r.s, ic, "❌ Снятие ролей: "+err.Error()); return }
+	if err != nil {
+		r.editReply(ic, i18n.T(lang, "mute.drop_failed", err))
+		return
+	}
 
 	if err := r.addMutedRoleOnly(gid, target.ID); err != nil {
-		_ = r.restoreRoles(gid, target.ID, removed)
-		editReply(r.s, ic, "❌ Не смог выдать мут: "+err.Error()); return
+		r.rollback(ic, target.ID, removed, false)
+		r.editReply(ic, i18n.T(lang, "mute.add_failed", err))
+		return
 	}
 
 	if err := r.insertMuteRow(gid, target.ID, ic.Member.User.ID, reason, minutes, removed); err != nil {
-		_ = r.removeMutedRole(gid, target.ID)
-		_ = r.restoreRoles(gid, target.ID, removed)
-		editReply(r.s, ic, "❌ DB insert: "+err.Error()); return
+		r.rollback(ic, target.ID, removed, true)
+		r.editReply(ic, i18n.T(lang, "mute.insert_failed", err))
+		return
 	}
 
-	if old, ok := r.unmuteTimers[target.ID]; ok { old.Stop() }
-	r.unmuteTimers[target.ID] = time.AfterFunc(time.Duration(minutes)*time.Minute, func() {
-		if err := r.forceUnmute(gid, target.ID, "auto-unmute"); err != nil {
-			log.Println("[mute] auto-unmute:", err)
-		}
-	})
+	r.scheduleUnmute(gid, target.ID, time.Duration(minutes)*time.Minute)
 
-	editReply(r.s, ic, fmt.Sprintf("✅ Мут выдан %s на %d мин.", mentionUser(target.ID), minutes))
-	if r.AdminLog != nil { r.AdminLog.PostMute(target, ic.Member.User, reason, minutes) } else {
-		r.logEmbedMute("⛔ Мут", target, ic.Member.User, reason, minutes, 0xE74C3C)
+	r.editReply(ic, i18n.T(lang, "mute.done", mentionUser(target.ID), minutes))
+	if r.AdminLog != nil {
+		r.AdminLog.PostMute(gid, target, ic.Member.User, reason, minutes)
+	} else {
+		r.logEmbedMute(gid, "log.mute.title", target, ic.Member.User, reason, minutes, 0xE74C3C)
 	}
 }
 
-
 func (r *Registry) handleUnmute(ic *discordgo.InteractionCreate) {
+	lang := i18n.For(ic.Interaction)
 	defer func() {
 		if rec := recover(); rec != nil {
-			log.Println("[unmute] panic:", rec)
-			editReply(r.s, ic, "❌ Внутренняя ошибка при обработке команды.")
+			logging.Interaction(r.log, ic).Error("unmute panicked", "panic", fmt.Sprint(rec))
+			r.editReply(ic, i18n.T(lang, "common.internal_error"))
 		}
 	}()
-	ackEphemeral(r.s, ic)
-
-    opts := ic.ApplicationCommandData().Options
-if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
-    editReply(r.s, ic, "⛔ Неверные аргументы команды.")
-    return
-}
+	r.ackEphemeral(ic)
 
+	opts := ic.ApplicationCommandData().Options
+	if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
+		r.editReply(ic, i18n.T(lang, "mute.bad_args"))
+		return
+	}
 
 	gid := ic.GuildID
 	target := ic.ApplicationCommandData().Options[0].UserValue(nil)
@@ -156,16 +328,26 @@ if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
 		reason = strings.TrimSpace(ic.ApplicationCommandData().Options[1].StringValue())
 	}
 
-	if err := r.checkPermissions(ic, target.ID); err != nil { editReply(r.s, ic, "⛔ "+err.Error()); return }
+	if r.mutedRoleID(gid) == "" {
+		r.editReply(ic, i18n.T(lang, "mute.no_role"))
+		return
+	}
+	if err := r.checkPermissions(ic, target.ID, lang); err != nil {
+		r.editReply(ic, "⛔ "+err.Error())
+		return
+	}
 
 	if err := r.forceUnmute(gid, target.ID, reason); err != nil {
-		editReply(r.s, ic, "❌ Размут не удался: "+err.Error()); return
+		r.editReply(ic, i18n.T(lang, "mute.unmute_failed", err))
+		return
 	}
-	if t, ok := r.unmuteTimers[target.ID]; ok { t.Stop(); delete(r.unmuteTimers, target.ID) }
+	r.setUnmuteTimer(gid, target.ID, nil)
 
-	editReply(r.s, ic, fmt.Sprintf("✅ Мут снят с %s.", mentionUser(target.ID)))
-	if r.AdminLog != nil { r.AdminLog.PostUnmute(target, ic.Member.User, reason) } else {
-		r.logEmbed("♻️ Размут", target, ic.Member.User, reason, 0x2ECC71)
+	r.editReply(ic, i18n.T(lang, "mute.unmute_done", mentionUser(target.ID)))
+	if r.AdminLog != nil {
+		r.AdminLog.PostUnmute(gid, target, ic.Member.User, reason)
+	} else {
+		r.logEmbed(gid, "log.unmute.title", target, ic.Member.User, reason, 0x2ECC71)
 	}
 }
 
@@ -173,19 +355,22 @@ if len(opts) < 2 || opts[0].Type != discordgo.ApplicationCommandOptionUser {
 
 func (r *Registry) addMutedRoleOnly(guildID, userID string) error {
 	// гарантия: перед вызовом все роли (кроме everyone) сняты
-	return r.s.GuildMemberRoleAdd(guildID, userID, r.MutedRoleID)
+	return r.s.GuildMemberRoleAdd(guildID, userID, r.mutedRoleID(guildID))
 }
 
 func (r *Registry) removeMutedRole(guildID, userID string) error {
-	return r.s.GuildMemberRoleRemove(guildID, userID, r.MutedRoleID)
+	return r.s.GuildMemberRoleRemove(guildID, userID, r.mutedRoleID(guildID))
 }
 
 func (r *Registry) computeRolesToRemove(guildID, userID string) ([]string, error) {
 	m, err := r.s.GuildMember(guildID, userID)
-	if err != nil { return nil, err }
+	if err != nil {
+		return nil, err
+	}
+	muted := r.mutedRoleID(guildID)
 	out := make([]string, 0, len(m.Roles))
 	for _, rid := range m.Roles {
-		if rid == r.MutedRoleID { // если уже есть — всё равно снимем и потом повесим заново
+		if rid == muted { // если уже есть — всё равно снимем и потом повесим заново
 			continue
 		}
 		out = append(out, rid)
@@ -198,7 +383,9 @@ func (r *Registry) dropRoles(guildID, userID string, roles []string) ([]string,
 	for _, rid := range roles {
 		if err := r.s.GuildMemberRoleRemove(guildID, userID, rid); err != nil {
 			// откат уже снятых — чтобы не оставить юзера голым
-			_ = r.restoreRoles(guildID, userID, removed)
+			if rbErr := r.restoreRoles(guildID, userID, removed); rbErr != nil {
+				r.log.Error("rollback dropped roles", "guild", guildID, "user", userID, "err", rbErr)
+			}
 			return nil, fmt.Errorf("снятие роли %s: %w", rid, err)
 		}
 		removed = append(removed, rid)
@@ -220,63 +407,41 @@ func (r *Registry) restoreRoles(guildID, userID string, roles []string) error {
 func (r *Registry) hasActiveMute(guildID, userID string) (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	var exists bool
-	err := r.DB.QueryRow(ctx,
-		`SELECT EXISTS(SELECT 1 FROM gosha.mutes WHERE guild_id=$1 AND user_id=$2 AND status='active')`,
-		guildID, userID,
-	).Scan(&exists)
-	return exists, err
+	return r.Store.HasActive(ctx, guildID, userID)
 }
 
 func (r *Registry) insertMuteRow(guildID, userID, moderatorID, reason string, minutes int, removedRoles []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	b, _ := json.Marshal(removedRoles)
-	endAt := time.Now().Add(time.Duration(minutes) * time.Minute)
-	_, err := r.DB.Exec(ctx, `INSERT INTO gosha.mutes (guild_id, user_id, moderator_id, reason, end_at, duration_minutes, roles_removed, status)
- VALUES ($1,$2,$3,$4,$5,$6,$7,'active')`,
-		guildID, userID, moderatorID, reason, endAt, minutes, b,
-	)
-	return err
+	return r.Store.Insert(ctx, guildID, userID, moderatorID, reason, minutes, removedRoles)
 }
 
 func (r *Registry) getActiveMute(guildID, userID string) (id int64, roles []string, err error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	var jb []byte
-	err = r.DB.QueryRow(ctx, 
-        `SELECT id, roles_removed FROM gosha.mutes
- WHERE guild_id=$1 AND user_id=$2 AND status='active'
- ORDER BY id DESC LIMIT 1`, 
- guildID, userID).Scan(&id, &jb)
-	if err != nil { return 0, nil, err }
-	if len(jb) > 0 {
-		_ = json.Unmarshal(jb, &roles)
-	}
-	return id, roles, nil
+	return r.Store.Active(ctx, guildID, userID)
 }
 
 func (r *Registry) completeMute(id int64) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	_, err := r.DB.Exec(ctx, `UPDATE gosha.mutes
-   SET status='completed', unmuted_at=now(), restored_count=restored_count+1
- WHERE id=$1 AND status='active'`, id)
-	return err
+	return r.Store.Complete(ctx, id)
 }
 
 // ---------- force unmute ----------
 
 func (r *Registry) forceUnmute(guildID, userID, reason string) error {
-	if r.DB == nil {
+	if r.Store == nil {
 		return fmt.Errorf("DB is nil")
 	}
 	if reason != "" {
-		log.Printf("[mute] forceUnmute user=%s reason=%q", userID, reason)
+		r.log.Info("force unmute", "guild", guildID, "user", userID, "reason", reason)
 	}
 
 	id, roles, err := r.getActiveMute(guildID, userID)
-	if err != nil { return fmt.Errorf("getActiveMute: %w", err) }
+	if err != nil {
+		return fmt.Errorf("getActiveMute: %w", err)
+	}
 
 	if err := r.removeMutedRole(guildID, userID); err != nil {
 		return fmt.Errorf("removeMutedRole: %w", err)
@@ -290,131 +455,139 @@ func (r *Registry) forceUnmute(guildID, userID, reason string) error {
 	return nil
 }
 
-
 // ---------- perms / hierarchy ----------
 
-func (r *Registry) checkPermissions(ic *discordgo.InteractionCreate, targetUserID string) error {
+func (r *Registry) checkPermissions(ic *discordgo.InteractionCreate, targetUserID string, lang i18n.Lang) error {
 	perms, err := r.s.UserChannelPermissions(ic.Member.User.ID, ic.ChannelID)
-	if err != nil { return fmt.Errorf("не удалось проверить права: %w", err) }
+	if err != nil {
+		return errors.New(i18n.T(lang, "mute.err.perm_check", err))
+	}
 	if perms&discordgo.PermissionAdministrator == 0 && perms&discordgo.PermissionManageRoles == 0 {
-		return fmt.Errorf("нужны права Administrator или Manage Roles")
+		return errors.New(i18n.T(lang, "mute.err.need_perms"))
+	}
+	ok, err := r.botHigherThan(ic.GuildID, targetUserID)
+	if err != nil {
+		return errors.New(i18n.T(lang, "mute.err.hierarchy_target", err))
 	}
-	ok, err := r.botHigherThan(targetUserID)
-	if err != nil { return fmt.Errorf("иерархия (target): %w", err) }
-	if !ok { return fmt.Errorf("бот ниже цели по иерархии ролей") }
-	if ok, err := r.botHigherThanRole(r.MutedRoleID); err != nil {
-		return fmt.Errorf("иерархия (mute role): %w", err)
+	if !ok {
+		return errors.New(i18n.T(lang, "mute.err.bot_below_target"))
+	}
+	if ok, err := r.botHigherThanRole(ic.GuildID, r.mutedRoleID(ic.GuildID)); err != nil {
+		return errors.New(i18n.T(lang, "mute.err.hierarchy_role", err))
 	} else if !ok {
-		return fmt.Errorf("роль мута выше роли бота — перетащи мьют-роль НИЖЕ роли бота")
+		return errors.New(i18n.T(lang, "mute.err.role_above_bot"))
 	}
 	return nil
 }
 
-func (r *Registry) botHigherThan(targetUserID string) (bool, error) {
-	g, err := r.s.State.Guild(r.GuildID)
-	if err != nil || g == nil {
-		g, err = r.s.Guild(r.GuildID)
-		if err != nil { return false, err }
+func (r *Registry) botHigherThan(guildID, targetUserID string) (bool, error) {
+	roles, err := discord.Roles(r.s, guildID)
+	if err != nil {
+		return false, err
 	}
-	rolePos := func(ids []string) int {
-		max := -1
-		for _, id := range ids {
-			for _, gr := range g.Roles {
-				if gr.ID == id && gr.Position > max { max = gr.Position }
-			}
-		}
-		return max
-	}
-	botID, err := botIDSafe(r.s); if err != nil { return false, err }
-	bm, err := r.s.GuildMember(r.GuildID, botID); if err != nil { return false, err }
-	tm, err := r.s.GuildMember(r.GuildID, targetUserID); if err != nil { return false, err }
-	return rolePos(bm.Roles) > rolePos(tm.Roles), nil
-}
-
-func (r *Registry) botHigherThanRole(roleID string) (bool, error) {
-	g, err := r.s.State.Guild(r.GuildID)
-	if err != nil || g == nil {
-		g, err = r.s.Guild(r.GuildID)
-		if err != nil { return false, err }
-	}
-	targetPos := -1
-	for _, gr := range g.Roles {
-		if gr.ID == roleID { targetPos = gr.Position; break }
-	}
-	if targetPos == -1 { return false, fmt.Errorf("роль %s не найдена", roleID) }
-	botID, err := botIDSafe(r.s); if err != nil { return false, err }
-	bm, err := r.s.GuildMember(r.GuildID, botID); if err != nil { return false, err }
-	botTop := -1
-	for _, id := range bm.Roles {
-		for _, gr := range g.Roles {
-			if gr.ID == id && gr.Position > botTop { botTop = gr.Position }
-		}
+	botID, err := discord.BotID(r.s)
+	if err != nil {
+		return false, err
+	}
+	bm, err := r.s.GuildMember(guildID, botID)
+	if err != nil {
+		return false, err
 	}
-	return botTop > targetPos, nil
+	tm, err := r.s.GuildMember(guildID, targetUserID)
+	if err != nil {
+		return false, err
+	}
+	return discord.HighestRolePosition(roles, bm.Roles) > discord.HighestRolePosition(roles, tm.Roles), nil
+}
+
+func (r *Registry) botHigherThanRole(guildID, roleID string) (bool, error) {
+	roles, err := discord.Roles(r.s, guildID)
+	if err != nil {
+		return false, err
+	}
+	targetPos := discord.HighestRolePosition(roles, []string{roleID})
+	if targetPos == -1 {
+		return false, fmt.Errorf("роль %s не найдена", roleID)
+	}
+	botID, err := discord.BotID(r.s)
+	if err != nil {
+		return false, err
+	}
+	bm, err := r.s.GuildMember(guildID, botID)
+	if err != nil {
+		return false, err
+	}
+	return discord.HighestRolePosition(roles, bm.Roles) > targetPos, nil
 }
 
 // публичный сеттер, если захочешь поменять срок хранения из main.go
 func (r *Registry) SetRetentionDays(days int) {
-    if days < 1 { days = 1 }
-    r.RetentionDays = days
+	if days < 1 {
+		days = 1
+	}
+	r.RetentionDays = days
 }
 
 // разовая чистка: удаляет старые неактивные записи
 func (r *Registry) cleanupOldMutes() (int64, error) {
-    if r.DB == nil { return 0, nil }
-    ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-    defer cancel()
-    // удаляем всё, что не active и старше RetentionDays
-    cmd, err := r.DB.Exec(ctx, `
-        DELETE FROM gosha.mutes
-        WHERE status <> 'active'
-          AND end_at < now() - ($1 * interval '1 day')`,
-        r.RetentionDays,
-    )
-    if err != nil { return 0, err }
-    return cmd.RowsAffected(), nil
+	if r.Store == nil {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return r.Store.Cleanup(ctx, r.RetentionDays)
 }
 
 // ежедневный тикер: запускает cleanup раз в сутки + один прогон на старте
 func (r *Registry) startDailyMaintenance() {
-    // первый прогон сразу
-    if n, err := r.cleanupOldMutes(); err != nil {
-        log.Println("[mute] cleanup error:", err)
-    } else if n > 0 {
-        log.Printf("[mute] cleanup removed %d old rows\n", n)
-    }
-
-    // затем — каждые 24 часа
-    go func() {
-        t := time.NewTicker(24 * time.Hour)
-        defer t.Stop()
-        for range t.C {
-            n, err := r.cleanupOldMutes()
-            if err != nil {
-                log.Println("[mute] daily cleanup error:", err)
-                continue
-            }
-            if n > 0 {
-                log.Printf("[mute] daily cleanup removed %d old rows\n", n)
-            }
-        }
-    }()
+	// первый прогон сразу
+	if n, err := r.cleanupOldMutes(); err != nil {
+		r.log.Error("cleanup old mutes", "err", err)
+	} else if n > 0 {
+		r.log.Info("old mutes cleaned up", "rows", n)
+	}
+
+	// затем — каждые 24 часа, пока не позвали Shutdown
+	r.maintenance.Add(1)
+	go func() {
+		defer r.maintenance.Done()
+		t := time.NewTicker(24 * time.Hour)
+		defer t.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-t.C:
+			}
+			n, err := r.cleanupOldMutes()
+			if err != nil {
+				r.log.Error("daily cleanup of old mutes", "err", err)
+				continue
+			}
+			if n > 0 {
+				r.log.Info("old mutes cleaned up", "rows", n)
+			}
+		}
+	}()
 }
 
-
 // ---------- embeds / utils ----------
 
-func (r *Registry) logEmbed(title string, target, moderator *discordgo.User, reason string, color int) {
-	if r.LogChannelID == "" {
-		log.Println("[mute]", title, "->", userTag(target), "by", userTag(moderator), "reason:", reason)
+// titleKey — ключ каталога i18n; язык — язык сервера
+func (r *Registry) logEmbed(guildID, titleKey string, target, moderator *discordgo.User, reason string, color int) {
+	lang := i18n.ForGuild(guildID)
+	title := i18n.T(lang, titleKey)
+	logChannelID := r.logChannelID(guildID)
+	if logChannelID == "" {
+		r.log.Info(title, "guild", guildID, "user", target.ID, "moderator", moderator.ID, "reason", reason)
 		return
 	}
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: mentionUser(target.ID), Inline: true},
-		{Name: "Модератор", Value: mentionUser(moderator.ID), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: mentionUser(target.ID), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: mentionUser(moderator.ID), Inline: true},
 	}
 	if strings.TrimSpace(reason) != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
 	}
 	embed := &discordgo.MessageEmbed{
 		Title:     title,
@@ -423,23 +596,30 @@ func (r *Registry) logEmbed(title string, target, moderator *discordgo.User, rea
 		Fields:    fields,
 		Footer:    &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04"))},
 	}
-	_, _ = r.s.ChannelMessageSendEmbed(r.LogChannelID, embed)
+	if _, err := r.s.ChannelMessageSendEmbed(logChannelID, embed); err != nil {
+		r.log.Warn("send log embed", "guild", guildID, "channel", logChannelID, "err", err)
+	}
 }
 
-func (r *Registry) logEmbedMute(title string, target, moderator *discordgo.User, reason string, minutes int, color int) {
-	extra := fmt.Sprintf("%d мин.", minutes)
-	if minutes <= 0 { extra = "не задано" }
-	if r.LogChannelID == "" {
-		log.Println("[mute]", title, "->", userTag(target), "by", userTag(moderator), "reason:", reason, "minutes:", minutes)
+func (r *Registry) logEmbedMute(guildID, titleKey string, target, moderator *discordgo.User, reason string, minutes int, color int) {
+	lang := i18n.ForGuild(guildID)
+	title := i18n.T(lang, titleKey)
+	extra := i18n.T(lang, "common.minutes", minutes)
+	if minutes <= 0 {
+		extra = i18n.T(lang, "common.not_set")
+	}
+	logChannelID := r.logChannelID(guildID)
+	if logChannelID == "" {
+		r.log.Info(title, "guild", guildID, "user", target.ID, "moderator", moderator.ID, "reason", reason, "minutes", minutes)
 		return
 	}
 	fields := []*discordgo.MessageEmbedField{
-		{Name: "Пользователь", Value: mentionUser(target.ID), Inline: true},
-		{Name: "Модератор", Value: mentionUser(moderator.ID), Inline: true},
-		{Name: "Длительность", Value: code(extra), Inline: true},
+		{Name: i18n.T(lang, "field.user"), Value: mentionUser(target.ID), Inline: true},
+		{Name: i18n.T(lang, "field.moderator"), Value: mentionUser(moderator.ID), Inline: true},
+		{Name: i18n.T(lang, "field.duration"), Value: code(extra), Inline: true},
 	}
 	if strings.TrimSpace(reason) != "" {
-		fields = append(fields, &discordgo.MessageEmbedField{Name: "Причина", Value: code(reason)})
+		fields = append(fields, &discordgo.MessageEmbedField{Name: i18n.T(lang, "field.reason"), Value: code(reason)})
 	}
 	embed := &discordgo.MessageEmbed{
 		Title:     title,
@@ -448,48 +628,64 @@ func (r *Registry) logEmbedMute(title string, target, moderator *discordgo.User,
 		Fields:    fields,
 		Footer:    &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("ID: %s • %s", target.ID, time.Now().Format("02.01.2006 15:04"))},
 	}
-	_, _ = r.s.ChannelMessageSendEmbed(r.LogChannelID, embed)
+	if _, err := r.s.ChannelMessageSendEmbed(logChannelID, embed); err != nil {
+		r.log.Warn("send log embed", "guild", guildID, "channel", logChannelID, "err", err)
+	}
 }
 
 func (r *Registry) AttachLogger(l *adminlog.Logger) { r.AdminLog = l }
 
 func userTag(u *discordgo.User) string {
-	if u == nil { return "—" }
+	if u == nil {
+		return "—"
+	}
 	return fmt.Sprintf("<@%s> (%s)", u.ID, u.Username)
 }
 func mentionUser(id string) string { return "<@" + id + ">" }
-func code(s string) string { return "`" + s + "`" }
+func code(s string) string         { return "`" + s + "`" }
 func avatarURL(u *discordgo.User) string {
-	if u == nil { return "" }
-	if u.Avatar != "" { return fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png?size=256", u.ID, u.Avatar) }
-	return "https://cdn.discordapp.com/embed/avatars/0.png"
-}
-func botIDSafe(s *discordgo.Session) (string, error) {
-	if s.State != nil && s.State.User != nil && s.State.User.ID != "" {
-		return s.State.User.ID, nil
+	if u == nil {
+		return ""
 	}
-	me, err := s.User("@me")
-	if err != nil {
-		return "", err
+	if u.Avatar != "" {
+		return fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png?size=256", u.ID, u.Avatar)
 	}
-	return me.ID, nil
+	return "https://cdn.discordapp.com/embed/avatars/0.png"
 }
 
 // быстрый ACK, чтобы Discord не писал "приложение не отвечает"
-func ackEphemeral(s *discordgo.Session, ic *discordgo.InteractionCreate) {
-	_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+func (r *Registry) ackEphemeral(ic *discordgo.InteractionCreate) {
+	err := r.s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Flags: discordgo.MessageFlagsEphemeral,
 		},
 	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("ack", "err", err)
+	}
 }
 
 // редактируем уже отправленный deferred-ответ
-func editReply(s *discordgo.Session, ic *discordgo.InteractionCreate, msg string) {
-	_, _ = s.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{
+func (r *Registry) editReply(ic *discordgo.InteractionCreate, msg string) {
+	_, err := r.s.InteractionResponseEdit(ic.Interaction, &discordgo.WebhookEdit{
 		Content: &msg,
 	})
+	if err != nil {
+		logging.Interaction(r.log, ic).Warn("edit reply", "err", err)
+	}
 }
 
-
+// rollback возвращает снятые роли (и снимает мут-роль, если она уже выдана),
+// когда мут не удалось довести до конца. Ошибки только логируем — пользователю уже ответили.
+func (r *Registry) rollback(ic *discordgo.InteractionCreate, userID string, removed []string, dropMuteRole bool) {
+	l := logging.Interaction(r.log, ic).With("target", userID)
+	if dropMuteRole {
+		if err := r.removeMutedRole(ic.GuildID, userID); err != nil {
+			l.Error("rollback: remove mute role", "err", err)
+		}
+	}
+	if err := r.restoreRoles(ic.GuildID, userID, removed); err != nil {
+		l.Error("rollback: restore roles", "err", err)
+	}
+}
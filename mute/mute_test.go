@@ -0,0 +1,237 @@
+package mute
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gosha_bot/discord/discordtest"
+	"gosha_bot/guildcfg"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	guildID  = "g1"
+	botID    = "bot"
+	modID    = "mod"
+	targetID = "u1"
+	muteRole = "muted"
+)
+
+type fakeStore struct {
+	rows      map[string][]string  // guild:user → снятые роли активного мута
+	ends      map[string]time.Time // guild:user → end_at
+	insertErr error
+	completed chan int64 // сигнал для тестов с таймерами
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		rows:      make(map[string][]string),
+		ends:      make(map[string]time.Time),
+		completed: make(chan int64, 10),
+	}
+}
+
+func (f *fakeStore) HasActive(_ context.Context, g, u string) (bool, error) {
+	_, ok := f.rows[g+":"+u]
+	return ok, nil
+}
+
+func (f *fakeStore) Insert(_ context.Context, g, u, _, _ string, _ int, removed []string) error {
+	if f.insertErr != nil {
+		return f.insertErr
+	}
+	f.rows[g+":"+u] = removed
+	return nil
+}
+
+func (f *fakeStore) Active(_ context.Context, g, u string) (int64, []string, error) {
+	roles, ok := f.rows[g+":"+u]
+	if !ok {
+		return 0, nil, errors.New("no rows")
+	}
+	return 1, roles, nil
+}
+
+func (f *fakeStore) Complete(_ context.Context, id int64) error {
+	f.completed <- id
+	return nil
+}
+
+func (f *fakeStore) Cleanup(context.Context, int) (int64, error) { return 0, nil }
+func (f *fakeStore) CountActive(context.Context) (int64, error)  { return int64(len(f.rows)), nil }
+
+func (f *fakeStore) ListActive(context.Context) ([]ActiveMute, error) {
+	var out []ActiveMute
+	for k, end := range f.ends {
+		g, u, _ := strings.Cut(k, ":")
+		out = append(out, ActiveMute{GuildID: g, UserID: u, EndAt: end})
+	}
+	return out, nil
+}
+
+// сервер: бот выше всех, у цели две обычные роли
+func setup(t *testing.T) (*Registry, *discordtest.Fake, *fakeStore) {
+	t.Helper()
+	fake := discordtest.New(botID)
+	fake.AddRole(guildID, "botrole", 10)
+	fake.AddRole(guildID, muteRole, 5)
+	fake.AddRole(guildID, "a", 2)
+	fake.AddRole(guildID, "b", 3)
+	fake.AddMember(guildID, botID, "botrole")
+	fake.AddMember(guildID, modID)
+	fake.AddMember(guildID, targetID, "a", "b")
+	fake.SetPermissions(modID, discordgo.PermissionManageRoles)
+
+	guilds := guildcfg.NewStore(nil)
+	if err := guilds.Apply(context.Background(), guildcfg.Guild{GuildID: guildID, MuteRoleID: muteRole}); err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeStore()
+	r := newRegistry(fake, guilds, store)
+	t.Cleanup(func() {
+		r.muTimers.Lock()
+		defer r.muTimers.Unlock()
+		for _, tm := range r.unmuteTimers {
+			tm.Stop()
+		}
+	})
+	return r, fake, store
+}
+
+func muteInteraction(minutes int64) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		ID:        "ic1",
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   guildID,
+		ChannelID: "c1",
+		Member:    &discordgo.Member{User: &discordgo.User{ID: modID}},
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: "mute",
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{
+				{Name: "user", Type: discordgo.ApplicationCommandOptionUser, Value: targetID},
+				{Name: "minutes", Type: discordgo.ApplicationCommandOptionInteger, Value: float64(minutes)},
+				{Name: "reason", Type: discordgo.ApplicationCommandOptionString, Value: "spam"},
+			},
+		},
+	}}
+}
+
+func TestHandleMuteSuccess(t *testing.T) {
+	r, fake, store := setup(t)
+
+	r.handleMute(muteInteraction(10))
+
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{muteRole}) {
+		t.Fatalf("roles after mute = %v, want only %s", got, muteRole)
+	}
+	if got := store.rows[guildID+":"+targetID]; len(got) != 2 {
+		t.Fatalf("stored removed roles = %v, want 2", got)
+	}
+	if !strings.Contains(fake.LastEdit(), "Мут выдан") {
+		t.Fatalf("reply = %q", fake.LastEdit())
+	}
+}
+
+func TestHandleMuteRollsBackWhenMuteRoleFails(t *testing.T) {
+	r, fake, store := setup(t)
+	fake.Fail("GuildMemberRoleAdd", muteRole, errors.New("missing access"))
+
+	r.handleMute(muteInteraction(10))
+
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("roles after failed mute = %v, want [a b]", got)
+	}
+	if len(store.rows) != 0 {
+		t.Fatalf("mute row must not be stored: %v", store.rows)
+	}
+	if !strings.Contains(fake.LastEdit(), "Не смог выдать мут") {
+		t.Fatalf("reply = %q", fake.LastEdit())
+	}
+}
+
+func TestHandleMuteRollsBackWhenInsertFails(t *testing.T) {
+	r, fake, store := setup(t)
+	store.insertErr = errors.New("db down")
+
+	r.handleMute(muteInteraction(10))
+
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("roles after failed insert = %v, want [a b]", got)
+	}
+	if !strings.Contains(fake.LastEdit(), "DB insert") {
+		t.Fatalf("reply = %q", fake.LastEdit())
+	}
+	if len(r.unmuteTimers) != 0 {
+		t.Fatal("no unmute timer expected after rollback")
+	}
+}
+
+func TestHandleMuteRollsBackPartiallyDroppedRoles(t *testing.T) {
+	r, fake, _ := setup(t)
+	fake.Fail("GuildMemberRoleRemove", "b", errors.New("role above bot"))
+
+	r.handleMute(muteInteraction(10))
+
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("roles after failed drop = %v, want [a b]", got)
+	}
+	if fake.CallCount("GuildMemberRoleAdd") != 1 {
+		t.Fatalf("expected exactly one restore call, got %d", fake.CallCount("GuildMemberRoleAdd"))
+	}
+}
+
+func TestHandleMuteRejectsTargetAboveBot(t *testing.T) {
+	r, fake, _ := setup(t)
+	fake.AddRole(guildID, "admin", 20)
+	fake.AddMember(guildID, targetID, "admin")
+
+	r.handleMute(muteInteraction(10))
+
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{"admin"}) {
+		t.Fatalf("roles must stay untouched, got %v", got)
+	}
+	if !strings.Contains(fake.LastEdit(), "иерархии") {
+		t.Fatalf("reply = %q", fake.LastEdit())
+	}
+}
+
+func TestRestoreTimersUnmutesExpiredImmediately(t *testing.T) {
+	r, fake, store := setup(t)
+	// мут выдан до рестарта и истёк, пока бот лежал
+	fake.AddMember(guildID, targetID, muteRole)
+	store.rows[guildID+":"+targetID] = []string{"a", "b"}
+	store.ends[guildID+":"+targetID] = time.Now().Add(-time.Minute)
+
+	r.restoreTimers()
+
+	select {
+	case <-store.completed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expired mute was not lifted")
+	}
+	if got := fake.MemberRoles(guildID, targetID); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("roles after restore = %v, want [a b]", got)
+	}
+}
+
+func TestShutdownStopsPendingTimers(t *testing.T) {
+	r, _, store := setup(t)
+	store.ends[guildID+":"+targetID] = time.Now().Add(time.Hour)
+	r.restoreTimers()
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(r.unmuteTimers); n != 0 {
+		t.Fatalf("%d timers left after shutdown", n)
+	}
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal("second shutdown:", err)
+	}
+}
@@ -0,0 +1,115 @@
+package mute
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Store — хранилище мутов (таблица gosha.mutes).
+type Store interface {
+	HasActive(ctx context.Context, guildID, userID string) (bool, error)
+	Insert(ctx context.Context, guildID, userID, moderatorID, reason string, minutes int, removedRoles []string) error
+	Active(ctx context.Context, guildID, userID string) (id int64, roles []string, err error)
+	Complete(ctx context.Context, id int64) error
+	Cleanup(ctx context.Context, retentionDays int) (int64, error)
+	CountActive(ctx context.Context) (int64, error)
+	ListActive(ctx context.Context) ([]ActiveMute, error)
+}
+
+// ActiveMute — активный мут и когда его снять.
+type ActiveMute struct {
+	GuildID string
+	UserID  string
+	EndAt   time.Time
+}
+
+type pgStore struct {
+	db *pgxpool.Pool
+}
+
+// NewPGStore — Store поверх Postgres.
+func NewPGStore(db *pgxpool.Pool) Store { return &pgStore{db: db} }
+
+func (p *pgStore) HasActive(ctx context.Context, guildID, userID string) (bool, error) {
+	var exists bool
+	err := p.db.QueryRow(ctx,
+		`SELECT EXISTS(SELECT 1 FROM gosha.mutes WHERE guild_id=$1 AND user_id=$2 AND status='active')`,
+		guildID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (p *pgStore) Insert(ctx context.Context, guildID, userID, moderatorID, reason string, minutes int, removedRoles []string) error {
+	b, _ := json.Marshal(removedRoles)
+	endAt := time.Now().Add(time.Duration(minutes) * time.Minute)
+	_, err := p.db.Exec(ctx, `INSERT INTO gosha.mutes (guild_id, user_id, moderator_id, reason, end_at, duration_minutes, roles_removed, status)
+ VALUES ($1,$2,$3,$4,$5,$6,$7,'active')`,
+		guildID, userID, moderatorID, reason, endAt, minutes, b,
+	)
+	return err
+}
+
+func (p *pgStore) Active(ctx context.Context, guildID, userID string) (id int64, roles []string, err error) {
+	var jb []byte
+	err = p.db.QueryRow(ctx,
+		`SELECT id, roles_removed FROM gosha.mutes
+ WHERE guild_id=$1 AND user_id=$2 AND status='active'
+ ORDER BY id DESC LIMIT 1`,
+		guildID, userID).Scan(&id, &jb)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(jb) > 0 {
+		_ = json.Unmarshal(jb, &roles)
+	}
+	return id, roles, nil
+}
+
+func (p *pgStore) Complete(ctx context.Context, id int64) error {
+	_, err := p.db.Exec(ctx, `UPDATE gosha.mutes
+   SET status='completed', unmuted_at=now(), restored_count=restored_count+1
+ WHERE id=$1 AND status='active'`, id)
+	return err
+}
+
+// удаляем всё, что не active и старше retentionDays
+func (p *pgStore) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	cmd, err := p.db.Exec(ctx, `
+        DELETE FROM gosha.mutes
+        WHERE status <> 'active'
+          AND end_at < now() - ($1 * interval '1 day')`,
+		retentionDays,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// активные муты по всем серверам (для метрик)
+func (p *pgStore) CountActive(ctx context.Context) (int64, error) {
+	var n int64
+	err := p.db.QueryRow(ctx, `SELECT count(*) FROM gosha.mutes WHERE status='active'`).Scan(&n)
+	return n, err
+}
+
+// все активные муты — чтобы после рестарта заново поставить таймеры авто-размута
+func (p *pgStore) ListActive(ctx context.Context) ([]ActiveMute, error) {
+	rows, err := p.db.Query(ctx, `SELECT guild_id, user_id, end_at FROM gosha.mutes WHERE status='active'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ActiveMute
+	for rows.Next() {
+		var m ActiveMute
+		if err := rows.Scan(&m.GuildID, &m.UserID, &m.EndAt); err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
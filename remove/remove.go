@@ -1,47 +1,51 @@
 package remove
 
 import (
-	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"slices"
 	"strings"
 
 	"gosha_bot/adminlog"
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
 
 	"github.com/bwmarrin/discordgo"
-	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 const CommandName = "remove"
 
 type Registry struct {
-	guildID          string
-	s                *discordgo.Session
-	adminRoleIDs     []string                    // кто может вызывать /remove
-	protectedRoleIDs map[string]struct{}         // роли, которые нельзя снимать (level-ролы)
-	DB               *pgxpool.Pool               // если не nil — подгружает protected из БД
-	AdminLog         *adminlog.Logger            // опционально
+	s                discord.Session
+	adminRoleIDs     []string            // кто может вызывать /remove
+	protectedRoleIDs map[string]struct{} // роли, которые нельзя снимать (из конфига)
+	Guilds           *guildcfg.Store     // роли за уровни (level_tiers) тоже защищены
+	AdminLog         *adminlog.Logger    // опционально
+	log              *slog.Logger
 }
 
 // Register регистрирует команду и настраивает обработчики.
 // adminRoleIDs — список ролей, которым разрешено снимать роли.
-// protectedRoleIDs — дополнительно защищённые роли (если DB=nil, используется этот список).
+// protectedRoleIDs — дополнительно защищённые роли; роли за уровни берутся из guilds.
 func Register(
 	s *discordgo.Session,
-	guildID string,
+	router *commands.Router,
+	guilds *guildcfg.Store,
 	adminRoleIDs []string,
 	protectedRoleIDs []string,
-	db *pgxpool.Pool,
 	logger *adminlog.Logger,
+	log *slog.Logger,
 ) (*Registry, error) {
 	r := &Registry{
-		guildID:          guildID,
 		s:                s,
 		adminRoleIDs:     dedup(adminRoleIDs),
 		protectedRoleIDs: make(map[string]struct{}),
-		DB:               db,
+		Guilds:           guilds,
 		AdminLog:         logger,
+		log:              log,
 	}
 
 	// заполнить защищённые роли из .env
@@ -51,13 +55,6 @@ func Register(
 			r.protectedRoleIDs[id] = struct{}{}
 		}
 	}
-	// если есть БД — подтянуть level-роли (roles.role_id); логируем warn при ошибке и идём дальше
-	if r.DB != nil {
-		if err := r.ReloadProtectedRolesFromDB(context.Background()); err != nil {
-			log.Println("[remove] warn: failed to load level roles from DB:", err)
-		}
-	}
-
 	// описываем слэш-команду
 	cmd := &discordgo.ApplicationCommand{
 		Name:        CommandName,
@@ -70,49 +67,36 @@ func Register(
 		},
 	}
 
-	// Регистрируем команду, когда сессия полностью готова (есть rdy.User.ID)
-	s.AddHandlerOnce(func(s *discordgo.Session, rdy *discordgo.Ready) {
-		if _, err := s.ApplicationCommandCreate(rdy.User.ID, guildID, cmd); err != nil {
-			log.Println("[remove] create cmd error:", err)
-		} else {
-			log.Println("[remove] /remove registered")
-		}
-	})
-
-	// обработчик интеракций можно вешать сразу
-	s.AddHandler(r.onInteraction)
+	// команду на серверах синхронизирует роутер
+	router.Add(cmd, r.onInteraction)
 
 	return r, nil
 }
 
-
-// ReloadProtectedRolesFromDB подтягивает роли из таблицы roles (role_id), чтобы нельзя было их снимать.
-func (r *Registry) ReloadProtectedRolesFromDB(ctx context.Context) error {
-	return nil
-}
-
 func (r *Registry) onInteraction(s *discordgo.Session, ev *discordgo.InteractionCreate) {
 	ic := ev.Interaction
-	if ic.Type != discordgo.InteractionApplicationCommand || ic.GuildID != r.guildID {
-		return
-	}
-	if ic.ApplicationCommandData().Name != CommandName {
+	if ic.Type != discordgo.InteractionApplicationCommand || ic.GuildID == "" {
 		return
 	}
 
 	// Ответим сразу, чтобы Discord не ждал (ephemeral).
-	_ = s.InteractionRespond(ic, &discordgo.InteractionResponse{
+	lg := logging.Interaction(r.log, ev)
+	if err := s.InteractionRespond(ic, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{Flags: 1 << 6}, // ephemeral
-	})
+	}); err != nil {
+		lg.Warn("ack", "err", err)
+	}
+
+	lang := i18n.For(ic)
 
 	// Валидации
 	if ic.Member == nil {
-		r.followup(ic, "Команда доступна только внутри сервера.")
+		r.followup(ic, i18n.T(lang, "common.guild_only"))
 		return
 	}
 	if !r.isAdmin(ic.Member) {
-		r.followup(ic, "⛔ Команда доступна только администраторам.")
+		r.followup(ic, i18n.T(lang, "common.admin_only"))
 		return
 	}
 
@@ -132,57 +116,54 @@ func (r *Registry) onInteraction(s *discordgo.Session, ev *discordgo.Interaction
 		}
 	}
 	if targetUser == nil || role == nil {
-		r.followup(ic, "Не удалось прочитать параметры (user/role).")
+		r.followup(ic, i18n.T(lang, "remove.bad_args"))
 		return
 	}
 
 	// Нельзя снимать защищённые (уровневые) роли
-	if r.isProtected(role.ID) {
-		r.followup(ic, "🔒 Нельзя снимать уровневые роли.")
+	if r.isProtected(ic.GuildID, role.ID) {
+		r.followup(ic, i18n.T(lang, "remove.protected"))
 		return
 	}
 
 	// Проверим иерархию: роль должна быть ниже самой высокой роли бота
-	ok, err := r.botHigherThan(role.ID)
+	ok, err := r.botHigherThan(ic.GuildID, role.ID)
 	if err != nil {
-		log.Println("[remove] botHigherThan error:", err)
-		r.followup(ic, "Ошибка проверки иерархии ролей.")
+		lg.Error("check role hierarchy", "role", role.ID, "err", err)
+		r.followup(ic, i18n.T(lang, "remove.hierarchy_error"))
 		return
 	}
 	if !ok {
-		r.followup(ic, "⛔ Нельзя снимать роль, которая не ниже роли бота по иерархии.")
+		r.followup(ic, i18n.T(lang, "remove.above_bot"))
 		return
 	}
 
 	// Проверим, есть ли у пользователя эта роль
 	member, err := s.GuildMember(ic.GuildID, targetUser.ID)
 	if err != nil {
-		r.followup(ic, "Не удалось получить участника.")
+		r.followup(ic, i18n.T(lang, "remove.member_failed"))
 		return
 	}
 	if !slices.Contains(member.Roles, role.ID) {
-		r.followup(ic, "У пользователя нет этой роли.")
+		r.followup(ic, i18n.T(lang, "remove.no_role"))
 		return
 	}
 
 	// Попробуем снять
 	if err := s.GuildMemberRoleRemove(ic.GuildID, targetUser.ID, role.ID); err != nil {
-		log.Println("[remove] remove error:", err)
-		r.followup(ic, "Не удалось снять роль. У бота достаточно прав и включён ли `Manage Roles`?")
+		lg.Error("remove role", "target", targetUser.ID, "role", role.ID, "err", err)
+		r.followup(ic, i18n.T(lang, "remove.failed"))
 		return
 	}
 
-	// Лог
-	if r.AdminLog != nil {
-		r.logRemoval(
-    fmt.Sprintf(
-        "Пользователь: <@%s>\nРоль: <@&%s>\nМодератор: <@%s>\nПричина: %s",
-        targetUser.ID, role.ID, ic.Member.User.ID, emptyIf(reason, "—"),
-    ),
-)
+	// Лог (без AdminLog logRemoval пишет в стандартный лог)
+	glang := i18n.ForGuild(ic.GuildID)
+	r.logRemoval(
+		i18n.T(glang, "remove.log_title"),
+		i18n.T(glang, "remove.log_body", targetUser.ID, role.ID, ic.Member.User.ID, emptyIf(reason, i18n.T(glang, "common.dash"))),
+	)
 
-	r.followup(ic, fmt.Sprintf("✅ Роль <@&%s> снята с <@%s>.", role.ID, targetUser.ID))
-}
+	r.followup(ic, i18n.T(lang, "remove.done", role.ID, targetUser.ID))
 }
 
 func (r *Registry) isAdmin(m *discordgo.Member) bool {
@@ -202,15 +183,18 @@ func (r *Registry) isAdmin(m *discordgo.Member) bool {
 	return false
 }
 
-func (r *Registry) isProtected(roleID string) bool {
-	_, ok := r.protectedRoleIDs[roleID]
-	return ok
+func (r *Registry) isProtected(guildID, roleID string) bool {
+	if _, ok := r.protectedRoleIDs[roleID]; ok {
+		return true
+	}
+	// ступени /levelroles меняются на лету — смотрим в кэш настроек сервера
+	return r.Guilds != nil && r.Guilds.IsTierRole(guildID, roleID)
 }
 
 // botHigherThan проверяет, что целевая роль ниже самой высокой роли бота.
-func (r *Registry) botHigherThan(targetRoleID string) (bool, error) {
+func (r *Registry) botHigherThan(guildID, targetRoleID string) (bool, error) {
 	// Получим роли сервера
-	roles, err := r.s.GuildRoles(r.guildID)
+	roles, err := r.s.GuildRoles(guildID)
 	if err != nil {
 		return false, err
 	}
@@ -220,8 +204,11 @@ func (r *Registry) botHigherThan(targetRoleID string) (bool, error) {
 	}
 
 	// Получим участника-бота на сервере
-	appID := r.s.State.User.ID
-	botMember, err := r.s.GuildMember(r.guildID, appID)
+	appID, err := discord.BotID(r.s)
+	if err != nil {
+		return false, err
+	}
+	botMember, err := r.s.GuildMember(guildID, appID)
 	if err != nil {
 		return false, err
 	}
@@ -259,34 +246,38 @@ type loggerWithInfof interface {
 }
 
 // logRemoval отправляет запись в adminlog, поддерживая разные API логгера.
-// Если подходящего метода нет — пишет в стандартный лог.
-func (r *Registry) logRemoval(text string) {
-	const title = "Снятие роли"
-
+// Если подходящего метода нет — пишет в журнал модуля.
+func (r *Registry) logRemoval(title, text string) {
 	if r.AdminLog == nil {
-		log.Println("[remove]", title+"\n"+text)
+		r.log.Info(title, "text", text)
 		return
 	}
 
+	var err error
 	switch l := any(r.AdminLog).(type) {
 	case loggerWithPostSimplef:
-		_ = l.PostSimplef(title, "%s", text)
+		err = l.PostSimplef(title, "%s", text)
 	case loggerWithPost:
-		_ = l.Post(title, text)
+		err = l.Post(title, text)
 	case loggerWithPrintf:
 		l.Printf("%s\n%s", title, text)
 	case loggerWithInfof:
 		l.Infof("%s\n%s", title, text)
 	default:
-		log.Println("[remove]", title+"\n"+text)
+		r.log.Info(title, "text", text)
+	}
+	if err != nil {
+		r.log.Warn("post to admin log", "err", err)
 	}
 }
 
-
 func (r *Registry) followup(ic *discordgo.Interaction, content string) {
-	_, _ = r.s.FollowupMessageCreate(ic, true, &discordgo.WebhookParams{
+	_, err := r.s.FollowupMessageCreate(ic, true, &discordgo.WebhookParams{
 		Content: content,
 	})
+	if err != nil {
+		logging.Interaction(r.log, &discordgo.InteractionCreate{Interaction: ic}).Warn("followup", "err", err)
+	}
 }
 
 func dedup(in []string) []string {
@@ -1,50 +1,73 @@
 package selfrole
 
 import (
-	"log"
-	"os"
+	"log/slog"
+
+	"gosha_bot/commands"
+	"gosha_bot/discord"
+	"gosha_bot/guildcfg"
+	"gosha_bot/i18n"
+	"gosha_bot/logging"
 
 	"github.com/bwmarrin/discordgo"
 )
 
-var (
-	guildID          string
-	welcomeChannelID string
-	selfRoleID       string
-)
+// настройки (welcome-канал и роль) берутся для каждого сервера из guildcfg
+var guilds *guildcfg.Store
 
-const btnID = "selfrole:grant"
+// журнал модуля; задаётся в Init
+var logger = logging.Discard()
 
-func Init(s *discordgo.Session) error {
-	// читаем env
-	guildID = os.Getenv("GUILD_ID")
-	welcomeChannelID = os.Getenv("WELCOME_CHANNEL_ID")
-	selfRoleID = os.Getenv("SELF_ROLE_ID")
+const btnID = "selfrole:grant"
 
-	if guildID == "" || welcomeChannelID == "" || selfRoleID == "" {
-		return ErrEnvNotSet
+func Init(s *discordgo.Session, router *commands.Router, store *guildcfg.Store, log *slog.Logger) error {
+	if store == nil {
+		return ErrNotConfigured
 	}
+	guilds = store
+	logger = log
 
 	// хэндлеры
 	s.AddHandler(onMemberJoin)
-	s.AddHandler(onButton)
+	router.Component(btnID, onButton)
+
+	// служебная команда: прислать приветствие себе ещё раз
+	adminPerm := int64(discordgo.PermissionAdministrator)
+	router.Add(&discordgo.ApplicationCommand{
+		Name:                     "welcome",
+		Description:              "Отправить приветствие с кнопкой выдачи роли",
+		DefaultMemberPermissions: &adminPerm,
+	}, onWelcomeCommand)
 
 	return nil
 }
 
-var ErrEnvNotSet = &envErr{"GUILD_ID / WELCOME_CHANNEL_ID / SELF_ROLE_ID must be set"}
+var ErrNotConfigured = &envErr{"selfrole: guild settings store is nil"}
+
+// ErrGuildNotSet — у сервера не заданы welcome_channel_id / self_role_id.
+var ErrGuildNotSet = &envErr{"welcome_channel_id / self_role_id must be set for the guild"}
 
 type envErr struct{ msg string }
+
 func (e *envErr) Error() string { return e.msg }
 
+// настройки сервера, если selfrole на нём включён
+func settings(guildID string) (welcomeChannelID, selfRoleID string, ok bool) {
+	cfg := guilds.Get(guildID)
+	if cfg == nil || cfg.WelcomeChannelID == "" || cfg.SelfRoleID == "" {
+		return "", "", false
+	}
+	return cfg.WelcomeChannelID, cfg.SelfRoleID, true
+}
+
 // Отправляем сообщение с кнопкой при входе участника
 func onMemberJoin(s *discordgo.Session, e *discordgo.GuildMemberAdd) {
-	if e.GuildID != guildID{
+	if _, _, ok := settings(e.GuildID); !ok {
 		return
 	}
 
-	if err:= SendWelcome(s, e.User.ID); err!=nil{
-		log.Println("[selfrole] welcome send error:", err)
+	if err := SendWelcome(s, e.GuildID, e.User.ID); err != nil {
+		logger.Warn("send welcome", "guild", e.GuildID, "user", e.User.ID, "err", err)
 	}
 }
 
@@ -53,66 +76,85 @@ func onButton(s *discordgo.Session, i *discordgo.InteractionCreate) {
 	if i.Type != discordgo.InteractionMessageComponent {
 		return
 	}
-	if i.MessageComponentData().CustomID != btnID {
-		return
-	}
-	if i.GuildID != guildID {
+	_, selfRoleID, ok := settings(i.GuildID)
+	if !ok {
 		return
 	}
 
 	userID := i.Member.User.ID
 
 	// Добавляем роль (идемпотентно — Discord просто вернёт 204/ошибку, если уже есть)
-	err := s.GuildMemberRoleAdd(guildID, userID, selfRoleID)
+	err := s.GuildMemberRoleAdd(i.GuildID, userID, selfRoleID)
 	if err != nil {
-		log.Println("[selfrole] add role error:", err)
-		_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Не смог выдать роль. Проверьте права бота и порядок ролей.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
-		})
+		logging.Interaction(logger, i).Error("grant self role", "role", selfRoleID, "err", err)
+		respond(s, i, i18n.T(i18n.For(i.Interaction), "selfrole.add_failed"))
 		return
 	}
 
 	// Эфемерное подтверждение
-	_ = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+	respond(s, i, i18n.T(i18n.For(i.Interaction), "selfrole.granted"))
+}
+
+// /welcome (служебная)
+func onWelcomeCommand(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Member == nil {
+		return
+	}
+	if err := SendWelcome(s, ic.GuildID, ic.Member.User.ID); err != nil {
+		logging.Interaction(logger, ic).Warn("send welcome", "err", err)
+	}
+	respond(s, ic, i18n.T(i18n.For(ic.Interaction), "selfrole.sent"))
+}
+
+// эфемерный ответ на интеракцию
+func respond(s *discordgo.Session, ic *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Готово! Роль выдана.",
+			Content: content,
 			Flags:   discordgo.MessageFlagsEphemeral,
 		},
 	})
+	if err != nil {
+		logging.Interaction(logger, ic).Warn("respond", "err", err)
+	}
 }
 
-func SendWelcome(s *discordgo.Session, userID string) error {
+func SendWelcome(s discord.Session, guildID, userID string) error {
+	welcomeChannelID, _, ok := settings(guildID)
+	if !ok {
+		return ErrGuildNotSet
+	}
+
+	// новичок ещё ничего не нажимал — пишем на языке сервера
+	lang := i18n.ForGuild(guildID)
+
 	//создаем приватный тред в велком канале
-	th,err:= s.ThreadStartComplex(welcomeChannelID,&discordgo.ThreadStart{
-		Name: "Приветствие"+ userID,
+	th, err := s.ThreadStartComplex(welcomeChannelID, &discordgo.ThreadStart{
+		Name:                i18n.T(lang, "selfrole.thread_name") + userID,
 		AutoArchiveDuration: 60, //архив через час
-		Type: discordgo.ChannelTypeGuildPrivateThread,
-		Invitable: false,
+		Type:                discordgo.ChannelTypeGuildPrivateThread,
+		Invitable:           false,
 	})
-	if err !=nil{
+	if err != nil {
 		return err
 	}
 
-	//добавляем юзера в тред 
-	if err:= s.ThreadMemberAdd(th.ID, userID); err!=nil{
+	//добавляем юзера в тред
+	if err := s.ThreadMemberAdd(th.ID, userID); err != nil {
 		return err
 	}
 
-	//отправляем кнопку 
-	_,err= s.ChannelMessageSendComplex(th.ID, &discordgo.MessageSend{
-		Content: "👋 Добро пожаловать! Нажми кнопку, чтобы получить роль.",
+	//отправляем кнопку
+	_, err = s.ChannelMessageSendComplex(th.ID, &discordgo.MessageSend{
+		Content: i18n.T(lang, "selfrole.welcome"),
 		Components: []discordgo.MessageComponent{
 			discordgo.ActionsRow{
 				Components: []discordgo.MessageComponent{
 					discordgo.Button{
 						CustomID: btnID,
-						Label:"Получить роль",
-						Style: discordgo.PrimaryButton,
+						Label:    i18n.T(lang, "selfrole.button"),
+						Style:    discordgo.PrimaryButton,
 					},
 				},
 			},
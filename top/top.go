@@ -2,20 +2,29 @@ package top
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"log/slog"
+
+	"gosha_bot/commands"
+	"gosha_bot/i18n"
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Registry struct {
-	DB *pgxpool.Pool
+	DB    *pgxpool.Pool
+	Curve levelcurve.Curve
+	log   *slog.Logger
 }
 
-func Register(s *discordgo.Session, db *pgxpool.Pool) *Registry {
-	r := &Registry{DB: db}
-	s.AddHandler(r.onInteractionCreate)
+func Register(router *commands.Router, db *pgxpool.Pool, curve levelcurve.Curve, log *slog.Logger) *Registry {
+	r := &Registry{DB: db, Curve: curve, log: log}
+	router.Add(&discordgo.ApplicationCommand{
+		Name:        "top",
+		Description: "Показать топ-10 пользователей по XP",
+	}, r.onInteractionCreate)
 	return r
 }
 
@@ -23,13 +32,19 @@ func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.Inter
 	if ic.Type != discordgo.InteractionApplicationCommand {
 		return
 	}
-	if ic.ApplicationCommandData().Name != "top" {
+	lang := i18n.For(ic.Interaction)
+	lg := logging.Interaction(r.log, ic)
+	if r.DB == nil {
+		r.respond(s, ic, lg, &discordgo.InteractionResponseData{
+			Content: i18n.T(lang, "top.no_db"),
+			Flags:   discordgo.MessageFlagsEphemeral,
+		})
 		return
 	}
 
 	// Достаём топ-10 по XP из users_levels
 	rows, err := r.DB.Query(context.Background(),
-		`SELECT user_id, xp, level
+		`SELECT user_id, xp
 		   FROM users_levels
 		  WHERE guild_id = $1
 		  ORDER BY xp DESC
@@ -37,13 +52,10 @@ func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.Inter
 		ic.GuildID,
 	)
 	if err != nil {
-		log.Println("[top] query:", err)
-		_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
-			Type: discordgo.InteractionResponseChannelMessageWithSource,
-			Data: &discordgo.InteractionResponseData{
-				Content: "Не удалось получить таблицу лидеров.",
-				Flags:   discordgo.MessageFlagsEphemeral,
-			},
+		lg.Error("query top", "err", err)
+		r.respond(s, ic, lg, &discordgo.InteractionResponseData{
+			Content: i18n.T(lang, "top.query_failed"),
+			Flags:   discordgo.MessageFlagsEphemeral,
 		})
 		return
 	}
@@ -53,25 +65,34 @@ func (r *Registry) onInteractionCreate(s *discordgo.Session, ic *discordgo.Inter
 	i := 1
 	for rows.Next() {
 		var userID string
-		var xp, level int64
-		if err := rows.Scan(&userID, &xp, &level); err != nil {
+		var xp int64
+		if err := rows.Scan(&userID, &xp); err != nil {
+			lg.Warn("scan top row", "err", err)
 			continue
 		}
-		desc += fmt.Sprintf("**%d.** <@%s> — %d XP (lvl %d)\n", i, userID, xp, level)
+		// уровень — по текущей кривой, колонка level могла отстать до /levelcurve recompute
+		desc += i18n.T(lang, "top.row", i, userID, xp, levelcurve.Level(r.Curve, xp)) + "\n"
 		i++
 	}
 	if desc == "" {
-		desc = "Пока пусто. Пиши в чат или сиди в войсе, чтобы зарабатывать XP!"
+		desc = i18n.T(lang, "top.empty")
 	}
 
 	embed := &discordgo.MessageEmbed{
-		Title:       "🏆 Топ-10 по XP",
+		Title:       i18n.T(lang, "top.title"),
 		Description: desc,
 		Color:       0xFFD700,
 	}
 
-	_ = s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+	r.respond(s, ic, lg, &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{embed}})
+}
+
+func (r *Registry) respond(s *discordgo.Session, ic *discordgo.InteractionCreate, lg *slog.Logger, data *discordgo.InteractionResponseData) {
+	err := s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
-		Data: &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{embed}},
+		Data: data,
 	})
+	if err != nil {
+		lg.Warn("respond", "err", err)
+	}
 }
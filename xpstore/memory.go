@@ -0,0 +1,175 @@
+package xpstore
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gosha_bot/levelcurve"
+)
+
+// Memory — Store в памяти с той же семантикой, что у Postgres. Для тестов.
+type Memory struct {
+	mu     sync.Mutex
+	curve  levelcurve.Curve
+	rows   map[[2]string]*memRow
+	ledger []Entry // старые → новые
+}
+
+type memRow struct {
+	xp        int64
+	level     int
+	lastMsg   time.Time
+	lastVoice time.Time
+	voice     int64
+	created   time.Time
+	decayed   time.Time
+}
+
+func NewMemory(curve levelcurve.Curve) *Memory {
+	return &Memory{curve: curve, rows: make(map[[2]string]*memRow)}
+}
+
+// Get — XP и уровень участника; false — строки нет.
+func (m *Memory) Get(guildID, userID string) (xp int64, level int, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row, ok := m.rows[[2]string{guildID, userID}]
+	if !ok {
+		return 0, 0, false
+	}
+	return row.xp, row.level, true
+}
+
+func (m *Memory) row(guildID, userID string) *memRow {
+	k := [2]string{guildID, userID}
+	row, ok := m.rows[k]
+	if !ok {
+		row = &memRow{level: 1, created: time.Now()}
+		m.rows[k] = row
+	}
+	return row
+}
+
+func (m *Memory) Add(_ context.Context, c Change) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.row(c.GuildID, c.UserID)
+	res := Result{XPBefore: row.xp, XP: row.xp, LevelBefore: row.level, Level: row.level}
+	if !c.MessageAt.IsZero() && !row.lastMsg.IsZero() && c.MessageAt.Sub(row.lastMsg) < c.Cooldown {
+		res.Skipped = true
+		return res, nil
+	}
+	row.xp += max(c.Delta, -row.xp)
+	row.level = levelcurve.Level(m.curve, row.xp)
+	row.voice += c.VoiceSec
+	if !c.MessageAt.IsZero() {
+		row.lastMsg = c.MessageAt
+	}
+	if c.Source == SourceVoice {
+		row.lastVoice = time.Now()
+	}
+	res.XP, res.Level = row.xp, row.level
+	m.appendLedger(c.GuildID, c.UserID, res, c.Meta)
+	return res, nil
+}
+
+func (m *Memory) Set(_ context.Context, guildID, userID string, xp int64, meta Meta) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	row := m.row(guildID, userID)
+	res := Result{XPBefore: row.xp, LevelBefore: row.level}
+	row.xp = max(xp, 0)
+	row.level = levelcurve.Level(m.curve, row.xp)
+	res.XP, res.Level = row.xp, row.level
+	m.appendLedger(guildID, userID, res, meta)
+	return res, nil
+}
+
+func (m *Memory) ResetAll(_ context.Context, guildID string, meta Meta) ([]MemberResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []MemberResult
+	for k, row := range m.rows {
+		if k[0] != guildID || row.xp == 0 {
+			continue
+		}
+		res := Result{XPBefore: row.xp, LevelBefore: row.level}
+		row.xp, row.level = 0, levelcurve.Level(m.curve, 0)
+		res.Level = row.level
+		m.appendLedger(guildID, k[1], res, meta)
+		out = append(out, MemberResult{UserID: k[1], Result: res})
+	}
+	slices.SortFunc(out, func(a, b MemberResult) int { return strings.Compare(a.UserID, b.UserID) })
+	return out, nil
+}
+
+func (m *Memory) Decay(_ context.Context, guildID string, d Decay, meta Meta) ([]MemberResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []MemberResult
+	for k, row := range m.rows {
+		active := row.created
+		if !row.lastMsg.IsZero() || !row.lastVoice.IsZero() {
+			active = row.lastMsg
+			if row.lastVoice.After(active) {
+				active = row.lastVoice
+			}
+		}
+		if k[0] != guildID || row.xp <= d.FloorXP || !active.Before(d.InactiveSince) ||
+			(!row.decayed.IsZero() && !row.decayed.Before(d.DecayedBefore)) {
+			continue
+		}
+		res := Result{XPBefore: row.xp, LevelBefore: row.level}
+		row.xp = d.apply(row.xp)
+		row.level = levelcurve.Level(m.curve, row.xp)
+		row.decayed = time.Now()
+		res.XP, res.Level = row.xp, row.level
+		m.appendLedger(guildID, k[1], res, meta)
+		out = append(out, MemberResult{UserID: k[1], Result: res})
+	}
+	slices.SortFunc(out, func(a, b MemberResult) int { return strings.Compare(a.UserID, b.UserID) })
+	return out, nil
+}
+
+func (m *Memory) Levels(_ context.Context, guildID string) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]int)
+	for k, row := range m.rows {
+		if k[0] == guildID {
+			out[k[1]] = row.level
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) History(_ context.Context, guildID, userID string, offset, limit int) ([]Entry, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Entry
+	total := 0
+	for i := len(m.ledger) - 1; i >= 0; i-- {
+		e := m.ledger[i]
+		if e.GuildID != guildID || e.UserID != userID {
+			continue
+		}
+		if total >= offset && len(out) < limit {
+			out = append(out, e)
+		}
+		total++
+	}
+	return out, total, nil
+}
+
+func (m *Memory) appendLedger(guildID, userID string, res Result, meta Meta) {
+	if res.XP == res.XPBefore {
+		return
+	}
+	m.ledger = append(m.ledger, Entry{
+		ID: int64(len(m.ledger) + 1), GuildID: guildID, UserID: userID,
+		Delta: res.XP - res.XPBefore, XP: res.XP, Meta: meta, At: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,357 @@
+// Package xpstore — изменение XP в users_levels без гонок.
+//
+// Каждое изменение — одна транзакция: строка участника блокируется, XP меняется
+// инкрементом (xp = xp + $n), а уровень пересчитывается по кривой тут же. Сообщение,
+// тикер войса и штраф /give для одного участника больше не перетирают XP друг друга.
+// В той же транзакции изменение записывается в журнал xp_ledger: откуда, кем и почему.
+package xpstore
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"gosha_bot/levelcurve"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Источники изменений XP (xp_ledger.source).
+const (
+	SourceMessage = "message"
+	SourceVoice   = "voice"
+	SourcePenalty = "penalty" // роль-наказание через /give
+	SourceAdmin   = "admin"   // /xp add|remove|set|reset|reset-all
+	SourceDecay   = "decay"   // затухание XP неактивных участников
+)
+
+// Meta — откуда изменение и кто его сделал; пишется в журнал вместе с дельтой.
+type Meta struct {
+	Source  string
+	ActorID string // "" — сам бот
+	Reason  string
+}
+
+// Change — одно начисление или списание XP.
+type Change struct {
+	GuildID     string
+	UserID      string
+	Username    string // непустые имена обновляются в строке
+	DisplayName string
+	Meta
+
+	Delta    int64 // < 0 — списание; XP не уходит ниже нуля
+	VoiceSec int64 // добавить к voice_sec_accum
+
+	// Для сообщений: MessageAt пишется в last_msg_at, а если прошлое сообщение
+	// было меньше Cooldown назад — ничего не меняется (Result.Skipped).
+	MessageAt time.Time
+	Cooldown  time.Duration
+}
+
+// Result — XP и уровень до и после изменения.
+type Result struct {
+	XPBefore    int64
+	XP          int64
+	LevelBefore int
+	Level       int
+	Skipped     bool // кулдаун сообщения: XP не менялся
+}
+
+// LevelChanged — уровень изменился (пора пересчитать роли).
+func (r Result) LevelChanged() bool { return r.Level != r.LevelBefore }
+
+// Entry — строка журнала xp_ledger.
+type Entry struct {
+	ID      int64
+	GuildID string
+	UserID  string
+	Delta   int64
+	XP      int64 // после изменения
+	Meta
+	At time.Time
+}
+
+// MemberResult — изменение XP одного участника в массовой операции (ResetAll, Decay).
+type MemberResult struct {
+	UserID string
+	Result
+}
+
+// Decay — списание XP у неактивных участников.
+type Decay struct {
+	Percent       float64   // сколько процентов XP снять (округляется вверх, хотя бы 1 XP)
+	InactiveSince time.Time // ни сообщений, ни войса с этого момента; без активности — с появления строки
+	DecayedBefore time.Time // прошлое списание раньше этого момента: не чаще раза в период
+	FloorXP       int64     // ниже не опускать
+}
+
+// apply — XP после списания.
+func (d Decay) apply(xp int64) int64 {
+	lost := int64(math.Ceil(float64(xp) * d.Percent / 100))
+	return max(xp-max(lost, 1), d.FloorXP)
+}
+
+// Store — атомарные изменения XP. Строка участника создаётся при первом изменении.
+// Изменения, после которых XP остался прежним (кулдаун, списание с нуля), в журнал не попадают.
+type Store interface {
+	Add(ctx context.Context, c Change) (Result, error)
+	Set(ctx context.Context, guildID, userID string, xp int64, m Meta) (Result, error)
+	// ResetAll обнуляет XP всем участникам сервера одной транзакцией.
+	ResetAll(ctx context.Context, guildID string, m Meta) ([]MemberResult, error)
+	// Decay списывает XP у неактивных участников сервера одной транзакцией и отмечает время списания.
+	Decay(ctx context.Context, guildID string, d Decay, m Meta) ([]MemberResult, error)
+	// Levels — уровни всех участников сервера, у которых есть строка в users_levels.
+	Levels(ctx context.Context, guildID string) (map[string]int, error)
+	// History — журнал участника, новые записи первыми, и сколько записей всего.
+	History(ctx context.Context, guildID, userID string, offset, limit int) ([]Entry, int, error)
+}
+
+type pgStore struct {
+	db    *pgxpool.Pool
+	curve levelcurve.Curve
+}
+
+// NewPG — Store поверх Postgres; уровень считается по curve.
+func NewPG(db *pgxpool.Pool, curve levelcurve.Curve) Store {
+	return &pgStore{db: db, curve: curve}
+}
+
+func (p *pgStore) Add(ctx context.Context, c Change) (Result, error) {
+	var res Result
+	err := p.inTx(ctx, c.GuildID, c.UserID, c.Username, c.DisplayName, func(tx pgx.Tx, xp int64, level int, lastMsg *time.Time) error {
+		res = Result{XPBefore: xp, XP: xp, LevelBefore: level, Level: level}
+		if !c.MessageAt.IsZero() && lastMsg != nil && c.MessageAt.Sub(*lastMsg) < c.Cooldown {
+			res.Skipped = true
+			return nil
+		}
+		delta := max(c.Delta, -xp) // строка заблокирована: xp — актуальное значение
+		var msgAt *time.Time
+		if !c.MessageAt.IsZero() {
+			msgAt = &c.MessageAt
+		}
+		err := tx.QueryRow(ctx, `
+UPDATE users_levels
+SET xp = xp + $3, level = $4, voice_sec_accum = voice_sec_accum + $5,
+    last_msg_at = COALESCE($6, last_msg_at),
+    last_voice_at = CASE WHEN $7 THEN now() ELSE last_voice_at END, updated_at = now()
+WHERE guild_id=$1 AND user_id=$2
+RETURNING xp, level`,
+			c.GuildID, c.UserID, delta, levelcurve.Level(p.curve, xp+delta), c.VoiceSec, msgAt, c.Source == SourceVoice,
+		).Scan(&res.XP, &res.Level)
+		if err != nil {
+			return err
+		}
+		return appendLedger(ctx, tx, c.GuildID, c.UserID, res, c.Meta)
+	})
+	return res, err
+}
+
+func (p *pgStore) Set(ctx context.Context, guildID, userID string, xp int64, m Meta) (Result, error) {
+	xp = max(xp, 0)
+	var res Result
+	err := p.inTx(ctx, guildID, userID, "", "", func(tx pgx.Tx, before int64, level int, _ *time.Time) error {
+		res = Result{XPBefore: before, LevelBefore: level}
+		err := tx.QueryRow(ctx, `
+UPDATE users_levels SET xp = $3, level = $4, updated_at = now()
+WHERE guild_id=$1 AND user_id=$2
+RETURNING xp, level`,
+			guildID, userID, xp, levelcurve.Level(p.curve, xp),
+		).Scan(&res.XP, &res.Level)
+		if err != nil {
+			return err
+		}
+		return appendLedger(ctx, tx, guildID, userID, res, m)
+	})
+	return res, err
+}
+
+func (p *pgStore) ResetAll(ctx context.Context, guildID string, m Meta) ([]MemberResult, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+SELECT user_id, xp, level FROM users_levels
+WHERE guild_id=$1 AND xp > 0
+ORDER BY user_id
+FOR UPDATE`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	var out []MemberResult
+	for rows.Next() {
+		r := MemberResult{}
+		if err := rows.Scan(&r.UserID, &r.XPBefore, &r.LevelBefore); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.Level = levelcurve.Level(p.curve, 0)
+		out = append(out, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if _, err := tx.Exec(ctx, `
+UPDATE users_levels SET xp = 0, level = $2, updated_at = now()
+WHERE guild_id=$1 AND xp > 0`, guildID, levelcurve.Level(p.curve, 0)); err != nil {
+		return nil, err
+	}
+	batch := &pgx.Batch{}
+	for _, r := range out {
+		batch.Queue(`
+INSERT INTO xp_ledger (guild_id, user_id, delta, xp_after, source, actor_id, reason)
+VALUES ($1, $2, $3, 0, $4, $5, $6)`,
+			guildID, r.UserID, -r.XPBefore, m.Source, m.ActorID, m.Reason)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+func (p *pgStore) Decay(ctx context.Context, guildID string, d Decay, m Meta) ([]MemberResult, error) {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+SELECT user_id, xp, level FROM users_levels
+WHERE guild_id=$1 AND xp > $2
+  AND COALESCE(GREATEST(last_msg_at, last_voice_at), created_at) < $3
+  AND (decayed_at IS NULL OR decayed_at < $4)
+ORDER BY user_id
+FOR UPDATE`, guildID, d.FloorXP, d.InactiveSince, d.DecayedBefore)
+	if err != nil {
+		return nil, err
+	}
+	var out []MemberResult
+	for rows.Next() {
+		r := MemberResult{}
+		if err := rows.Scan(&r.UserID, &r.XPBefore, &r.LevelBefore); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		r.XP = d.apply(r.XPBefore)
+		r.Level = levelcurve.Level(p.curve, r.XP)
+		out = append(out, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	batch := &pgx.Batch{}
+	for _, r := range out {
+		batch.Queue(`
+UPDATE users_levels SET xp = $3, level = $4, decayed_at = now(), updated_at = now()
+WHERE guild_id=$1 AND user_id=$2`, guildID, r.UserID, r.XP, r.Level)
+		batch.Queue(`
+INSERT INTO xp_ledger (guild_id, user_id, delta, xp_after, source, actor_id, reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			guildID, r.UserID, r.XP-r.XPBefore, r.XP, m.Source, m.ActorID, m.Reason)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit(ctx)
+}
+
+func (p *pgStore) History(ctx context.Context, guildID, userID string, offset, limit int) ([]Entry, int, error) {
+	var total int
+	if err := p.db.QueryRow(ctx,
+		`SELECT count(*) FROM xp_ledger WHERE guild_id=$1 AND user_id=$2`, guildID, userID,
+	).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	rows, err := p.db.Query(ctx, `
+SELECT id, delta, xp_after, source, actor_id, reason, created_at FROM xp_ledger
+WHERE guild_id=$1 AND user_id=$2
+ORDER BY id DESC OFFSET $3 LIMIT $4`, guildID, userID, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var out []Entry
+	for rows.Next() {
+		e := Entry{GuildID: guildID, UserID: userID}
+		if err := rows.Scan(&e.ID, &e.Delta, &e.XP, &e.Source, &e.ActorID, &e.Reason, &e.At); err != nil {
+			return nil, 0, err
+		}
+		out = append(out, e)
+	}
+	return out, total, rows.Err()
+}
+
+func (p *pgStore) Levels(ctx context.Context, guildID string) (map[string]int, error) {
+	rows, err := p.db.Query(ctx, `SELECT user_id, level FROM users_levels WHERE guild_id=$1`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string]int)
+	for rows.Next() {
+		var userID string
+		var level int
+		if err := rows.Scan(&userID, &level); err != nil {
+			return nil, err
+		}
+		out[userID] = level
+	}
+	return out, rows.Err()
+}
+
+// appendLedger пишет изменение в журнал той же транзакцией; XP не изменился — писать нечего.
+func appendLedger(ctx context.Context, tx pgx.Tx, guildID, userID string, res Result, m Meta) error {
+	if res.XP == res.XPBefore {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+INSERT INTO xp_ledger (guild_id, user_id, delta, xp_after, source, actor_id, reason)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		guildID, userID, res.XP-res.XPBefore, res.XP, m.Source, m.ActorID, m.Reason)
+	return err
+}
+
+// inTx создаёт (или обновляет именами) строку участника, блокируя её до конца транзакции,
+// и вызывает fn с текущими значениями. Конкурентные изменения того же участника ждут коммита.
+func (p *pgStore) inTx(ctx context.Context, guildID, userID, username, display string,
+	fn func(tx pgx.Tx, xp int64, level int, lastMsg *time.Time) error) error {
+	tx, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var xp int64
+	var level int
+	var lastMsg *time.Time
+	err = tx.QueryRow(ctx, `
+INSERT INTO users_levels (guild_id, user_id, username, display_name)
+VALUES ($1, $2, NULLIF($3,''), NULLIF($4,''))
+ON CONFLICT (guild_id, user_id) DO UPDATE
+SET
+  username     = COALESCE(NULLIF($3,''), users_levels.username),
+  display_name = COALESCE(NULLIF($4,''), users_levels.display_name)
+RETURNING xp, level, last_msg_at`,
+		guildID, userID, username, display,
+	).Scan(&xp, &level, &lastMsg)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, xp, level, lastMsg); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,294 @@
+package xpstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"gosha_bot/levelcurve"
+	"gosha_bot/logging"
+	"gosha_bot/migrate"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type testStore struct {
+	Store
+	guildID string // у Postgres — свой на каждый прогон
+}
+
+// stores — Memory всегда, Postgres — если задан POSTGRES_TEST_DSN (схема накатывается миграциями).
+// Без DSN вариант postgres помечается пропущенным, а не проходит молча.
+func stores(t *testing.T) map[string]testStore {
+	t.Helper()
+	out := map[string]testStore{"memory": {NewMemory(levelcurve.Default), "g1"}}
+
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Run("postgres", func(t *testing.T) {
+			t.Skip("POSTGRES_TEST_DSN is not set: pgStore is not tested (make test-pg)")
+		})
+		return out
+	}
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(db.Close)
+	if err := migrate.Up(ctx, db, logging.Discard()); err != nil {
+		t.Fatal(err)
+	}
+	guildID := fmt.Sprintf("xpstore-test-%d", time.Now().UnixNano())
+	// xp_ledger только дописывается — его строки остаются под уникальным guildID
+	t.Cleanup(func() { _, _ = db.Exec(ctx, `DELETE FROM users_levels WHERE guild_id=$1`, guildID) })
+	out["postgres"] = testStore{NewPG(db, levelcurve.Default), guildID}
+	return out
+}
+
+func TestConcurrentChangesAreNotLost(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			// запас, чтобы штраф в начале гонки не упёрся в 0 (XP ниже нуля не опускается)
+			const start = 1000
+			if _, err := st.Add(ctx, Change{GuildID: st.guildID, UserID: "u1", Delta: start}); err != nil {
+				t.Fatal(err)
+			}
+			// сообщения, войс и штрафы одного участника одновременно
+			const workers, each = 8, 25
+			var wg sync.WaitGroup
+			for w := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range each {
+						c := Change{GuildID: st.guildID, UserID: "u1", Delta: 10}
+						switch (w + i) % 3 {
+						case 1:
+							c.VoiceSec = 60
+						case 2:
+							c.Delta = -3
+						}
+						if _, err := st.Add(ctx, c); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			want := int64(start)
+			for w := range workers {
+				for i := range each {
+					if (w+i)%3 == 2 {
+						want -= 3
+					} else {
+						want += 10
+					}
+				}
+			}
+			res, err := st.Add(ctx, Change{GuildID: st.guildID, UserID: "u1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.XP != want {
+				t.Fatalf("xp = %d, want %d (lost updates)", res.XP, want)
+			}
+			if wantLvl := levelcurve.Level(levelcurve.Default, want); res.Level != wantLvl {
+				t.Fatalf("level = %d, want %d", res.Level, wantLvl)
+			}
+		})
+	}
+}
+
+func TestAddClampsAndSetReportsBefore(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := st.Set(ctx, st.guildID, "u2", 5000, Meta{}); err != nil {
+				t.Fatal(err)
+			}
+			res, err := st.Add(ctx, Change{GuildID: st.guildID, UserID: "u2", Delta: -9000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.XPBefore != 5000 || res.XP != 0 || res.Level != 1 || !res.LevelChanged() {
+				t.Fatalf("deduct: %+v", res)
+			}
+			res, err = st.Set(ctx, st.guildID, "u2", 250, Meta{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.XPBefore != 0 || res.XP != 250 || res.Level != levelcurve.Level(levelcurve.Default, 250) {
+				t.Fatalf("set: %+v", res)
+			}
+		})
+	}
+}
+
+func TestMessageCooldown(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			at := time.Now().UTC().Truncate(time.Second)
+			msg := func(at time.Time) Result {
+				t.Helper()
+				res, err := st.Add(ctx, Change{GuildID: st.guildID, UserID: "u3", Delta: 5, MessageAt: at, Cooldown: time.Minute})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return res
+			}
+			if res := msg(at); res.Skipped || res.XP != 5 {
+				t.Fatalf("first message: %+v", res)
+			}
+			if res := msg(at.Add(30 * time.Second)); !res.Skipped || res.XP != 5 {
+				t.Fatalf("within cooldown: %+v", res)
+			}
+			if res := msg(at.Add(time.Minute)); res.Skipped || res.XP != 10 {
+				t.Fatalf("after cooldown: %+v", res)
+			}
+		})
+	}
+}
+
+func TestLedgerRecordsChanges(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			add := func(c Change) {
+				t.Helper()
+				c.GuildID, c.UserID = st.guildID, "u4"
+				if _, err := st.Add(ctx, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			at := time.Now().UTC()
+			add(Change{Delta: 20, MessageAt: at, Cooldown: time.Minute, Meta: Meta{Source: SourceMessage}})
+			add(Change{Delta: 20, MessageAt: at.Add(time.Second), Cooldown: time.Minute, Meta: Meta{Source: SourceMessage}}) // кулдаун
+			add(Change{Delta: 7, VoiceSec: 60, Meta: Meta{Source: SourceVoice}})
+			add(Change{Delta: -100, Meta: Meta{Source: SourcePenalty, ActorID: "mod", Reason: "flood"}}) // до нуля: -27
+			add(Change{Delta: -5, Meta: Meta{Source: SourcePenalty}})                                    // уже ноль
+			if _, err := st.Set(ctx, st.guildID, "u4", 50, Meta{Source: SourceAdmin, ActorID: "mod"}); err != nil {
+				t.Fatal(err)
+			}
+
+			all, total, err := st.History(ctx, st.guildID, "u4", 0, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			type row struct {
+				delta, xp int64
+				source    string
+			}
+			want := []row{{50, 50, SourceAdmin}, {-27, 0, SourcePenalty}, {7, 27, SourceVoice}, {20, 20, SourceMessage}}
+			if total != len(want) || len(all) != len(want) {
+				t.Fatalf("total = %d, entries = %+v", total, all)
+			}
+			for i, w := range want {
+				if got := (row{all[i].Delta, all[i].XP, all[i].Source}); got != w {
+					t.Errorf("entry %d = %+v, want %+v", i, got, w)
+				}
+			}
+			if all[1].ActorID != "mod" || all[1].Reason != "flood" {
+				t.Errorf("penalty entry lost actor/reason: %+v", all[1])
+			}
+
+			page, total, err := st.History(ctx, st.guildID, "u4", 2, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 4 || len(page) != 2 || page[0].Source != SourceVoice {
+				t.Fatalf("second page: total %d, %+v", total, page)
+			}
+		})
+	}
+}
+
+func TestResetAll(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for user, xp := range map[string]int64{"a": 500, "b": 40, "c": 0} {
+				if _, err := st.Set(ctx, st.guildID, user, xp, Meta{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			resets, err := st.ResetAll(ctx, st.guildID, Meta{Source: SourceAdmin, ActorID: "mod"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resets) != 2 || resets[0].UserID != "a" || resets[0].XPBefore != 500 || resets[0].Level != 1 || resets[1].UserID != "b" {
+				t.Fatalf("resets = %+v", resets)
+			}
+			res, err := st.Add(ctx, Change{GuildID: st.guildID, UserID: "a"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.XP != 0 || res.Level != 1 {
+				t.Fatalf("after reset: %+v", res)
+			}
+			entries, _, err := st.History(ctx, st.guildID, "a", 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e := entries[0]; e.Delta != -500 || e.XP != 0 || e.Source != SourceAdmin || e.ActorID != "mod" {
+				t.Fatalf("ledger entry = %+v", e)
+			}
+		})
+	}
+}
+
+func TestDecay(t *testing.T) {
+	for name, st := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			longAgo := now.Add(-60 * 24 * time.Hour)
+			for _, c := range []Change{
+				{UserID: "old", Delta: 1000, MessageAt: longAgo},
+				{UserID: "floor", Delta: 520, MessageAt: longAgo},
+				{UserID: "chat", Delta: 1000, MessageAt: now},
+				{UserID: "voice", Delta: 1000, Meta: Meta{Source: SourceVoice}},
+			} {
+				c.GuildID = st.guildID
+				if _, err := st.Add(ctx, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+			d := Decay{Percent: 10, InactiveSince: now.Add(-30 * 24 * time.Hour), DecayedBefore: now.Add(-7 * 24 * time.Hour), FloorXP: 500}
+
+			decayed, err := st.Decay(ctx, st.guildID, d, Meta{Source: SourceDecay})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(decayed) != 2 || decayed[0].UserID != "floor" || decayed[0].XP != 500 || decayed[1].UserID != "old" || decayed[1].XP != 900 {
+				t.Fatalf("decayed = %+v", decayed)
+			}
+			if decayed[1].Level != levelcurve.Level(levelcurve.Default, 900) {
+				t.Fatalf("level not recalculated: %+v", decayed[1])
+			}
+			entries, _, err := st.History(ctx, st.guildID, "old", 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if e := entries[0]; e.Delta != -100 || e.Source != SourceDecay {
+				t.Fatalf("ledger entry = %+v", e)
+			}
+
+			// раз в период: повторный прогон ничего не снимает
+			again, err := st.Decay(ctx, st.guildID, d, Meta{Source: SourceDecay})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(again) != 0 {
+				t.Fatalf("decayed twice: %+v", again)
+			}
+		})
+	}
+}